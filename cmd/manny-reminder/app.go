@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"database/sql"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/availability"
+	calendar2 "manny-reminder/internal/calendar"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/events"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/reminders"
+	"manny-reminder/internal/sms"
+	"manny-reminder/internal/teams"
+	"manny-reminder/internal/telegram"
+	"manny-reminder/internal/templates"
+	"manny-reminder/internal/webpush"
+)
+
+// app holds the services wired together from the config, shared by the server and the admin commands.
+type app struct {
+	cfg *config.Config
+	l   *zap.Logger
+	db  *sql.DB
+
+	as  *auth.ServiceImpl
+	es  *events.ServiceImpl
+	tms *teams.ServiceImpl
+	avs *availability.ServiceImpl
+	tps *templates.ServiceImpl
+	rs  *reminders.ServiceImpl
+	ts  *telegram.ServiceImpl
+	wps *webpush.ServiceImpl
+	ss  *sms.ServiceImpl
+
+	// vapidKey is nil when web push is off
+	vapidKey        *ecdsa.PrivateKey
+	telegramEnabled bool
+}
+
+func newApp(cfg *config.Config, l *zap.Logger) (*app, error) {
+	googleConfig, err := getOAuthConfig(cfg.Google)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the Google OAuth config: %w", err)
+	}
+	msConfig := getMicrosoftOAuthConfig(cfg.Microsoft)
+
+	db, err := getDb(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the database: %w", err)
+	}
+
+	configs := auth.OAuthConfigs{models.ProviderGoogle: googleConfig}
+	cls := calendar2.Calendars{models.ProviderGoogle: calendar2.NewCalendar(googleConfig)}
+	if msConfig != nil {
+		configs[models.ProviderMicrosoft] = msConfig
+		cls[models.ProviderMicrosoft] = calendar2.NewMicrosoftCalendar(msConfig)
+	}
+
+	a := &app{cfg: cfg, l: l, db: db}
+
+	ar := auth.NewRepository(l, db)
+	a.as = auth.NewService(l, ar, configs, cls)
+	if cfg.Server.StateSecret != "" {
+		a.as.SetStateKey([]byte(cfg.Server.StateSecret))
+	} else {
+		l.Warn("No state secret configured, account links in progress won't survive a restart")
+	}
+	if cfg.Google.DeviceAuthURL != "" {
+		a.as.SetDeviceAuthURL(models.ProviderGoogle, cfg.Google.DeviceAuthURL)
+	}
+	if msConfig != nil {
+		a.as.SetDeviceAuthURL(models.ProviderMicrosoft, calendar2.MicrosoftDeviceAuthURL(cfg.Microsoft.Tenant))
+	}
+
+	er := events.NewRepository(l, db)
+	a.es = events.NewService(er, l, a.as, cls)
+	if cfg.Server.CursorSecret != "" {
+		a.es.SetCursorKey([]byte(cfg.Server.CursorSecret))
+	} else {
+		l.Warn("No cursor secret configured, events cursors won't survive a restart")
+	}
+
+	a.tms = teams.NewService(l, teams.NewRepository(l, db), a.as)
+	a.avs = availability.NewService(l, a.as, a.es)
+
+	tr := telegram.NewRepository(l, db)
+	wr := webpush.NewRepository(l, db)
+	if cfg.Reminders.HasChannel(webpush.ChannelWebPush) {
+		a.vapidKey, err = webpush.LoadKey(context.Background(), l, wr, cfg.WebPush.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the VAPID key: %w", err)
+		}
+	}
+	sr := sms.NewRepository(l, db)
+	gateway := sms.NewTwilioGateway(cfg.SMS)
+	ns, err := getNotifiers(l, cfg, tr, wr, a.vapidKey, sr, gateway)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up the notifiers: %w", err)
+	}
+	tmr := templates.NewRepository(l, db)
+	engine, err := templates.NewEngine(l, tmr, cfg.Templates.Dir, cfg.Templates.DefaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the message templates: %w", err)
+	}
+	a.tps = templates.NewService(l, tmr, engine, a.as, er, a.es)
+
+	a.rs = reminders.NewService(l, er, a.as, a.es, ns, cfg.Reminders)
+	a.rs.SetRenderer(engine)
+	if cfg.Reminders.ActionSecret != "" {
+		a.rs.SetActionKey([]byte(cfg.Reminders.ActionSecret))
+	} else {
+		l.Warn("No action secret configured, reminder action links won't survive a restart")
+	}
+	if cfg.Server.PublicURL != "" {
+		a.rs.SetPublicURL(cfg.Server.PublicURL)
+	} else {
+		l.Warn("No public URL configured, reminders are sent without action links")
+	}
+
+	a.ts = telegram.NewService(l, tr, a.as, a.rs, cfg.Telegram)
+	_, a.telegramEnabled = ns[telegram.ChannelTelegram]
+	a.wps = webpush.NewService(l, wr, a.as, a.vapidKey)
+	a.ss = sms.NewService(l, sr, a.as, gateway, cfg.SMS)
+	return a, nil
+}
+
+// getNotifiers sets up the notifier of every configured channel.
+func getNotifiers(l *zap.Logger, cfg *config.Config, tr telegram.TelegramRepository, wr webpush.WebPushRepository,
+	vapidKey *ecdsa.PrivateKey, sr sms.SmsRepository, gateway sms.Gateway) (reminders.Notifiers, error) {
+	ns := reminders.Notifiers{}
+	for _, channel := range cfg.Reminders.AllChannels() {
+		switch channel {
+		case reminders.ChannelLog:
+			ns[channel] = reminders.NewLogNotifier(l)
+		case reminders.ChannelEmail:
+			ns[channel] = reminders.NewEmailNotifier(l, cfg.Email)
+		case reminders.ChannelSlack:
+			ns[channel] = reminders.NewSlackNotifier(l, cfg.Slack)
+		case telegram.ChannelTelegram:
+			ns[channel] = telegram.NewNotifier(l, tr, cfg.Telegram)
+		case webpush.ChannelWebPush:
+			ns[channel] = webpush.NewNotifier(l, wr, vapidKey, cfg.WebPush)
+		case sms.ChannelSMS:
+			ns[channel] = sms.NewNotifier(l, sr, gateway, cfg.SMS)
+		default:
+			return nil, fmt.Errorf("unknown reminders channel %q", channel)
+		}
+	}
+	return ns, nil
+}
+
+func getDb(c config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", c.ConnectionString())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	return db, nil
+}
+
+func getOAuthConfig(c config.GoogleConfig) (*oauth2.Config, error) {
+	b, err := c.Credentials()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client secret file: %w", err)
+	}
+
+	// If modifying these scopes, delete your previously saved credentials.json.
+	oauthConfig, err := google.ConfigFromJSON(b, c.Scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse client secret file to config: %w", err)
+	}
+	if c.RedirectURL != "" {
+		oauthConfig.RedirectURL = c.RedirectURL
+	}
+	return oauthConfig, nil
+}
+
+// getMicrosoftOAuthConfig returns nil when the Microsoft provider isn't configured.
+func getMicrosoftOAuthConfig(c config.MicrosoftConfig) *oauth2.Config {
+	if c.ClientID == "" {
+		return nil
+	}
+	return calendar2.NewMicrosoftOAuthConfig(c.ClientID, c.ClientSecret, c.Tenant, c.RedirectURL, c.Scopes)
+}
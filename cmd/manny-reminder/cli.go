@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/events"
+	"manny-reminder/internal/models"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: manny-reminder [flags] [command]
+
+Commands:
+  serve                             run the server, the default
+  users list                        list the users
+  users show <user-id>              show a user with their accounts and settings
+  users delete <user-id>            delete a user with everything linked to them
+  users link -device [-provider <microsoft|google>] [-user <user-id>]
+                                    link a calendar account by entering a code on any device, to the
+                                    user or to a new one. Google's device flow rejects the Calendar
+                                    scopes, link Google accounts through /users/add
+  sync <user-id>                    schedule the reminders of a user now
+  reminders pending [-user <id>] [-limit <n>]
+                                    list the pending reminder deliveries
+  reminders resend <delivery-id>    send a delivery again at the next dispatch
+  help                              show this help
+
+The flags, listed with -h, come before the command.
+`
+
+// errUsage is returned for a command line which isn't a known command.
+var errUsage = errors.New("invalid command")
+
+// userSyncer schedules the reminders of a user, see reminders.RemindersService.
+type userSyncer interface {
+	SyncUser(ctx context.Context, user *models.User) error
+}
+
+// cli runs the admin commands against the services, writing their output to out.
+type cli struct {
+	as  auth.AuthService
+	es  events.EventsService
+	rs  userSyncer
+	out io.Writer
+}
+
+func newCLI(as auth.AuthService, es events.EventsService, rs userSyncer, out io.Writer) *cli {
+	return &cli{as: as, es: es, rs: rs, out: out}
+}
+
+// parseCommand returns the admin command of args, the command and its arguments, or errUsage when they aren't one.
+func parseCommand(args []string) (func(c cli, ctx context.Context) error, error) {
+	switch {
+	case matches(args, "users", "list"):
+		return cli.listUsers, nil
+	case matches(args, "users", "show", "*"):
+		return func(c cli, ctx context.Context) error { return c.showUser(ctx, args[2]) }, nil
+	case matches(args, "users", "delete", "*"):
+		return func(c cli, ctx context.Context) error { return c.deleteUser(ctx, args[2]) }, nil
+	case len(args) >= 2 && args[0] == "users" && args[1] == "link":
+		provider, userId, err := parseLink(args[2:])
+		if err != nil {
+			return nil, err
+		}
+		return func(c cli, ctx context.Context) error { return c.linkAccount(ctx, provider, userId) }, nil
+	case matches(args, "sync", "*"):
+		return func(c cli, ctx context.Context) error { return c.syncUser(ctx, args[1]) }, nil
+	case len(args) >= 2 && args[0] == "reminders" && args[1] == "pending":
+		filter, err := parseDeliveryFilter(args[2:])
+		if err != nil {
+			return nil, err
+		}
+		return func(c cli, ctx context.Context) error { return c.pendingDeliveries(ctx, filter) }, nil
+	case matches(args, "reminders", "resend", "*"):
+		return func(c cli, ctx context.Context) error { return c.resendDelivery(ctx, args[2]) }, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUsage, strings.Join(args, " "))
+	}
+}
+
+// parseLink reads the flags of users link. Only the device flow is available from the command line, the browser one
+// goes through /users/add. Microsoft is the default provider, Google doesn't grant the Calendar scopes to devices.
+func parseLink(args []string) (string, string, error) {
+	fs := flag.NewFlagSet("users link", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	device := fs.Bool("device", false, "link with the device flow")
+	provider := fs.String("provider", models.ProviderMicrosoft, "calendar provider of the account")
+	userId := fs.String("user", "", "user to link the account to, a new user when empty")
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() > 0 || !*device {
+		return "", "", fmt.Errorf("%w: users link %s", errUsage, strings.Join(args, " "))
+	}
+	return *provider, *userId, nil
+}
+
+// parseDeliveryFilter reads the flags of reminders pending.
+func parseDeliveryFilter(args []string) (models.DeliveryFilter, error) {
+	fs := flag.NewFlagSet("reminders pending", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	filter := models.DeliveryFilter{Status: models.DeliveryPending}
+	fs.StringVar(&filter.UserId, "user", "", "only the deliveries of the user")
+	fs.IntVar(&filter.Limit, "limit", 0, "maximum number of deliveries listed")
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() > 0 {
+		return models.DeliveryFilter{}, fmt.Errorf("%w: reminders pending %s", errUsage, strings.Join(args, " "))
+	}
+	return filter, nil
+}
+
+// matches tells whether args are exactly the words of pattern, a * standing for any one argument.
+func matches(args []string, pattern ...string) bool {
+	if len(args) != len(pattern) {
+		return false
+	}
+	for i, word := range pattern {
+		if word != "*" && word != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c cli) listUsers(ctx context.Context) error {
+	users, err := c.as.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tACCOUNTS\tTEAM\tTIME ZONE")
+	for _, user := range users {
+		team := ""
+		if user.Team != nil {
+			team = user.Team.Name
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", user.Id, value(user.Email), len(user.Accounts), team,
+			value(user.TimeZone))
+	}
+	return w.Flush()
+}
+
+func (c cli) showUser(ctx context.Context, id string) error {
+	user, err := c.getUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(c.out)
+	e.SetIndent("", "  ")
+	return e.Encode(user)
+}
+
+func (c cli) deleteUser(ctx context.Context, id string) error {
+	deleted, err := c.as.DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("user %s not found", id)
+	}
+	_, err = fmt.Fprintf(c.out, "Deleted user %s\n", id)
+	return err
+}
+
+// linkAccount runs the device flow, showing the code to enter then waiting for the user to enter it.
+func (c cli) linkAccount(ctx context.Context, provider string, userId string) error {
+	code, err := c.as.StartDeviceLink(ctx, provider, userId)
+	if err != nil {
+		return err
+	}
+	if code.VerificationURIComplete != "" {
+		fmt.Fprintf(c.out, "Open %s and check that the code is %s\n", code.VerificationURIComplete, code.UserCode)
+	} else {
+		fmt.Fprintf(c.out, "Open %s and enter the code %s\n", code.VerificationURI, code.UserCode)
+	}
+	fmt.Fprintf(c.out, "Waiting until %s...\n", code.ExpiresAt.Format(time.Kitchen))
+
+	user, err := c.as.FinishDeviceLink(ctx, *code)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("the linked user is gone")
+	}
+	_, err = fmt.Fprintf(c.out, "Linked the %s account to user %s\n", provider, user.Id)
+	return err
+}
+
+func (c cli) syncUser(ctx context.Context, id string) error {
+	user, err := c.getUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = c.rs.SyncUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "Synced user %s\n", id)
+	return err
+}
+
+func (c cli) pendingDeliveries(ctx context.Context, filter models.DeliveryFilter) error {
+	deliveries, err := c.es.GetDeliveries(ctx, filter)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER\tEVENT\tEVENT START\tCHANNEL\tSEND AT\tATTEMPTS\tLAST ERROR")
+	for _, d := range deliveries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", d.Id, d.UserId, d.EventId,
+			d.EventStart.Format(time.RFC3339), d.Channel, d.SendAt.Format(time.RFC3339), d.Attempts, d.LastError)
+	}
+	return w.Flush()
+}
+
+func (c cli) resendDelivery(ctx context.Context, id string) error {
+	delivery, err := c.es.ResendDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return fmt.Errorf("delivery %s not found, being sent or acknowledged", id)
+	}
+	_, err = fmt.Fprintf(c.out, "Resending delivery %s over %s\n", id, delivery.Channel)
+	return err
+}
+
+// getUser returns the user, an error when they don't exist.
+func (c cli) getUser(ctx context.Context, id string) (*models.User, error) {
+	user, err := c.as.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %s not found", id)
+	}
+	return user, nil
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
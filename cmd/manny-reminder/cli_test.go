@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSyncer records the users synced.
+type fakeSyncer struct {
+	synced []*models.User
+}
+
+func (f *fakeSyncer) SyncUser(_ context.Context, user *models.User) error {
+	f.synced = append(f.synced, user)
+	return nil
+}
+
+func TestParseCommand_Invalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"users"}, {"users", "show"}, {"users", "list", "extra"}, {"sync"}, {"reminders", "pending", "-limit", "x"},
+		{"reminders", "pending", "extra"}, {"users", "link"}, {"users", "link", "-device", "extra"}, {"unknown"},
+	} {
+		_, err := parseCommand(args)
+
+		assert.ErrorIs(t, err, errUsage, strings.Join(args, " "))
+	}
+}
+
+func TestParseLink_DefaultsToMicrosoft(t *testing.T) {
+	provider, userId, err := parseLink([]string{"-device"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, models.ProviderMicrosoft, provider)
+	assert.Empty(t, userId)
+}
+
+func TestCLI_LinkAccount(t *testing.T) {
+	as, _, _, c, out := initCLI(t)
+	userId := uuid.New()
+	code := &models.DeviceCode{
+		Provider: models.ProviderMicrosoft, UserId: userId.String(), DeviceCode: "device", UserCode: "ABCD-EFGH",
+		VerificationURI: "https://microsoft.com/devicelogin", ExpiresAt: time.Date(2022, 6, 1, 13, 15, 0, 0, time.UTC),
+	}
+	as.On("StartDeviceLink", mock.Anything, models.ProviderMicrosoft, userId.String()).Return(code, nil)
+	as.On("FinishDeviceLink", mock.Anything, *code).Return(&models.User{Id: &userId}, nil)
+
+	err := runCommand(c, "users", "link", "--device", "-provider", models.ProviderMicrosoft, "-user", userId.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Open https://microsoft.com/devicelogin and enter the code ABCD-EFGH\n"+
+		"Waiting until 1:15PM...\n"+
+		"Linked the microsoft account to user "+userId.String()+"\n", out.String())
+}
+
+func TestCLI_SyncUser(t *testing.T) {
+	as, _, rs, c, out := initCLI(t)
+	userId := uuid.New()
+	user := &models.User{Id: &userId}
+	as.On("GetUser", mock.Anything, userId.String()).Return(user, nil)
+
+	err := runCommand(c, "sync", userId.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*models.User{user}, rs.synced)
+	assert.Equal(t, "Synced user "+userId.String()+"\n", out.String())
+}
+
+func TestCLI_SyncUser_NotFound(t *testing.T) {
+	as, _, rs, c, _ := initCLI(t)
+	as.On("GetUser", mock.Anything, "missing").Return(nil, nil)
+
+	err := runCommand(c, "sync", "missing")
+
+	assert.EqualError(t, err, "user missing not found")
+	assert.Empty(t, rs.synced)
+}
+
+func TestCLI_PendingDeliveries(t *testing.T) {
+	_, es, _, c, out := initCLI(t)
+	id, userId := uuid.New(), uuid.New()
+	sendAt := time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+	es.On("GetDeliveries", mock.Anything, models.DeliveryFilter{
+		UserId: userId.String(), Status: models.DeliveryPending, Limit: 10,
+	}).Return(models.Deliveries{{
+		Id: &id, UserId: &userId, EventId: "event", EventStart: sendAt.Add(10 * time.Minute), Channel: "slack",
+		SendAt: sendAt, Status: models.DeliveryPending,
+	}}, nil)
+
+	err := runCommand(c, "reminders", "pending", "-user", userId.String(), "-limit", "10")
+
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{id.String(), userId.String(), "event", "2022-06-01T13:10:00Z", "slack",
+		"2022-06-01T13:00:00Z", "0"}, strings.Fields(lines[1]))
+}
+
+func TestCLI_ResendDelivery_NotResendable(t *testing.T) {
+	_, es, _, c, _ := initCLI(t)
+	es.On("ResendDelivery", mock.Anything, "delivery").Return(nil, nil)
+
+	err := runCommand(c, "reminders", "resend", "delivery")
+
+	assert.EqualError(t, err, "delivery delivery not found, being sent or acknowledged")
+}
+
+func runCommand(c *cli, args ...string) error {
+	run, err := parseCommand(args)
+	if err != nil {
+		return err
+	}
+	return run(*c, context.Background())
+}
+
+func initCLI(t *testing.T) (*mocks.AuthService, *mocks.EventsService, *fakeSyncer, *cli, *bytes.Buffer) {
+	as := mocks.NewAuthService(t)
+	es := mocks.NewEventsService(t)
+	rs := &fakeSyncer{}
+	out := &bytes.Buffer{}
+	return as, es, rs, newCLI(as, es, rs, out), out
+}
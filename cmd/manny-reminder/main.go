@@ -2,125 +2,302 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/calendar/v3"
-	"io/ioutil"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+	"io/fs"
 	"log"
 	"manny-reminder/internal/auth"
-	calendar2 "manny-reminder/internal/calendar"
+	"manny-reminder/internal/availability"
+	"manny-reminder/internal/config"
 	"manny-reminder/internal/events"
+	"manny-reminder/internal/health"
+	"manny-reminder/internal/logging"
+	"manny-reminder/internal/metrics"
+	"manny-reminder/internal/reminders"
+	"manny-reminder/internal/sms"
+	"manny-reminder/internal/teams"
+	"manny-reminder/internal/telegram"
+	"manny-reminder/internal/templates"
+	"manny-reminder/internal/tracing"
+	"manny-reminder/internal/utils"
+	"manny-reminder/internal/webpush"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"syscall"
 	"time"
 )
 
-var bindAddress = ":8080"
-
 func main() {
+	// the .env file is optional, the environment and the config file can provide everything
 	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal(err)
+	}
+	serving := len(cfg.Command) == 0 || matches(cfg.Command, "serve")
+	if matches(cfg.Command, "help") {
+		fmt.Print(usage)
+		return
+	}
+	var run func(c cli, ctx context.Context) error
+	if !serving {
+		run, err = parseCommand(cfg.Command)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "manny-reminder: %v\n\n%s", err, usage)
+			os.Exit(2)
+		}
 	}
 
-	err, config := getOAuthConfig()
+	// the admin commands log to stderr, their output goes to stdout
+	newLogger := logging.NewCommand
+	if serving {
+		newLogger = logging.New
+	}
+	l, err := newLogger(cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Sync()
 
-	l := log.New(os.Stdout, "manny-reminder ", log.LstdFlags)
-	db := getDb(err)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		l.Fatal("Unable to set up tracing", zap.Error(err))
+	}
 
-	ar := auth.NewRepository(l, db)
-	as := auth.NewService(l, ar, config)
-	ah := auth.NewHandler(as)
+	a, err := newApp(cfg, l)
+	if err != nil {
+		l.Fatal("Unable to set up the service", zap.Error(err))
+	}
 
-	cl := calendar2.NewCalendar(config)
-	er := events.NewRepository(l, db)
-	es := events.NewService(er, l, as, cl)
-	eh := events.NewHandler(es)
+	if serving {
+		serve(a, shutdownTracing)
+		return
+	}
+
+	err = command(a, run)
+	ctx, cancelFunc := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelFunc()
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		l.Error("Unable to flush the spans", zap.Error(shutdownErr))
+	}
+	if err != nil {
+		cancelFunc()
+		_ = l.Sync()
+		fmt.Fprintf(os.Stderr, "manny-reminder: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// command runs the admin command against the services of the app, until it's done or interrupted.
+func command(a *app, run func(c cli, ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return run(*newCLI(a.as, a.es, a.rs, os.Stdout), ctx)
+}
+
+// serve runs the server and the schedulers until the process is signalled, then flushes the spans.
+func serve(a *app, shutdownTracing func(context.Context) error) {
+	cfg, l := a.cfg, a.l
+
+	ah := auth.NewHandler(a.as)
+	eh := events.NewHandler(a.es, a.as)
+	tsh := teams.NewHandler(a.tms)
+	avh := availability.NewHandler(a.avs)
+	tmh := templates.NewHandler(a.tps)
+	rs := a.rs
+	rh := reminders.NewHandler(rs)
+	ts := a.ts
+	th := telegram.NewHandler(ts)
+	telegramEnabled := a.telegramEnabled
+	pollTelegram := telegramEnabled && cfg.Telegram.Polling
+	vapidKey := a.vapidKey
+	wh := webpush.NewHandler(a.wps)
+	smsEnabled := cfg.Reminders.HasChannel(sms.ChannelSMS)
+	sh := sms.NewHandler(a.ss)
+
+	hs := health.NewService(l)
+	hs.Register("postgres", a.db.PingContext)
+	hs.Register("oauth", a.as.CheckConfigs)
+	hs.Register("sync", rs.SyncHeartbeat().Check)
+	hs.Register("scheduler", rs.DispatchHeartbeat().Check)
+	if pollTelegram {
+		hs.Register("telegram", ts.Heartbeat().Check)
+	}
+	hh := health.NewHandler(hs)
 
 	sm := mux.NewRouter()
+	sm.Use(tracing.Middleware())
+	sm.Use(logging.Middleware(l))
+	sm.Use(metrics.Middleware)
+	// the response can't be written after the write timeout, so the work behind it stops too
+	sm.Use(utils.Timeout(cfg.Server.WriteTimeout))
 
 	getR := sm.Methods(http.MethodGet).Subrouter()
+	getR.HandleFunc("/healthz", hh.Live)
+	getR.HandleFunc("/readyz", hh.Ready)
+	getR.Handle("/metrics", promhttp.Handler())
 	getR.HandleFunc("/users", ah.GetUsers)
 	getR.HandleFunc("/users/add", ah.AddUser)
 	getR.HandleFunc("/users/save", ah.SaveUser)
+	getR.HandleFunc("/users/{userId}/accounts/add", ah.AddAccount)
 	getR.HandleFunc("/users/events", eh.GetUsersEvents)
 	getR.HandleFunc("/users/{userId}/events", eh.GetUserEvents)
+	getR.HandleFunc("/users/{userId}/conflicts", eh.GetUserConflicts)
+	getR.HandleFunc("/teams", tsh.GetTeams)
+	getR.HandleFunc("/teams/{teamId}", tsh.GetTeam)
+	getR.HandleFunc("/teams/{teamId}/events", eh.GetTeamEvents)
+	getR.HandleFunc("/conflicts", eh.GetSharedConflicts)
+	getR.HandleFunc("/admin/deliveries", eh.GetDeliveries)
+	getR.HandleFunc("/admin/templates", tmh.GetTemplates)
+	getR.HandleFunc("/reminders/actions/{token}", rh.DescribeAction)
+	if vapidKey != nil {
+		getR.HandleFunc("/push/key", wh.GetPublicKey)
+		getR.HandleFunc("/push/sw.js", wh.ServiceWorker)
+		getR.HandleFunc("/users/{userId}/push", wh.SubscribePage)
+	}
+
+	postR := sm.Methods(http.MethodPost).Subrouter()
+	postR.HandleFunc("/availability", avh.FindCommonSlots)
+	postR.HandleFunc("/teams", tsh.CreateTeam)
+	postR.HandleFunc("/reminders/actions/{token}", rh.PerformAction)
+	postR.HandleFunc("/admin/templates/preview", tmh.Preview)
+	postR.HandleFunc("/users/{userId}/quiet-hours/calendar", ah.SeedQuietHours)
+	if telegramEnabled {
+		postR.HandleFunc("/users/{userId}/telegram/link", th.CreateLinkCode)
+	}
+	if vapidKey != nil {
+		postR.HandleFunc("/users/{userId}/push/subscriptions", wh.Subscribe)
+	}
+	if smsEnabled {
+		postR.HandleFunc("/users/{userId}/phone", sh.StartVerification)
+		postR.HandleFunc("/users/{userId}/phone/verify", sh.ConfirmPhone)
+	}
+	if cfg.Slack.SigningSecret != "" {
+		postR.HandleFunc("/slack/interactions", reminders.NewSlackHandler(l, rs, cfg.Slack.SigningSecret).Interactions)
+	}
+
+	putR := sm.Methods(http.MethodPut).Subrouter()
+	putR.HandleFunc("/users/{userId}/preferences", ah.SavePreferences)
+	putR.HandleFunc("/users/{userId}/quiet-hours", ah.SaveQuietHours)
+	putR.HandleFunc("/users/{userId}/reminder-rules", ah.SaveReminderRules)
+	putR.HandleFunc("/teams/{teamId}", tsh.UpdateTeam)
+	putR.HandleFunc("/teams/{teamId}/members/{userId}", tsh.AddMember)
+	putR.HandleFunc("/admin/templates/{channel}/{locale}/{kind}/{format}", tmh.SaveTemplate)
+
+	deleteR := sm.Methods(http.MethodDelete).Subrouter()
+	deleteR.HandleFunc("/admin/templates/{channel}/{locale}/{kind}/{format}", tmh.DeleteTemplate)
+	deleteR.HandleFunc("/users/{userId}/quiet-hours", ah.RemoveQuietHours)
+	deleteR.HandleFunc("/teams/{teamId}", tsh.DeleteTeam)
+	deleteR.HandleFunc("/teams/{teamId}/members/{userId}", tsh.RemoveMember)
+	if vapidKey != nil {
+		deleteR.HandleFunc("/users/{userId}/push/subscriptions/{subscriptionId}", wh.Unsubscribe)
+	}
+	if smsEnabled {
+		deleteR.HandleFunc("/users/{userId}/phone", sh.RemovePhone)
+	}
 
 	// create a new server
-	s := http.Server{
-		Addr:         bindAddress,       // configure the bind address
-		Handler:      sm,                // set the default handler
-		ErrorLog:     l,                 // set the logger for the server
-		ReadTimeout:  5 * time.Second,   // max time to read request from the client
-		WriteTimeout: 10 * time.Second,  // max time to write response to the client
-		IdleTimeout:  120 * time.Second, // max time for connections using TCP Keep-Alive
+	s := &http.Server{
+		Addr:         cfg.Server.BindAddress,  // configure the bind address
+		Handler:      sm,                      // set the default handler
+		ErrorLog:     zap.NewStdLog(l),        // set the logger for the server
+		ReadTimeout:  cfg.Server.ReadTimeout,  // max time to read request from the client
+		WriteTimeout: cfg.Server.WriteTimeout, // max time to write response to the client
+		IdleTimeout:  cfg.Server.IdleTimeout,  // max time for connections using TCP Keep-Alive
+	}
+	ln, err := net.Listen("tcp", cfg.Server.BindAddress)
+	if err != nil {
+		l.Fatal("Error starting server", zap.Error(err))
 	}
 
-	// start the server
+	// the scheduler stops with the signal, a delivery it held is retried once its claim expires
+	remindersCtx, stopReminders := context.WithCancel(context.Background())
+	remindersDone := make(chan struct{})
 	go func() {
-		l.Println("Starting server on port $1", bindAddress)
+		defer close(remindersDone)
+		rs.Run(remindersCtx)
+	}()
+	if pollTelegram {
+		go ts.Run(remindersCtx)
+	}
 
-		err := s.ListenAndServe()
+	runServer(l, s, ln, cfg.Server, func() {
+		hs.SetShuttingDown()
+		stopReminders()
+	}, func(ctx context.Context) {
+		select {
+		case <-remindersDone:
+		case <-ctx.Done():
+		}
+		err := shutdownTracing(ctx)
 		if err != nil {
-			l.Println("Error starting server", "error", err)
-			os.Exit(1)
+			l.Error("Unable to flush the spans", zap.Error(err))
 		}
-	}()
+	})
+}
 
-	// trap sigterm or interrupt and gracefully shutdown the server
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, os.Kill)
+// runServer serves on the listener until SIGINT or SIGTERM. It then calls stopping, keeps serving for the drain delay
+// and shuts the server down, cancelling the requests still running once the shutdown timeout is over. stopped is
+// given what is left of the shutdown timeout.
+func runServer(l *zap.Logger, s *http.Server, ln net.Listener, c config.ServerConfig, stopping func(),
+	stopped func(ctx context.Context)) {
+	// requests derive from baseCtx, cancelling it stops the calls still in flight when shutdown times out
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	s.BaseContext = func(net.Listener) context.Context { return baseCtx }
 
-	// Block until a signal is received.
-	sig := <-c
-	log.Println("Got signal:", sig)
+	// trap sigterm or interrupt and gracefully shutdown the server
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
 
-	// gracefully shutdown the server, waiting max 30 seconds for current operations to complete
-	ctx, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
-	cancelFunc()
-	err = s.Shutdown(ctx)
-	if err != nil {
-		panic(err.Error())
-	}
-}
+	// start the server
+	go func() {
+		l.Info("Starting server", zap.Stringer("address", ln.Addr()))
 
-func getDb(err error) *sql.DB {
-	host := os.Getenv("PGSQL_HOST")
-	port, err := strconv.Atoi(os.Getenv("PGSQL_PORT"))
-	if err != nil {
-		log.Fatalf("PGSQL_PORT is in invalid format, should be int")
-	}
-	user := os.Getenv("PGSQL_USER")
-	password := os.Getenv("PGSQL_PASSWORD")
-	dbname := os.Getenv("PGSQL_DB")
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+		var err error
+		if c.TLSCertFile != "" {
+			err = s.ServeTLS(ln, c.TLSCertFile, c.TLSKeyFile)
+		} else {
+			err = s.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			l.Fatal("Error starting server", zap.Error(err))
+		}
+	}()
 
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		panic(err)
-	}
-	return db
-}
+	// Block until a signal is received.
+	sig := <-sigs
+	l.Info("Got signal", zap.Stringer("signal", sig))
+	stopping()
 
-func getOAuthConfig() (error, *oauth2.Config) {
-	b, err := ioutil.ReadFile("credentials.json")
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+	// keep serving until the load balancers saw the server isn't ready, a second signal stops right away
+	drain := time.NewTimer(c.DrainDelay)
+	select {
+	case <-drain.C:
+	case sig = <-sigs:
+		l.Info("Got signal, skipping the drain delay", zap.Stringer("signal", sig))
+		drain.Stop()
 	}
 
-	// If modifying these scopes, delete your previously saved credentials.json.
-	config, err := google.ConfigFromJSON(b, calendar.CalendarReadonlyScope)
+	// gracefully shutdown the server, waiting for current operations to complete
+	ctx, cancelFunc := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancelFunc()
+	err := s.Shutdown(ctx)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		l.Error("Unable to shut down the server gracefully", zap.Error(err))
+		cancelBase()
 	}
-	return err, config
+	stopped(ctx)
 }
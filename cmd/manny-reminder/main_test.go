@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunServer_SIGTERMCancelsRequestsPastTheShutdownTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	// a call which only ends with its request
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		cancelled <- r.Context().Err()
+	})}
+	c := config.ServerConfig{DrainDelay: 10 * time.Millisecond, ShutdownTimeout: 50 * time.Millisecond}
+	var stopping, stopped bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runServer(zap.NewNop(), s, ln, c, func() { stopping = true }, func(ctx context.Context) {
+			stopped = ctx.Err() != nil
+		})
+	}()
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-started
+
+	p, err := os.FindProcess(os.Getpid())
+	assert.Nil(t, err)
+	assert.Nil(t, p.Signal(syscall.SIGTERM))
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server didn't stop on SIGTERM")
+	}
+	assert.True(t, stopping)
+	assert.True(t, stopped)
+	select {
+	case err = <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("the request running past the shutdown timeout wasn't cancelled")
+	}
+}
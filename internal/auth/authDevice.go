@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"io/ioutil"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	// defaultDeviceInterval is the polling interval when the provider doesn't tell one, and how much slow_down adds
+	defaultDeviceInterval = 5 * time.Second
+)
+
+var (
+	ErrDeviceLinkDenied  = errors.New("the user denied the authorization")
+	ErrDeviceCodeExpired = errors.New("the device code expired before the user entered it")
+)
+
+// SetDeviceAuthURL enables the device flow of the provider, url being its device authorization endpoint.
+func (s *ServiceImpl) SetDeviceAuthURL(provider string, url string) {
+	s.deviceURLs[provider] = url
+}
+
+// StartDeviceLink starts the device flow linking an account of the provider to the user, or to a new user when userId
+// is empty. The user is then shown the code to enter, see FinishDeviceLink.
+func (s ServiceImpl) StartDeviceLink(ctx context.Context, provider string, userId string) (*models.DeviceCode, error) {
+	config, err := s.config(provider)
+	if err != nil {
+		return nil, err
+	}
+	deviceURL := s.deviceURLs[provider]
+	if deviceURL == "" {
+		return nil, fmt.Errorf("calendar provider %q doesn't support the device flow", provider)
+	}
+
+	form := url.Values{"client_id": {config.ClientID}, "scope": {strings.Join(config.Scopes, " ")}}
+	var response struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		// Google names the verification URI verification_url
+		VerificationURL         string `json:"verification_url"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	err = postForm(ctx, deviceURL, form, &response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request a device code: %w", err)
+	}
+	if response.DeviceCode == "" || response.UserCode == "" {
+		return nil, errors.New("unable to request a device code: the response has no code")
+	}
+
+	code := &models.DeviceCode{
+		Provider:                provider,
+		UserId:                  userId,
+		DeviceCode:              response.DeviceCode,
+		UserCode:                response.UserCode,
+		VerificationURI:         response.VerificationURI,
+		VerificationURIComplete: response.VerificationURIComplete,
+		ExpiresAt:               time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+		Interval:                time.Duration(response.Interval) * time.Second,
+	}
+	if code.VerificationURI == "" {
+		code.VerificationURI = response.VerificationURL
+	}
+	if code.Interval <= 0 {
+		code.Interval = defaultDeviceInterval
+	}
+	return code, nil
+}
+
+// FinishDeviceLink polls the token endpoint until the user entered the code, then stores the account like SaveUser
+// and returns the user. It fails with ErrDeviceLinkDenied or ErrDeviceCodeExpired when the user didn't authorize in
+// time, and stops with the context.
+func (s ServiceImpl) FinishDeviceLink(ctx context.Context, code models.DeviceCode) (*models.User, error) {
+	config, err := s.config(code.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := code.Interval
+	for {
+		err = s.wait(ctx, interval)
+		if err != nil {
+			return nil, err
+		}
+		if !time.Now().Before(code.ExpiresAt) {
+			return nil, ErrDeviceCodeExpired
+		}
+
+		tok, err := pollDeviceToken(ctx, config, code.DeviceCode)
+		var oe *oauthError
+		if errors.As(err, &oe) {
+			switch oe.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += defaultDeviceInterval
+				continue
+			case "access_denied":
+				return nil, ErrDeviceLinkDenied
+			case "expired_token":
+				return nil, ErrDeviceCodeExpired
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the token of the device code: %w", err)
+		}
+
+		id, err := s.saveAccount(ctx, code.Provider, code.UserId, tok)
+		if err != nil {
+			return nil, err
+		}
+		s.l.Info("Linked account with the device flow", zap.Stringer("userId", id), zap.String("provider", code.Provider))
+		return s.r.GetUser(ctx, id.String())
+	}
+}
+
+// pollDeviceToken asks the token endpoint for the token of the device code, an *oauthError telling the user didn't
+// authorize yet.
+func pollDeviceToken(ctx context.Context, config *oauth2.Config, deviceCode string) (*oauth2.Token, error) {
+	form := url.Values{"grant_type": {deviceCodeGrantType}, "device_code": {deviceCode}, "client_id": {config.ClientID}}
+	if config.ClientSecret != "" {
+		form.Set("client_secret", config.ClientSecret)
+	}
+	var response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	err := postForm(ctx, config.Endpoint.TokenURL, form, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.AccessToken == "" {
+		return nil, errors.New("the response has no access token")
+	}
+	tok := &oauth2.Token{AccessToken: response.AccessToken, TokenType: response.TokenType, RefreshToken: response.RefreshToken}
+	if response.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// oauthError is the error response of an OAuth endpoint.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// postForm posts the form to the OAuth endpoint and decodes its JSON response into v, failing with an *oauthError
+// when the endpoint tells one.
+func postForm(ctx context.Context, endpoint string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := (&http.Client{Transport: tracing.Transport(nil)}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var oe oauthError
+		if json.Unmarshal(body, &oe) == nil && oe.Code != "" {
+			return &oe
+		}
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// sleep waits for d, failing when the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
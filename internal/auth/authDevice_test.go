@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/oauth2"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deviceServer is an OAuth provider answering the device code then, in turn, each of the token responses.
+func deviceServer(t *testing.T, tokenResponses ...string) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			assert.Equal(t, "client", r.PostForm.Get("client_id"))
+			_, _ = w.Write([]byte(`{"device_code": "device", "user_code": "ABCD-EFGH",
+				"verification_url": "https://www.google.com/device", "expires_in": 1800, "interval": 5}`))
+		case "/token":
+			assert.Equal(t, deviceCodeGrantType, r.PostForm.Get("grant_type"))
+			assert.Equal(t, "device", r.PostForm.Get("device_code"))
+			response := tokenResponses[0]
+			tokenResponses = tokenResponses[1:]
+			if response[2:7] == "error" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			_, _ = w.Write([]byte(response))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func getDeviceService(t *testing.T, ts *httptest.Server) (*ServiceImpl, *mocks.AuthRepository, *[]time.Duration) {
+	as, r := getService(t)
+	as.configs[models.ProviderGoogle] = &oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: ts.URL + "/token"}}
+	as.SetDeviceAuthURL(models.ProviderGoogle, ts.URL+"/device")
+	var waits []time.Duration
+	as.wait = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return as, r, &waits
+}
+
+func TestDeviceLink_NewUser(t *testing.T) {
+	ts := deviceServer(t, `{"error": "authorization_pending"}`, `{"error": "slow_down"}`,
+		`{"access_token": "access", "token_type": "Bearer", "refresh_token": "refresh", "expires_in": 3600}`)
+	as, r, waits := getDeviceService(t, ts)
+	c := mocks.NewCalendar(t)
+	as.cs[models.ProviderGoogle] = c
+	c.On("GetEmail", mock.Anything, mock.MatchedBy(func(tok oauth2.Token) bool {
+		return tok.AccessToken == "access" && tok.RefreshToken == "refresh"
+	})).Return("user@example.com", nil)
+	c.On("GetTimeZone", mock.Anything, mock.Anything).Return("", nil)
+	c.On("GetWorkingHours", mock.Anything, mock.Anything).Return(nil, nil)
+	r.On("GetAccount", mock.Anything, models.ProviderGoogle, "user@example.com").Return(nil, nil)
+	var userId string
+	r.On("AddUser", mock.Anything, mock.Anything, "user@example.com", mock.Anything).
+		Run(func(args mock.Arguments) { userId = args.String(1) }).Return(nil)
+	r.On("GetUser", mock.Anything, mock.Anything).Return(func(_ context.Context, id string) *models.User {
+		assert.Equal(t, userId, id)
+		return &models.User{}
+	}, nil)
+
+	code, err := as.StartDeviceLink(context.Background(), models.ProviderGoogle, "")
+	assert.Nil(t, err)
+	user, err := as.FinishDeviceLink(context.Background(), *code)
+
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	assert.Equal(t, "ABCD-EFGH", code.UserCode)
+	assert.Equal(t, "https://www.google.com/device", code.VerificationURI)
+	assert.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second, 10 * time.Second}, *waits)
+}
+
+func TestDeviceLink_LinkedAccountFindsItsUser(t *testing.T) {
+	ts := deviceServer(t, `{"access_token": "access", "token_type": "Bearer", "refresh_token": "refresh", "expires_in": 3600}`)
+	as, r, _ := getDeviceService(t, ts)
+	c := mocks.NewCalendar(t)
+	as.cs[models.ProviderGoogle] = c
+	c.On("GetEmail", mock.Anything, mock.Anything).Return("user@example.com", nil)
+	accountId, userId := uuid.New(), uuid.New()
+	r.On("GetAccount", mock.Anything, models.ProviderGoogle, "user@example.com").
+		Return(&models.ConnectedAccount{Id: &accountId, UserId: &userId, Provider: models.ProviderGoogle}, nil)
+	r.On("UpdateAccountToken", mock.Anything, &accountId, mock.MatchedBy(func(token string) bool {
+		return strings.Contains(token, `"access_token":"access"`)
+	})).Return(nil)
+	r.On("GetUser", mock.Anything, userId.String()).Return(&models.User{Id: &userId}, nil)
+
+	code, err := as.StartDeviceLink(context.Background(), models.ProviderGoogle, "")
+	assert.Nil(t, err)
+	user, err := as.FinishDeviceLink(context.Background(), *code)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &userId, user.Id)
+}
+
+func TestDeviceLink_AccountOfAnotherUser(t *testing.T) {
+	ts := deviceServer(t, `{"access_token": "access", "token_type": "Bearer", "refresh_token": "refresh", "expires_in": 3600}`)
+	as, r, _ := getDeviceService(t, ts)
+	c := mocks.NewCalendar(t)
+	as.cs[models.ProviderGoogle] = c
+	c.On("GetEmail", mock.Anything, mock.Anything).Return("user@example.com", nil)
+	accountId, owner := uuid.New(), uuid.New()
+	r.On("GetAccount", mock.Anything, models.ProviderGoogle, "user@example.com").
+		Return(&models.ConnectedAccount{Id: &accountId, UserId: &owner, Provider: models.ProviderGoogle}, nil)
+
+	code, err := as.StartDeviceLink(context.Background(), models.ProviderGoogle, uuid.New().String())
+	assert.Nil(t, err)
+	_, err = as.FinishDeviceLink(context.Background(), *code)
+
+	assert.ErrorIs(t, err, ErrAccountLinked)
+}
+
+func TestDeviceLink_Denied(t *testing.T) {
+	ts := deviceServer(t, `{"error": "access_denied"}`)
+	as, _, _ := getDeviceService(t, ts)
+
+	code, err := as.StartDeviceLink(context.Background(), models.ProviderGoogle, "")
+	assert.Nil(t, err)
+	_, err = as.FinishDeviceLink(context.Background(), *code)
+
+	assert.ErrorIs(t, err, ErrDeviceLinkDenied)
+}
+
+func TestStartDeviceLink_NotSupported(t *testing.T) {
+	as, _ := getService(t)
+
+	_, err := as.StartDeviceLink(context.Background(), models.ProviderGoogle, "")
+
+	assert.EqualError(t, err, `calendar provider "google" doesn't support the device flow`)
+}
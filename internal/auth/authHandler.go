@@ -1,10 +1,18 @@
 package auth
 
 import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"manny-reminder/internal/models"
 	"manny-reminder/internal/utils"
 	"net/http"
 )
 
+// nonceCookie keeps the nonce of the OAuth state in the browser which started the link, until the provider redirects
+// back to /users/save.
+const nonceCookie = "oauth_nonce"
+
 type Handler interface {
 	GetUsers(w http.ResponseWriter, r *http.Request)
 }
@@ -17,8 +25,8 @@ func NewHandler(as AuthService) *HandlerImpl {
 	return &HandlerImpl{as: as}
 }
 
-func (h *HandlerImpl) GetUsers(w http.ResponseWriter, _ *http.Request) {
-	users, err := h.as.GetUsers()
+func (h *HandlerImpl) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.as.GetUsers(r.Context())
 	if err != nil {
 		utils.SendHttpError(w, err)
 	}
@@ -26,18 +34,132 @@ func (h *HandlerImpl) GetUsers(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (h *HandlerImpl) AddUser(w http.ResponseWriter, r *http.Request) {
-	authUrl := h.as.GetTokenFromWeb()
+	h.redirectToProvider(w, r, "")
+}
+
+// AddAccount links another calendar account to an existing user.
+func (h *HandlerImpl) AddAccount(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userId := params["userId"]
+	if userId == "" {
+		utils.SendHttpStringError(w, "User id not defined")
+		return
+	}
+
+	h.redirectToProvider(w, r, userId)
+}
+
+func (h *HandlerImpl) redirectToProvider(w http.ResponseWriter, r *http.Request, userId string) {
+	provider := r.URL.Query().Get("provider")
+	if provider == "" {
+		provider = models.ProviderGoogle
+	}
+
+	authUrl, nonce, err := h.as.GetTokenFromWeb(r.Context(), provider, userId)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: nonceCookie, Value: nonce, Path: "/users", MaxAge: int(stateTTL.Seconds()),
+		Secure: r.TLS != nil, HttpOnly: true, SameSite: http.SameSiteLaxMode,
+	})
 
 	http.Redirect(w, r, authUrl, http.StatusSeeOther)
 }
 
 func (h *HandlerImpl) SaveUser(w http.ResponseWriter, r *http.Request) {
 	authCode := r.URL.Query().Get("code")
-	err := h.as.SaveUser(authCode)
+	state := r.URL.Query().Get("state")
+	nonce := ""
+	if c, err := r.Cookie(nonceCookie); err == nil {
+		nonce = c.Value
+	}
+	err := h.as.SaveUser(r.Context(), state, nonce, authCode)
+	if errors.Is(err, ErrInvalidState) {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrAccountLinked) {
+		utils.SendJsonWithStatus(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		utils.SendHttpError(w, err)
 		return
 	}
 
+	http.SetCookie(w, &http.Cookie{Name: nonceCookie, Path: "/users", MaxAge: -1})
+
 	http.Redirect(w, r, "/users", http.StatusSeeOther)
 }
+
+// SavePreferences sets the time zone and locale of the user, an empty time zone follows their calendar.
+func (h *HandlerImpl) SavePreferences(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	var p models.UserPreferences
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	user, err := h.as.SavePreferences(r.Context(), userId, p)
+	sendUser(w, user, err)
+}
+
+// SaveQuietHours sets the quiet hours of the user, see models.QuietHours.
+func (h *HandlerImpl) SaveQuietHours(w http.ResponseWriter, r *http.Request) {
+	var q models.QuietHours
+	err := json.NewDecoder(r.Body).Decode(&q)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	user, err := h.as.SaveQuietHours(r.Context(), mux.Vars(r)["userId"], &q)
+	sendUser(w, user, err)
+}
+
+func (h *HandlerImpl) RemoveQuietHours(w http.ResponseWriter, r *http.Request) {
+	user, err := h.as.SaveQuietHours(r.Context(), mux.Vars(r)["userId"], nil)
+	sendUser(w, user, err)
+}
+
+// SeedQuietHours sets the quiet hours of the user outside the working hours of their calendar, with the policy query
+// param, defer by default.
+func (h *HandlerImpl) SeedQuietHours(w http.ResponseWriter, r *http.Request) {
+	policy := r.URL.Query().Get("policy")
+	if policy == "" {
+		policy = models.QuietDefer
+	}
+	user, err := h.as.SeedQuietHours(r.Context(), mux.Vars(r)["userId"], policy)
+	sendUser(w, user, err)
+}
+
+// SaveReminderRules sets the reminder rules of the user, see models.ReminderRules. Saving {} follows the team again.
+func (h *HandlerImpl) SaveReminderRules(w http.ResponseWriter, r *http.Request) {
+	var rules models.ReminderRules
+	err := json.NewDecoder(r.Body).Decode(&rules)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	user, err := h.as.SaveReminderRules(r.Context(), mux.Vars(r)["userId"], rules)
+	sendUser(w, user, err)
+}
+
+func sendUser(w http.ResponseWriter, user *models.User, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPreferences):
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrNoWorkingHours):
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case err != nil:
+		utils.SendHttpError(w, err)
+	case user == nil:
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+	default:
+		utils.SendJson(w, user)
+	}
+}
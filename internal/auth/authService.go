@@ -3,103 +3,428 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"golang.org/x/oauth2"
-	"log"
+	calendar2 "manny-reminder/internal/calendar"
+	"manny-reminder/internal/metrics"
 	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
 	"net/http"
 	"os"
+	"regexp"
+	"time"
 )
 
 type AuthService interface {
-	SaveUser(authCode string) error
-	GetUsers() ([]models.User, error)
-	GetTokenFromWeb() string
-	GetClient(user string) *http.Client
-	GetUser(id string) (*models.User, error)
-	RefreshUser(user *models.User) (*oauth2.Token, error)
+	SaveUser(ctx context.Context, state string, nonce string, authCode string) error
+	GetUsers(ctx context.Context) ([]models.User, error)
+	GetTokenFromWeb(ctx context.Context, provider string, userId string) (string, string, error)
+	GetClient(ctx context.Context, user string) (*http.Client, error)
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	RefreshAccount(ctx context.Context, account *models.ConnectedAccount) (*oauth2.Token, error)
+	SavePreferences(ctx context.Context, userId string, p models.UserPreferences) (*models.User, error)
+	SaveQuietHours(ctx context.Context, userId string, q *models.QuietHours) (*models.User, error)
+	SeedQuietHours(ctx context.Context, userId string, policy string) (*models.User, error)
+	SaveReminderRules(ctx context.Context, userId string, rules models.ReminderRules) (*models.User, error)
+	GetTeamMembers(ctx context.Context, teamId string) ([]models.User, error)
+	DeleteUser(ctx context.Context, id string) (bool, error)
+	StartDeviceLink(ctx context.Context, provider string, userId string) (*models.DeviceCode, error)
+	FinishDeviceLink(ctx context.Context, code models.DeviceCode) (*models.User, error)
 }
 
+var (
+	ErrInvalidPreferences = errors.New("invalid preferences")
+	ErrNoWorkingHours     = errors.New("no calendar of the user tells their working hours")
+	ErrAccountLinked      = errors.New("the calendar account is linked to another user")
+)
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+// OAuthConfigs maps a calendar provider to the OAuth config used to link and refresh its accounts.
+type OAuthConfigs map[string]*oauth2.Config
+
 type ServiceImpl struct {
-	l      *log.Logger
-	r      AuthRepository
-	config *oauth2.Config
+	l       *zap.Logger
+	r       AuthRepository
+	configs OAuthConfigs
+	cs      calendar2.Calendars
+	// deviceURLs are the device authorization endpoints of the providers supporting the device flow
+	deviceURLs map[string]string
+	wait       func(ctx context.Context, d time.Duration) error
+	states     stateCodec
 }
 
-func NewService(l *log.Logger, r AuthRepository, config *oauth2.Config) *ServiceImpl {
-	return &ServiceImpl{l, r, config}
+func NewService(l *zap.Logger, r AuthRepository, configs OAuthConfigs, cs calendar2.Calendars) *ServiceImpl {
+	return &ServiceImpl{l: l, r: r, configs: configs, cs: cs, deviceURLs: map[string]string{}, wait: sleep,
+		states: newStateCodec(randomKey())}
 }
 
-func (s ServiceImpl) GetUsers() ([]models.User, error) {
-	return s.r.GetUsers()
+// SetStateKey sets the key signing the OAuth state, so account links survive restarts and work across replicas.
+func (s *ServiceImpl) SetStateKey(key []byte) {
+	s.states = newStateCodec(key)
 }
 
-func (s ServiceImpl) GetUser(userId string) (*models.User, error) {
-	return s.r.GetUser(userId)
+func (s ServiceImpl) GetUsers(ctx context.Context) ([]models.User, error) {
+	return s.r.GetUsers(ctx)
+}
+
+func (s ServiceImpl) GetUser(ctx context.Context, userId string) (*models.User, error) {
+	return s.r.GetUser(ctx, userId)
 }
 
 // GetClient Retrieve a token, saves the token, then returns the generated client.
-func (s *ServiceImpl) GetClient(user string) *http.Client {
+func (s *ServiceImpl) GetClient(ctx context.Context, user string) (*http.Client, error) {
 	// The file credentials.json stores the user's access and refresh tokens, and is
 	// created automatically when the authorization flow completes for the first
 	// time.
 	tok, err := s.tokenFromFile("users/" + user)
 	if err != nil {
-		s.l.Fatalf("Unable to read token file: %v", err)
+		return nil, fmt.Errorf("unable to read token file: %w", err)
 	}
-	return s.config.Client(context.Background(), tok)
+	return s.configs[models.ProviderGoogle].Client(tracing.OAuthContext(ctx), tok), nil
 }
 
 // GetTokenFromWeb Request a token from the web, then returns the retrieved token.
-func (s *ServiceImpl) GetTokenFromWeb() string {
-	authURL := s.config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	return authURL
+// The provider and the user to link the account to (empty for a new user) are passed through the signed OAuth state,
+// so SaveUser knows which config to exchange the code with. The returned nonce must be kept by the browser and given
+// back to SaveUser.
+func (s *ServiceImpl) GetTokenFromWeb(_ context.Context, provider string, userId string) (string, string, error) {
+	config, err := s.config(provider)
+	if err != nil {
+		return "", "", err
+	}
+	nonce := randomNonce()
+	state, err := s.states.encode(oauthState{
+		Provider: provider, UserId: userId, Nonce: nonce, Expiry: time.Now().Add(stateTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	return authURL, nonce, nil
 }
 
-func (s ServiceImpl) SaveUser(authCode string) error {
-	tok, err := s.config.Exchange(context.TODO(), authCode)
+// SaveUser exchanges the auth code and stores the account, either for a new user or linked to the user in the state.
+// It fails with ErrInvalidState when the state wasn't issued by GetTokenFromWeb with the nonce, or expired.
+func (s ServiceImpl) SaveUser(ctx context.Context, state string, nonce string, authCode string) error {
+	st, err := s.states.decode(state, nonce, time.Now())
+	if err != nil {
+		return err
+	}
+	config, err := s.config(st.Provider)
+	if err != nil {
+		return err
+	}
+
+	tok, err := config.Exchange(tracing.OAuthContext(ctx), authCode)
 	if err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
+		return fmt.Errorf("unable to exchange authorization code: %w", err)
 	}
-	userId := uuid.NewString()
 
+	_, err = s.saveAccount(ctx, st.Provider, st.UserId, tok)
+	return err
+}
+
+// saveAccount stores the account of the token for a new user, or linked to the user when userId is set, and returns
+// the id of the user. An account already linked only gets the new token: signing in with it finds its user, and it
+// can't be linked to another user.
+func (s ServiceImpl) saveAccount(ctx context.Context, provider string, userId string, tok *oauth2.Token) (*uuid.UUID, error) {
 	ts, err := json.Marshal(tok)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	token := string(ts)
 
-	err = s.r.AddUser(userId, string(ts))
+	email, err := s.cs[provider].GetEmail(ctx, *tok)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	linked, err := s.r.GetAccount(ctx, provider, email)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		if userId != "" && userId != linked.UserId.String() {
+			return nil, ErrAccountLinked
+		}
+		err = s.r.UpdateAccountToken(ctx, linked.Id, token)
+		if err != nil {
+			return nil, err
+		}
+		return linked.UserId, nil
+	}
+
+	accountId := uuid.New()
+	account := models.ConnectedAccount{Id: &accountId, Provider: provider, Email: &email, Token: &token}
+
+	if userId == "" {
+		id := uuid.New()
+		account.UserId = &id
+		err = s.r.AddUser(ctx, id.String(), email, account)
+		if err != nil {
+			return nil, err
+		}
+		s.seedTimeZone(ctx, &id, nil, provider, *tok)
+		s.seedQuietHours(ctx, &id, provider, *tok)
+		return &id, nil
+	}
+
+	user, err := s.r.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user to link the account to does not exist")
+	}
+	account.UserId = user.Id
+
+	err = s.r.AddAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if user.TimeZone == nil {
+		s.seedTimeZone(ctx, user.Id, user.Locale, provider, *tok)
+	}
+	if user.QuietHours == nil {
+		s.seedQuietHours(ctx, user.Id, provider, *tok)
+	}
+	return user.Id, nil
+}
+
+// seedTimeZone sets the time zone of a user who has none to the one of their calendar. Users can do without one, so
+// failures are only logged.
+func (s ServiceImpl) seedTimeZone(ctx context.Context, userId *uuid.UUID, locale *string, provider string, tok oauth2.Token) {
+	timeZone, err := s.calendarTimeZone(ctx, provider, tok)
+	if err == nil {
+		err = s.r.SavePreferences(ctx, userId.String(), &timeZone, locale)
+	}
+	if err != nil {
+		s.l.Warn("Unable to set the time zone of the user from their calendar",
+			zap.Stringer("userId", userId), zap.String("provider", provider), zap.Error(err))
+	}
 }
 
-func (s ServiceImpl) RefreshUser(user *models.User) (*oauth2.Token, error) {
+// seedQuietHours makes the time outside the working hours of the calendar quiet, deferring reminders, when the
+// calendar tells them.
+func (s ServiceImpl) seedQuietHours(ctx context.Context, userId *uuid.UUID, provider string, tok oauth2.Token) {
+	hours, err := s.cs[provider].GetWorkingHours(ctx, tok)
+	if err == nil && hours != nil {
+		q := models.QuietHoursOf(*hours, models.QuietDefer)
+		err = s.r.SaveQuietHours(ctx, userId.String(), &q)
+	}
+	if err != nil {
+		s.l.Warn("Unable to set the quiet hours of the user from their calendar",
+			zap.Stringer("userId", userId), zap.String("provider", provider), zap.Error(err))
+	}
+}
+
+// calendarTimeZone returns the time zone setting of the calendar, failing when it isn't an IANA zone.
+func (s ServiceImpl) calendarTimeZone(ctx context.Context, provider string, tok oauth2.Token) (string, error) {
+	timeZone, err := s.cs[provider].GetTimeZone(ctx, tok)
+	if err != nil {
+		return "", err
+	}
+	if timeZone == "" {
+		return "", errors.New("the calendar has no time zone")
+	}
+	_, err = time.LoadLocation(timeZone)
+	if err != nil {
+		return "", fmt.Errorf("unknown time zone %q", timeZone)
+	}
+	return timeZone, nil
+}
+
+// SavePreferences sets the time zone and locale of the user and returns the user, nil when the user doesn't exist.
+// Without a time zone the one of the first calendar telling it is used.
+func (s ServiceImpl) SavePreferences(ctx context.Context, userId string, p models.UserPreferences) (*models.User, error) {
+	if p.TimeZone != "" {
+		_, err := time.LoadLocation(p.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreferences, p.TimeZone)
+		}
+	}
+	if p.Locale != "" && !localePattern.MatchString(p.Locale) {
+		return nil, fmt.Errorf("%w: the locale %q isn't a language tag", ErrInvalidPreferences, p.Locale)
+	}
+
+	user, err := s.r.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	if p.TimeZone == "" {
+		for i := range user.Accounts {
+			tok, err := s.RefreshAccount(ctx, &user.Accounts[i])
+			if err == nil {
+				p.TimeZone, err = s.calendarTimeZone(ctx, user.Accounts[i].Provider, *tok)
+			}
+			if err != nil {
+				s.l.Warn("Unable to read the time zone of the calendar", zap.Stringer("userId", user.Id),
+					zap.Stringer("accountId", user.Accounts[i].Id), zap.Error(err))
+				continue
+			}
+			break
+		}
+	}
+
+	user.TimeZone, user.Locale = optional(p.TimeZone), optional(p.Locale)
+	err = s.r.SavePreferences(ctx, userId, user.TimeZone, user.Locale)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SaveQuietHours sets the quiet hours of the user, nil removes them, and returns the user, nil when the user doesn't
+// exist.
+func (s ServiceImpl) SaveQuietHours(ctx context.Context, userId string, q *models.QuietHours) (*models.User, error) {
+	if q != nil {
+		err := q.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPreferences, err)
+		}
+	}
+	user, err := s.r.GetUser(ctx, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	err = s.r.SaveQuietHours(ctx, userId, q)
+	if err != nil {
+		return nil, err
+	}
+	user.QuietHours = q
+	return user, nil
+}
+
+// SeedQuietHours makes the time outside the working hours of the first calendar telling them quiet, with the policy.
+func (s ServiceImpl) SeedQuietHours(ctx context.Context, userId string, policy string) (*models.User, error) {
+	user, err := s.r.GetUser(ctx, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	for i := range user.Accounts {
+		account := &user.Accounts[i]
+		tok, err := s.RefreshAccount(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+		hours, err := s.cs[account.Provider].GetWorkingHours(ctx, *tok)
+		if err != nil {
+			return nil, err
+		}
+		if hours != nil {
+			q := models.QuietHoursOf(*hours, policy)
+			return s.SaveQuietHours(ctx, userId, &q)
+		}
+	}
+	return nil, ErrNoWorkingHours
+}
+
+// SaveReminderRules sets the reminder rules of the user, the fields left out follow their team, and returns the
+// user, nil when the user doesn't exist.
+func (s ServiceImpl) SaveReminderRules(ctx context.Context, userId string, rules models.ReminderRules) (*models.User, error) {
+	err := rules.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPreferences, err)
+	}
+	user, err := s.r.GetUser(ctx, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	err = s.r.SaveReminderRules(ctx, userId, rules)
+	if err != nil {
+		return nil, err
+	}
+	user.Rules = rules
+	return user, nil
+}
+
+// GetTeamMembers returns the members of the team, nil when the team doesn't exist and empty when it has none.
+func (s ServiceImpl) GetTeamMembers(ctx context.Context, teamId string) ([]models.User, error) {
+	return s.r.GetTeamMembers(ctx, teamId)
+}
+
+// DeleteUser deletes the user and everything linked to them, returning false when they don't exist.
+func (s ServiceImpl) DeleteUser(ctx context.Context, id string) (bool, error) {
+	deleted, err := s.r.DeleteUser(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		s.l.Info("Deleted user", zap.String("userId", id))
+	}
+	return deleted, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (s ServiceImpl) RefreshAccount(ctx context.Context, account *models.ConnectedAccount) (*oauth2.Token, error) {
 	var tok oauth2.Token
-	err := json.Unmarshal([]byte(*user.Token), &tok)
+	err := json.Unmarshal([]byte(*account.Token), &tok)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := s.config(account.Provider)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenSource := s.config.TokenSource(context.TODO(), &tok)
+	tokenSource := config.TokenSource(tracing.OAuthContext(ctx), &tok)
 	updatedToken, err := tokenSource.Token()
 	if err != nil {
+		metrics.ObserveTokenRefresh(account.Provider, metrics.OutcomeError)
 		return nil, err
 	}
+	metrics.ObserveTokenRefresh(account.Provider, metrics.OutcomeSuccess)
 
 	ts, err := json.Marshal(updatedToken)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.r.UpdateUserToken(user.Id, string(ts))
+	err = s.r.UpdateAccountToken(ctx, account.Id, string(ts))
 	if err != nil {
 		return nil, err
 	}
 
-	return &tok, nil
+	return updatedToken, nil
+}
+
+// CheckConfigs is the health check of the OAuth configs, every provider needs a client to link accounts.
+func (s ServiceImpl) CheckConfigs(_ context.Context) error {
+	if len(s.configs) == 0 {
+		return errors.New("no OAuth config loaded")
+	}
+	for provider, config := range s.configs {
+		if config == nil || config.ClientID == "" || config.Endpoint.TokenURL == "" {
+			return fmt.Errorf("OAuth config of %s is incomplete", provider)
+		}
+	}
+	return nil
+}
+
+func (s ServiceImpl) config(provider string) (*oauth2.Config, error) {
+	config, ok := s.configs[provider]
+	if !ok || config == nil {
+		return nil, fmt.Errorf("calendar provider %q is not configured", provider)
+	}
+	if _, ok := s.cs[provider]; !ok {
+		return nil, fmt.Errorf("calendar provider %q is not configured", provider)
+	}
+	return config, nil
 }
 
 // Retrieves a token from a local file.
@@ -111,7 +436,7 @@ func (s *ServiceImpl) tokenFromFile(file string) (*oauth2.Token, error) {
 	defer func(f *os.File) {
 		err := f.Close()
 		if err != nil {
-			s.l.Fatalf(err.Error())
+			s.l.Warn("Unable to close token file", zap.String("file", file), zap.Error(err))
 		}
 	}(f)
 	tok := &oauth2.Token{}
@@ -1,19 +1,28 @@
 package auth
 
 import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
 	"golang.org/x/oauth2"
-	"log"
+	calendar2 "manny-reminder/internal/calendar"
 	"manny-reminder/internal/models"
 	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestGetUsers_EmptyResponse(t *testing.T) {
 	as, r := getService(t)
 	var mockedUsers models.Users
 	mockAuthServiceGetUsers(r, mockedUsers, nil)
-	users, err := as.GetUsers()
+	users, err := as.GetUsers(context.Background())
 
 	assert.Nil(t, err)
 	assert.Empty(t, users)
@@ -27,7 +36,7 @@ func TestGetUsers_OneUser(t *testing.T) {
 	}
 	mockAuthServiceGetUsers(r, users, nil)
 
-	users, err := as.GetUsers()
+	users, err := as.GetUsers(context.Background())
 
 	assert.Nil(t, err)
 	assert.NotEmpty(t, users)
@@ -45,21 +54,176 @@ func TestGetUsers_MultipleUsers(t *testing.T) {
 	}
 	mockAuthServiceGetUsers(r, users, nil)
 
-	users, err := as.GetUsers()
+	users, err := as.GetUsers(context.Background())
 
 	assert.Nil(t, err)
 	assert.NotEmpty(t, users)
 	assert.Exactly(t, 4, len(users))
 }
 
+func TestGetTokenFromWeb_ProviderInState(t *testing.T) {
+	as, _ := getService(t)
+
+	authUrl, nonce, err := as.GetTokenFromWeb(context.Background(), models.ProviderGoogle, "")
+
+	assert.Nil(t, err)
+	state, err := as.states.decode(stateOf(t, authUrl), nonce, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, models.ProviderGoogle, state.Provider)
+	assert.Empty(t, state.UserId)
+}
+
+func TestGetTokenFromWeb_UserInState(t *testing.T) {
+	as, _ := getService(t)
+
+	authUrl, nonce, err := as.GetTokenFromWeb(context.Background(), models.ProviderGoogle, "user-id")
+
+	assert.Nil(t, err)
+	state, err := as.states.decode(stateOf(t, authUrl), nonce, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, models.ProviderGoogle, state.Provider)
+	assert.Equal(t, "user-id", state.UserId)
+}
+
+func TestGetTokenFromWeb_ProviderNotConfigured(t *testing.T) {
+	as, _ := getService(t)
+
+	authUrl, _, err := as.GetTokenFromWeb(context.Background(), models.ProviderMicrosoft, "")
+
+	assert.Error(t, err)
+	assert.Empty(t, authUrl)
+}
+
+func TestSaveUser_ExchangeFails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": "invalid_grant"}`))
+	}))
+	defer ts.Close()
+	as, _ := getService(t)
+	as.configs[models.ProviderGoogle].Endpoint.TokenURL = ts.URL
+	authUrl, nonce, _ := as.GetTokenFromWeb(context.Background(), models.ProviderGoogle, "")
+
+	err := as.SaveUser(context.Background(), stateOf(t, authUrl), nonce, "expired-code")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_grant")
+}
+
+func TestSaveUser_InvalidState(t *testing.T) {
+	as, _ := getService(t)
+	authUrl, nonce, _ := as.GetTokenFromWeb(context.Background(), models.ProviderGoogle, "user-id")
+	state := stateOf(t, authUrl)
+	forged, _ := newStateCodec([]byte("other key")).encode(oauthState{
+		Provider: models.ProviderGoogle, UserId: "victim-id", Nonce: nonce, Expiry: time.Now().Add(time.Hour).Unix(),
+	})
+	expired, _ := as.states.encode(oauthState{
+		Provider: models.ProviderGoogle, Nonce: nonce, Expiry: time.Now().Add(-time.Second).Unix(),
+	})
+
+	for name, c := range map[string]struct{ state, nonce string }{
+		"unsigned":    {models.ProviderGoogle + ":victim-id", nonce},
+		"forged":      {forged, nonce},
+		"expired":     {expired, nonce},
+		"other nonce": {state, "other-nonce"},
+		"no nonce":    {state, ""},
+		"tampered":    {"x" + state, nonce},
+		"malformed":   {"", nonce},
+	} {
+		err := as.SaveUser(context.Background(), c.state, c.nonce, "code")
+
+		assert.ErrorIs(t, err, ErrInvalidState, name)
+	}
+}
+
+func TestSavePreferences_UnknownTimeZone(t *testing.T) {
+	as, _ := getService(t)
+
+	_, err := as.SavePreferences(context.Background(), "user-id", models.UserPreferences{TimeZone: "Mars/Olympus_Mons"})
+
+	assert.True(t, errors.Is(err, ErrInvalidPreferences))
+}
+
+func TestSavePreferences_TimeZoneOfCalendar(t *testing.T) {
+	as, r := getService(t)
+	c := mocks.NewCalendar(t)
+	as.cs[models.ProviderGoogle] = c
+	id := uuid.New()
+	token := `{"access_token": "token", "expiry": "2999-01-01T00:00:00Z"}`
+	user := &models.User{Id: &id, Accounts: models.ConnectedAccounts{{Id: &id, Provider: models.ProviderGoogle, Token: &token}}}
+	r.On("GetUser", mock.Anything, id.String()).Return(user, nil)
+	r.On("UpdateAccountToken", mock.Anything, &id, mock.Anything).Return(nil)
+	c.On("GetTimeZone", mock.Anything, mock.Anything).Return("America/New_York", nil)
+	timeZone, locale := "America/New_York", "en-US"
+	r.On("SavePreferences", mock.Anything, id.String(), &timeZone, &locale).Return(nil)
+
+	saved, err := as.SavePreferences(context.Background(), id.String(), models.UserPreferences{Locale: "en-US"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "America/New_York", saved.Location().String())
+	assert.Equal(t, "en-US", saved.Language())
+}
+
+func TestSaveQuietHours_Invalid(t *testing.T) {
+	as, _ := getService(t)
+
+	_, err := as.SaveQuietHours(context.Background(), "user-id", &models.QuietHours{Start: "22:00", Policy: models.QuietDrop})
+
+	assert.EqualError(t, err, "invalid preferences: start and end go together")
+}
+
+func TestSaveReminderRules_Invalid(t *testing.T) {
+	as, _ := getService(t)
+
+	_, err := as.SaveReminderRules(context.Background(), "user-id", models.ReminderRules{Channels: []string{""}})
+
+	assert.ErrorIs(t, err, ErrInvalidPreferences)
+}
+
+func TestSeedQuietHours_OutsideWorkingHours(t *testing.T) {
+	as, r := getService(t)
+	c := mocks.NewCalendar(t)
+	as.cs[models.ProviderGoogle] = c
+	id := uuid.New()
+	token := `{"access_token": "token", "expiry": "2999-01-01T00:00:00Z"}`
+	user := &models.User{Id: &id, Accounts: models.ConnectedAccounts{{Id: &id, Provider: models.ProviderGoogle, Token: &token}}}
+	r.On("GetUser", mock.Anything, id.String()).Return(user, nil)
+	r.On("UpdateAccountToken", mock.Anything, &id, mock.Anything).Return(nil)
+	days := []time.Weekday{time.Monday, time.Tuesday}
+	c.On("GetWorkingHours", mock.Anything, mock.Anything).Return(&models.WorkingHours{Start: "09:00", End: "17:30", Days: days}, nil)
+	quiet := &models.QuietHours{Start: "17:30", End: "09:00", WorkingDays: days, Policy: models.QuietDrop}
+	r.On("SaveQuietHours", mock.Anything, id.String(), quiet).Return(nil)
+
+	saved, err := as.SeedQuietHours(context.Background(), id.String(), models.QuietDrop)
+
+	assert.Nil(t, err)
+	assert.Equal(t, quiet, saved.QuietHours)
+}
+
+func TestCheckConfigs_IncompleteConfig(t *testing.T) {
+	as, _ := getService(t)
+
+	err := as.CheckConfigs(context.Background())
+
+	assert.Error(t, err)
+}
+
 func getService(t *testing.T) (*ServiceImpl, *mocks.AuthRepository) {
-	l := log.Default()
+	l := zap.NewNop()
 	r := mocks.NewAuthRepository(t)
-	c := &oauth2.Config{}
-	as := NewService(l, r, c)
+	c := OAuthConfigs{models.ProviderGoogle: &oauth2.Config{}}
+	cs := calendar2.Calendars{models.ProviderGoogle: mocks.NewCalendar(t)}
+	as := NewService(l, r, c, cs)
 	return as, r
 }
 
+func stateOf(t *testing.T, authUrl string) string {
+	u, err := url.Parse(authUrl)
+	assert.Nil(t, err)
+	return u.Query().Get("state")
+}
+
 func mockAuthServiceGetUsers(as *mocks.AuthRepository, users []models.User, err error) {
-	as.On("GetUsers").Return(users, err)
+	as.On("GetUsers", mock.Anything).Return(users, err)
 }
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	stateVersion = 1
+	// stateTTL bounds the time the user has to consent on the provider's page
+	stateTTL = 15 * time.Minute
+)
+
+var ErrInvalidState = errors.New("invalid or expired OAuth state")
+
+// oauthState is carried through the provider's consent page: the provider and the user to link the account to,
+// empty for a new user. The nonce is also kept in a cookie of the browser which started the flow, so a link started
+// by someone else can't be finished in the browser of a victim.
+type oauthState struct {
+	Version  int    `json:"v"`
+	Provider string `json:"p"`
+	UserId   string `json:"u,omitempty"`
+	Nonce    string `json:"n"`
+	Expiry   int64  `json:"e"`
+}
+
+// stateCodec signs states so they can't be forged to link an account to another user, the payload is not secret.
+type stateCodec struct {
+	key []byte
+}
+
+func newStateCodec(key []byte) stateCodec {
+	return stateCodec{key: key}
+}
+
+// randomKey is used when no key is configured, flows started before a restart then fail.
+func randomKey() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}
+
+func randomNonce() string {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(nonce)
+}
+
+func (c stateCodec) encode(state oauthState) (string, error) {
+	state.Version = stateVersion
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+// decode checks the signature and the expiry of the state, and that it was started with the nonce.
+func (c stateCodec) decode(s string, nonce string, now time.Time) (*oauthState, error) {
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidState
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return nil, ErrInvalidState
+	}
+
+	var state oauthState
+	err = json.Unmarshal(payload, &state)
+	if err != nil || state.Version != stateVersion || !now.Before(time.Unix(state.Expiry, 0)) {
+		return nil, ErrInvalidState
+	}
+	if nonce == "" || !hmac.Equal([]byte(nonce), []byte(state.Nonce)) {
+		return nil, ErrInvalidState
+	}
+	return &state, nil
+}
+
+func (c stateCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -1,67 +1,118 @@
 package auth
 
 import (
+	"context"
 	"database/sql"
 	"github.com/google/uuid"
-	"log"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
 	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"time"
 )
 
 type AuthRepository interface {
-	GetUsers() ([]models.User, error)
-	AddUser(authCode string, token string) error
-	GetUser(id string) (*models.User, error)
-	UpdateUserToken(id *uuid.UUID, token string) error
+	GetUsers(ctx context.Context) ([]models.User, error)
+	AddUser(ctx context.Context, id string, email string, account models.ConnectedAccount) error
+	GetUser(ctx context.Context, id string) (*models.User, error)
+	AddAccount(ctx context.Context, account models.ConnectedAccount) error
+	GetAccount(ctx context.Context, provider string, email string) (*models.ConnectedAccount, error)
+	UpdateAccountToken(ctx context.Context, id *uuid.UUID, token string) error
+	SavePreferences(ctx context.Context, userId string, timeZone *string, locale *string) error
+	SaveQuietHours(ctx context.Context, userId string, q *models.QuietHours) error
+	SaveReminderRules(ctx context.Context, userId string, rules models.ReminderRules) error
+	GetTeamMembers(ctx context.Context, teamId string) ([]models.User, error)
+	DeleteUser(ctx context.Context, id string) (bool, error)
 }
 
 type RepositoryImpl struct {
-	l  *log.Logger
+	l  *zap.Logger
 	db *sql.DB
 }
 
-func NewRepository(l *log.Logger, db *sql.DB) *RepositoryImpl {
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
 	return &RepositoryImpl{l, db}
 }
 
-func (r RepositoryImpl) GetUsers() ([]models.User, error) {
-	var res models.User
-	var users []models.User
-	rows, err := r.db.Query("SELECT id, email, token FROM users")
+const selectUsersWithAccounts = `SELECT u.id, u.email, u.phone, u.time_zone, u.locale,
+u.quiet_start, u.quiet_end, u.working_days, u.quiet_policy, u.reminder_offsets, u.reminder_channels,
+t.id, t.name, t.reminder_offsets, t.reminder_channels, a.id, a.user_id, a.provider, a.email, a.token
+FROM users u LEFT JOIN teams t ON t.id = u.team_id LEFT JOIN accounts a ON a.user_id = u.id`
+
+const insertAccount = "INSERT INTO accounts (id, user_id, provider, email, token) VALUES ($1, $2, $3, $4, $5)"
+
+func (r RepositoryImpl) GetUsers(ctx context.Context) (_ []models.User, err error) {
+	query := selectUsersWithAccounts + " ORDER BY u.id, a.id"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.GetUsers", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
 		err := rows.Close()
 		if err != nil {
-			r.l.Fatal(err)
+			r.l.Error("Unable to close users rows", zap.Error(err))
 		}
 	}()
-	for rows.Next() {
-		err := rows.Scan(&res.Id, &res.Email, &res.Token)
+	return scanUsers(rows)
+}
+
+func (r RepositoryImpl) GetUser(ctx context.Context, userId string) (_ *models.User, err error) {
+	query := selectUsersWithAccounts + " WHERE u.id = $1 ORDER BY a.id"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.GetUser", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
 		if err != nil {
-			return nil, err
+			r.l.Error("Unable to close users rows", zap.Error(err))
 		}
-		users = append(users, res)
+	}()
+	users, err := scanUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
 	}
-	return users, nil
+
+	return &users[0], nil
 }
 
-func (r RepositoryImpl) GetUser(userId string) (*models.User, error) {
-	var user models.User
-	row := r.db.QueryRow("SELECT id, email, token FROM users WHERE id = $1 LIMIT 1", userId)
-	err := row.Scan(&user.Id, &user.Email, &user.Token)
+func (r RepositoryImpl) AddUser(ctx context.Context, id string, email string, account models.ConnectedAccount) (err error) {
+	query := "INSERT INTO users (id, email) VALUES ($1, $2)"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.AddUser", query+"; "+insertAccount)
+	defer tracing.End(span, &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+		return err
 	}
+	defer tx.Rollback()
 
-	return &user, nil
+	_, err = tx.ExecContext(ctx, query, id, email)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, insertAccount, account.Id, account.UserId, account.Provider, account.Email, account.Token)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r RepositoryImpl) AddUser(id, token string) error {
-	_, err := r.db.Exec("INSERT INTO users (id, token) VALUES ($1, $2)", id, token)
+func (r RepositoryImpl) AddAccount(ctx context.Context, account models.ConnectedAccount) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.AddAccount", insertAccount)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, insertAccount, account.Id, account.UserId, account.Provider, account.Email, account.Token)
 	if err != nil {
 		return err
 	}
@@ -69,11 +120,198 @@ func (r RepositoryImpl) AddUser(id, token string) error {
 	return nil
 }
 
-func (r RepositoryImpl) UpdateUserToken(id *uuid.UUID, token string) error {
-	_, err := r.db.Exec("UPDATE users (token) VALUES ($2) WHERE Id = '$1'", id, token)
+// GetAccount returns the account of the provider with the email, nil when no one linked it.
+func (r RepositoryImpl) GetAccount(ctx context.Context, provider string, email string) (_ *models.ConnectedAccount, err error) {
+	query := "SELECT id, user_id, provider, email, token FROM accounts WHERE provider = $1 AND email = $2"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.GetAccount", query)
+	defer tracing.End(span, &err)
+
+	var account models.ConnectedAccount
+	err = r.db.QueryRowContext(ctx, query, provider, email).
+		Scan(&account.Id, &account.UserId, &account.Provider, &account.Email, &account.Token)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r RepositoryImpl) UpdateAccountToken(ctx context.Context, id *uuid.UUID, token string) (err error) {
+	query := "UPDATE accounts SET token = $2 WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.UpdateAccountToken", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, id, token)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// SavePreferences sets the time zone and locale of the user, nil clears them.
+func (r RepositoryImpl) SavePreferences(ctx context.Context, userId string, timeZone *string, locale *string) (err error) {
+	query := "UPDATE users SET time_zone = $2, locale = $3 WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.SavePreferences", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, userId, timeZone, locale)
+	return err
+}
+
+// SaveQuietHours sets the quiet hours of the user, nil clears them.
+func (r RepositoryImpl) SaveQuietHours(ctx context.Context, userId string, q *models.QuietHours) (err error) {
+	query := "UPDATE users SET quiet_start = $2, quiet_end = $3, working_days = $4, quiet_policy = $5 WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.SaveQuietHours", query)
+	defer tracing.End(span, &err)
+
+	if q == nil {
+		_, err = r.db.ExecContext(ctx, query, userId, nil, nil, nil, nil)
+		return err
+	}
+	var days pq.Int64Array
+	if q.WorkingDays != nil {
+		days = pq.Int64Array{}
+		for _, day := range q.WorkingDays {
+			days = append(days, int64(day))
+		}
+	}
+	_, err = r.db.ExecContext(ctx, query, userId, q.Start, q.End, days, q.Policy)
+	return err
+}
+
+// SaveReminderRules sets the reminder rules of the user, overriding those of their team.
+func (r RepositoryImpl) SaveReminderRules(ctx context.Context, userId string, rules models.ReminderRules) (err error) {
+	query := "UPDATE users SET reminder_offsets = $2, reminder_channels = $3 WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.SaveReminderRules", query)
+	defer tracing.End(span, &err)
+
+	offsets, channels := RulesArrays(rules)
+	_, err = r.db.ExecContext(ctx, query, userId, offsets, channels)
+	return err
+}
+
+// DeleteUser deletes the user together with their accounts, deliveries and channels, returning false when they don't
+// exist.
+func (r RepositoryImpl) DeleteUser(ctx context.Context, id string) (_ bool, err error) {
+	query := "DELETE FROM users WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.DeleteUser", query)
+	defer tracing.End(span, &err)
+
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// GetTeamMembers returns the members of the team with their accounts, nil when the team doesn't exist and empty
+// when it has no members.
+func (r RepositoryImpl) GetTeamMembers(ctx context.Context, teamId string) (_ []models.User, err error) {
+	query := selectUsersWithAccounts + " WHERE u.team_id = $1 ORDER BY u.id, a.id"
+	ctx, span := tracing.StartQuery(ctx, "AuthRepository.GetTeamMembers", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, teamId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			r.l.Error("Unable to close users rows", zap.Error(err))
+		}
+	}()
+	users, err := scanUsers(rows)
+	if err != nil || users != nil {
+		return users, err
+	}
+
+	// without members, tell an empty team from a missing one
+	var exists bool
+	err = r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM teams WHERE id = $1)", teamId).Scan(&exists)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return []models.User{}, nil
+}
+
+// RulesArrays are the columns reminder rules are stored in, NULL for the fields inherited.
+func RulesArrays(rules models.ReminderRules) (pq.Int64Array, pq.StringArray) {
+	var offsets pq.Int64Array
+	if rules.OffsetMinutes != nil {
+		offsets = pq.Int64Array{}
+		for _, offset := range rules.OffsetMinutes {
+			offsets = append(offsets, int64(offset))
+		}
+	}
+	return offsets, pq.StringArray(rules.Channels)
+}
+
+// RulesOf reads reminder rules back from their columns.
+func RulesOf(offsets pq.Int64Array, channels pq.StringArray) models.ReminderRules {
+	rules := models.ReminderRules{Channels: channels}
+	if offsets != nil {
+		rules.OffsetMinutes = []int{}
+		for _, offset := range offsets {
+			rules.OffsetMinutes = append(rules.OffsetMinutes, int(offset))
+		}
+	}
+	return rules
+}
+
+// scanUsers folds the rows of a users/accounts join, ordered by user, into users with their accounts.
+func scanUsers(rows *sql.Rows) ([]models.User, error) {
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		var account models.ConnectedAccount
+		var accountId, accountUserId *uuid.UUID
+		var provider *string
+		var quietStart, quietEnd, quietPolicy *string
+		var workingDays pq.Int64Array
+		var offsets, teamOffsets pq.Int64Array
+		var channels, teamChannels pq.StringArray
+		var teamId *uuid.UUID
+		var teamName *string
+		err := rows.Scan(&user.Id, &user.Email, &user.Phone, &user.TimeZone, &user.Locale,
+			&quietStart, &quietEnd, &workingDays, &quietPolicy, &offsets, &channels,
+			&teamId, &teamName, &teamOffsets, &teamChannels, &accountId, &accountUserId, &provider, &account.Email, &account.Token)
+		if err != nil {
+			return nil, err
+		}
+		user.Rules = RulesOf(offsets, channels)
+		if teamId != nil {
+			user.Team = &models.Team{Id: teamId, Name: *teamName, Rules: RulesOf(teamOffsets, teamChannels)}
+		}
+		if quietPolicy != nil {
+			user.QuietHours = &models.QuietHours{Policy: *quietPolicy}
+			if quietStart != nil && quietEnd != nil {
+				user.QuietHours.Start, user.QuietHours.End = *quietStart, *quietEnd
+			}
+			if workingDays != nil {
+				user.QuietHours.WorkingDays = []time.Weekday{}
+				for _, day := range workingDays {
+					user.QuietHours.WorkingDays = append(user.QuietHours.WorkingDays, time.Weekday(day))
+				}
+			}
+		}
+		if len(users) == 0 || *users[len(users)-1].Id != *user.Id {
+			users = append(users, user)
+		}
+		if accountId != nil {
+			account.Id = accountId
+			account.UserId = accountUserId
+			account.Provider = *provider
+			last := &users[len(users)-1]
+			last.Accounts = append(last.Accounts, account)
+		}
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,31 @@
+package availability
+
+import (
+	"encoding/json"
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+type HandlerImpl struct {
+	as AvailabilityService
+}
+
+func NewHandler(as AvailabilityService) *HandlerImpl {
+	return &HandlerImpl{as: as}
+}
+
+func (h HandlerImpl) FindCommonSlots(w http.ResponseWriter, r *http.Request) {
+	var request Request
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+
+	slots, err := h.as.FindCommonSlots(r.Context(), request)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, slots)
+}
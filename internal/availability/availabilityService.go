@@ -0,0 +1,227 @@
+package availability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/events"
+	"manny-reminder/internal/models"
+	"sort"
+	"time"
+)
+
+// maxRange bounds how far ahead availability can be searched, each request fetches the events of every user.
+const maxRange = 31 * 24 * time.Hour
+
+type AvailabilityService interface {
+	FindCommonSlots(ctx context.Context, request Request) (models.Slots, error)
+}
+
+type Request struct {
+	UserIds         []string     `json:"userIds"`
+	From            time.Time    `json:"from"`
+	To              time.Time    `json:"to"`
+	WorkingHours    WorkingHours `json:"workingHours"`
+	DurationMinutes int          `json:"durationMinutes"`
+}
+
+// WorkingHours are the daily hours, as "15:04" in the time zone, on the weekdays meetings can be held.
+// Weekdays default to Monday to Friday and the time zone to UTC.
+type WorkingHours struct {
+	Start    string         `json:"start"`
+	End      string         `json:"end"`
+	TimeZone string         `json:"timeZone"`
+	Weekdays []time.Weekday `json:"weekdays"`
+}
+
+type ServiceImpl struct {
+	l  *zap.Logger
+	as auth.AuthService
+	es events.EventsService
+}
+
+func NewService(l *zap.Logger, as auth.AuthService, es events.EventsService) *ServiceImpl {
+	return &ServiceImpl{l: l, as: as, es: es}
+}
+
+// FindCommonSlots returns the windows within working hours where none of the users is busy and which are long
+// enough for the meeting. Accepted and tentative events count as busy.
+func (s ServiceImpl) FindCommonSlots(ctx context.Context, request Request) (models.Slots, error) {
+	err := request.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var busy models.Slots
+	for _, userId := range request.UserIds {
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+		user, err := s.as.GetUser(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %s does not exist", userId)
+		}
+
+		userEvents, err := s.es.GetEventsInRangeForUser(ctx, user, request.From, request.To)
+		if err != nil {
+			return nil, err
+		}
+		busy = append(busy, busySlots(userEvents)...)
+	}
+
+	windows, err := request.WorkingHours.windows(request.From, request.To)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(request.DurationMinutes) * time.Minute
+	var result models.Slots
+	for _, free := range subtract(windows, mergeSlots(busy)) {
+		if free.End.Sub(free.Start) >= duration {
+			result = append(result, free)
+		}
+	}
+
+	return result, nil
+}
+
+func (r Request) validate() error {
+	if len(r.UserIds) == 0 {
+		return errors.New("at least one user id is required")
+	}
+	if !r.To.After(r.From) {
+		return errors.New("to must be after from")
+	}
+	if r.To.Sub(r.From) > maxRange {
+		return fmt.Errorf("range can't be longer than %s", maxRange)
+	}
+	if r.DurationMinutes <= 0 {
+		return errors.New("durationMinutes must be positive")
+	}
+	return nil
+}
+
+// windows returns the working hours of every working day within the range, clipped to it.
+func (w WorkingHours) windows(from time.Time, to time.Time) (models.Slots, error) {
+	loc := time.UTC
+	if w.TimeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working hours start: %w", err)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working hours end: %w", err)
+	}
+	if !end.After(start) {
+		return nil, errors.New("working hours must end after they start")
+	}
+	weekdays := w.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+	working := make(map[time.Weekday]bool)
+	for _, d := range weekdays {
+		working[d] = true
+	}
+
+	var result models.Slots
+	f := from.In(loc)
+	for day := time.Date(f.Year(), f.Month(), f.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !working[day.Weekday()] {
+			continue
+		}
+		// building the times from the date keeps the hours right on days a DST change happens
+		window := models.Slot{
+			Start: time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc),
+			End:   time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc),
+		}
+		if window.Start.Before(from) {
+			window.Start = from
+		}
+		if window.End.After(to) {
+			window.End = to
+		}
+		if window.End.After(window.Start) {
+			result = append(result, window)
+		}
+	}
+
+	return result, nil
+}
+
+// busySlots returns the spans of the events the user attends. Events without times, like all-day ones, are ignored.
+func busySlots(events models.Events) models.Slots {
+	var result models.Slots
+	for _, event := range events {
+		if event.ResponseStatus != models.ResponseAccepted && event.ResponseStatus != models.ResponseTentative {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, event.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, event.End)
+		if err != nil {
+			continue
+		}
+		result = append(result, models.Slot{Start: start, End: end})
+	}
+	return result
+}
+
+// mergeSlots sorts the slots and joins the overlapping or touching ones.
+func mergeSlots(slots models.Slots) models.Slots {
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+
+	var result models.Slots
+	for _, slot := range slots {
+		if len(result) > 0 && !slot.Start.After(result[len(result)-1].End) {
+			last := &result[len(result)-1]
+			if slot.End.After(last.End) {
+				last.End = slot.End
+			}
+			continue
+		}
+		result = append(result, slot)
+	}
+	return result
+}
+
+// subtract removes the sorted, merged busy slots from the sorted windows.
+func subtract(windows models.Slots, busy models.Slots) models.Slots {
+	var result models.Slots
+	for _, window := range windows {
+		start := window.Start
+		for _, b := range busy {
+			if !b.End.After(start) {
+				continue
+			}
+			if !b.Start.Before(window.End) {
+				break
+			}
+			if b.Start.After(start) {
+				result = append(result, models.Slot{Start: start, End: b.Start})
+			}
+			start = b.End
+		}
+		if window.End.After(start) {
+			result = append(result, models.Slot{Start: start, End: window.End})
+		}
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+package availability
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"testing"
+	"time"
+)
+
+func TestFindCommonSlots_NoEvents_WorkingHours(t *testing.T) {
+	as, es, s := initService(t)
+	user := generateUser()
+	mockAuthServiceGetUser(as, user)
+	mockEventsServiceGetEventsInRangeForUser(es, user, nil)
+
+	// Friday to Monday, the weekend is skipped
+	slots, err := s.FindCommonSlots(context.Background(), Request{
+		UserIds:         []string{user.Id.String()},
+		From:            parse("2022-06-03T00:00:00Z"),
+		To:              parse("2022-06-07T00:00:00Z"),
+		WorkingHours:    WorkingHours{Start: "09:00", End: "17:00"},
+		DurationMinutes: 30,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, models.Slots{
+		{Start: parse("2022-06-03T09:00:00Z"), End: parse("2022-06-03T17:00:00Z")},
+		{Start: parse("2022-06-06T09:00:00Z"), End: parse("2022-06-06T17:00:00Z")},
+	}, utc(slots))
+}
+
+func TestFindCommonSlots_BusyAcrossUsers(t *testing.T) {
+	as, es, s := initService(t)
+	user1 := generateUser()
+	user2 := generateUser()
+	mockAuthServiceGetUser(as, user1)
+	mockAuthServiceGetUser(as, user2)
+	mockEventsServiceGetEventsInRangeForUser(es, user1, models.Events{
+		event("2022-06-01T09:30:00Z", "2022-06-01T10:00:00Z", models.ResponseAccepted),
+		event("2022-06-01T11:00:00Z", "2022-06-01T12:00:00Z", models.ResponseDeclined),
+	})
+	mockEventsServiceGetEventsInRangeForUser(es, user2, models.Events{
+		event("2022-06-01T12:00:00+02:00", "2022-06-01T12:50:00+02:00", models.ResponseTentative),
+		event("2022-06-01T11:15:00Z", "2022-06-01T12:00:00Z", models.ResponseAccepted),
+	})
+
+	slots, err := s.FindCommonSlots(context.Background(), Request{
+		UserIds:         []string{user1.Id.String(), user2.Id.String()},
+		From:            parse("2022-06-01T00:00:00Z"),
+		To:              parse("2022-06-02T00:00:00Z"),
+		WorkingHours:    WorkingHours{Start: "09:00", End: "13:00"},
+		DurationMinutes: 30,
+	})
+
+	assert.Nil(t, err)
+	// 10:50 to 11:15 is free too, but too short for the meeting
+	assert.Equal(t, models.Slots{
+		{Start: parse("2022-06-01T09:00:00Z"), End: parse("2022-06-01T09:30:00Z")},
+		{Start: parse("2022-06-01T12:00:00Z"), End: parse("2022-06-01T13:00:00Z")},
+	}, utc(slots))
+}
+
+func TestFindCommonSlots_WorkingHoursTimeZoneAcrossDst(t *testing.T) {
+	as, es, s := initService(t)
+	user := generateUser()
+	mockAuthServiceGetUser(as, user)
+	mockEventsServiceGetEventsInRangeForUser(es, user, nil)
+
+	// Berlin switches to summer time on Sunday 2022-03-27
+	slots, err := s.FindCommonSlots(context.Background(), Request{
+		UserIds:         []string{user.Id.String()},
+		From:            parse("2022-03-26T00:00:00Z"),
+		To:              parse("2022-03-29T00:00:00Z"),
+		WorkingHours:    WorkingHours{Start: "09:00", End: "10:00", TimeZone: "Europe/Berlin", Weekdays: []time.Weekday{time.Saturday, time.Monday}},
+		DurationMinutes: 60,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, models.Slots{
+		{Start: parse("2022-03-26T08:00:00Z"), End: parse("2022-03-26T09:00:00Z")},
+		{Start: parse("2022-03-28T07:00:00Z"), End: parse("2022-03-28T08:00:00Z")},
+	}, utc(slots))
+}
+
+func TestFindCommonSlots_UnknownUser(t *testing.T) {
+	as, _, s := initService(t)
+	as.On("GetUser", mock.Anything, mock.Anything).Return(nil, nil)
+
+	slots, err := s.FindCommonSlots(context.Background(), Request{
+		UserIds:         []string{uuid.NewString()},
+		From:            parse("2022-06-01T00:00:00Z"),
+		To:              parse("2022-06-02T00:00:00Z"),
+		WorkingHours:    WorkingHours{Start: "09:00", End: "17:00"},
+		DurationMinutes: 30,
+	})
+
+	assert.Error(t, err)
+	assert.Empty(t, slots)
+}
+
+func TestFindCommonSlots_InvalidRequest(t *testing.T) {
+	_, _, s := initService(t)
+
+	slots, err := s.FindCommonSlots(context.Background(), Request{
+		UserIds:      []string{uuid.NewString()},
+		From:         parse("2022-06-01T00:00:00Z"),
+		To:           parse("2022-06-02T00:00:00Z"),
+		WorkingHours: WorkingHours{Start: "09:00", End: "17:00"},
+	})
+
+	assert.Error(t, err)
+	assert.Empty(t, slots)
+}
+
+func initService(t *testing.T) (*mocks.AuthService, *mocks.EventsService, *ServiceImpl) {
+	as := mocks.NewAuthService(t)
+	es := mocks.NewEventsService(t)
+	s := NewService(zap.NewNop(), as, es)
+	return as, es, s
+}
+
+func mockAuthServiceGetUser(as *mocks.AuthService, user *models.User) {
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+}
+
+func mockEventsServiceGetEventsInRangeForUser(es *mocks.EventsService, user *models.User, events models.Events) {
+	es.On("GetEventsInRangeForUser", mock.Anything, user, mock.Anything, mock.Anything).Return(events, nil)
+}
+
+func generateUser() *models.User {
+	id := uuid.New()
+	return &models.User{Id: &id}
+}
+
+func event(start string, end string, responseStatus string) models.Event {
+	return models.Event{Start: start, End: end, ResponseStatus: responseStatus}
+}
+
+func parse(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+func utc(slots models.Slots) models.Slots {
+	var result models.Slots
+	for _, slot := range slots {
+		result = append(result, models.Slot{Start: slot.Start.UTC(), End: slot.End.UTC()})
+	}
+	return result
+}
@@ -2,17 +2,29 @@ package calendar
 
 import (
 	"context"
+	"errors"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
+	"manny-reminder/internal/metrics"
 	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"net/http"
 	"time"
 )
 
 type Calendar interface {
 	GetEventsForUser(ctx context.Context, tok oauth2.Token, nextPageToken string, size int) (*models.Events, string, error)
+	GetEventsInRange(ctx context.Context, tok oauth2.Token, from time.Time, to time.Time) (*models.Events, error)
+	GetEmail(ctx context.Context, tok oauth2.Token) (string, error)
+	GetTimeZone(ctx context.Context, tok oauth2.Token) (string, error)
+	GetWorkingHours(ctx context.Context, tok oauth2.Token) (*models.WorkingHours, error)
 }
 
+// Calendars maps a provider name to the Calendar serving its users.
+type Calendars map[string]Calendar
+
 type GoogleCalendar struct {
 	config *oauth2.Config
 }
@@ -21,11 +33,10 @@ func NewCalendar(c *oauth2.Config) *GoogleCalendar {
 	return &GoogleCalendar{config: c}
 }
 
-func (c GoogleCalendar) GetEventsForUser(ctx context.Context, tok oauth2.Token, pageToken string, size int) (*models.Events, string, error) {
-
-	client := c.config.Client(context.Background(), &tok)
+func (c GoogleCalendar) GetEventsForUser(ctx context.Context, tok oauth2.Token, pageToken string, size int) (_ *models.Events, _ string, err error) {
+	defer observe(models.ProviderGoogle, "events.list", time.Now(), &err)
 
-	srv, err := calendar.NewService(ctx, option.WithHTTPClient(client))
+	srv, err := c.service(ctx, tok)
 	if err != nil {
 		return nil, "", err
 	}
@@ -47,23 +58,167 @@ func (c GoogleCalendar) GetEventsForUser(ctx context.Context, tok oauth2.Token,
 
 	var result models.Events
 	for _, item := range events.Items {
-		date := item.Start.DateTime
-		if date == "" {
-			date = item.Start.Date
-		}
-		var attendees []string
-		for _, attendee := range item.Attendees {
-			attendees = append(attendees, attendee.Email)
+		result = append(result, toEvent(item))
+	}
+
+	return &result, events.NextPageToken, nil
+}
+
+// GetEventsInRange returns every event overlapping the range, following all pages. Cancelled events are among them,
+// the sync needs them to tell the user, listings leave them out.
+func (c GoogleCalendar) GetEventsInRange(ctx context.Context, tok oauth2.Token, from time.Time, to time.Time) (_ *models.Events, err error) {
+	defer observe(models.ProviderGoogle, "events.range", time.Now(), &err)
+
+	srv, err := c.service(ctx, tok)
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.Events
+	err = srv.Events.
+		List("primary").
+		ShowDeleted(true).
+		SingleEvents(true).
+		TimeMin(from.Format(time.RFC3339)).
+		TimeMax(to.Format(time.RFC3339)).
+		OrderBy("startTime").
+		Pages(ctx, func(events *calendar.Events) error {
+			for _, item := range events.Items {
+				result = append(result, toEvent(item))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetEmail returns the id of the primary calendar, which Google sets to the account's email.
+func (c GoogleCalendar) GetEmail(ctx context.Context, tok oauth2.Token) (_ string, err error) {
+	defer observe(models.ProviderGoogle, "email", time.Now(), &err)
+
+	srv, err := c.service(ctx, tok)
+	if err != nil {
+		return "", err
+	}
+
+	primary, err := srv.CalendarList.Get("primary").Do()
+	if err != nil {
+		return "", err
+	}
+
+	return primary.Id, nil
+}
+
+// GetTimeZone returns the time zone setting of the calendar, an IANA name.
+func (c GoogleCalendar) GetTimeZone(ctx context.Context, tok oauth2.Token) (_ string, err error) {
+	defer observe(models.ProviderGoogle, "timezone", time.Now(), &err)
+
+	srv, err := c.service(ctx, tok)
+	if err != nil {
+		return "", err
+	}
+
+	setting, err := srv.Settings.Get("timezone").Do()
+	if err != nil {
+		return "", err
+	}
+
+	return setting.Value, nil
+}
+
+// GetWorkingHours returns nil, Google Calendar keeps working hours to itself: they aren't among the settings its API
+// exposes.
+func (c GoogleCalendar) GetWorkingHours(_ context.Context, _ oauth2.Token) (*models.WorkingHours, error) {
+	return nil, nil
+}
+
+func (c GoogleCalendar) service(ctx context.Context, tok oauth2.Token) (*calendar.Service, error) {
+	// the traced transport is the base of the OAuth one, so API calls and token refreshes both get a span
+	client := c.config.Client(tracing.OAuthContext(ctx), &tok)
+
+	return calendar.NewService(ctx, option.WithHTTPClient(client))
+}
+
+func toEvent(item *calendar.Event) models.Event {
+	var attendees []string
+	// events without attendees only exist on the owner's calendar
+	responseStatus := models.ResponseAccepted
+	for _, attendee := range item.Attendees {
+		attendees = append(attendees, attendee.Email)
+		if attendee.Self {
+			responseStatus = attendee.ResponseStatus
 		}
-		event := &models.Event{
-			Title:     item.Summary,
-			Start:     item.Start.DateTime,
-			End:       item.End.DateTime,
-			Organizer: item.Organizer.Email,
-			Attendees: attendees,
+	}
+	return models.Event{
+		Id:             item.Id,
+		ICalUID:        item.ICalUID,
+		Title:          item.Summary,
+		Start:          eventTime(item.Start),
+		End:            eventTime(item.End),
+		Organizer:      item.Organizer.Email,
+		Attendees:      attendees,
+		ResponseStatus: responseStatus,
+		Status:         item.Status,
+		JoinURL:        joinURL(item),
+	}
+}
+
+// eventTime is the RFC3339 time of the event, or its date for all-day events. The end date of an all-day event is
+// exclusive.
+func eventTime(t *calendar.EventDateTime) string {
+	if t == nil {
+		return ""
+	}
+	if t.DateTime != "" {
+		return t.DateTime
+	}
+	return t.Date
+}
+
+// joinURL returns the video conference link of the event, Meet or a third party one added through conference data.
+func joinURL(item *calendar.Event) string {
+	if item.ConferenceData != nil {
+		for _, entryPoint := range item.ConferenceData.EntryPoints {
+			if entryPoint.EntryPointType == "video" {
+				return entryPoint.Uri
+			}
 		}
-		result = append(result, *event)
 	}
+	return item.HangoutLink
+}
 
-	return &result, events.NextPageToken, nil
+// observe records a provider call in the metrics, deferred with the address of the call's error result.
+func observe(provider string, operation string, start time.Time, err *error) {
+	outcome := metrics.OutcomeSuccess
+	if *err != nil {
+		outcome = errorOutcome(*err)
+	}
+	metrics.ObserveCalendarRequest(provider, operation, outcome, time.Since(start))
+}
+
+// googleQuotaReasons are the error reasons Google answers with when usage limits are hit.
+var googleQuotaReasons = map[string]bool{
+	"rateLimitExceeded": true, "userRateLimitExceeded": true, "quotaExceeded": true, "dailyLimitExceeded": true,
+}
+
+func errorOutcome(err error) string {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		if gErr.Code == http.StatusTooManyRequests {
+			return metrics.OutcomeQuota
+		}
+		for _, item := range gErr.Errors {
+			if gErr.Code == http.StatusForbidden && googleQuotaReasons[item.Reason] {
+				return metrics.OutcomeQuota
+			}
+		}
+	}
+	var grErr *graphError
+	if errors.As(err, &grErr) && grErr.Status == http.StatusTooManyRequests {
+		return metrics.OutcomeQuota
+	}
+	return metrics.OutcomeError
 }
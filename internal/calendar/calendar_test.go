@@ -0,0 +1,64 @@
+package calendar
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"manny-reminder/internal/metrics"
+	"net/http"
+	"testing"
+)
+
+func TestErrorOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		outcome string
+	}{
+		{"google rate limited", &googleapi.Error{Code: http.StatusTooManyRequests}, metrics.OutcomeQuota},
+		{"google quota exceeded", &googleapi.Error{
+			Code:   http.StatusForbidden,
+			Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}},
+		}, metrics.OutcomeQuota},
+		{"google forbidden", &googleapi.Error{
+			Code:   http.StatusForbidden,
+			Errors: []googleapi.ErrorItem{{Reason: "forbidden"}},
+		}, metrics.OutcomeError},
+		{"graph throttled", &graphError{Status: http.StatusTooManyRequests}, metrics.OutcomeQuota},
+		{"other", errors.New("connection reset"), metrics.OutcomeError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.outcome, errorOutcome(test.err))
+		})
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	meet := &calendar.Event{HangoutLink: "https://meet.google.com/abc-defg-hij"}
+	zoom := &calendar.Event{
+		HangoutLink: "https://meet.google.com/abc-defg-hij",
+		ConferenceData: &calendar.ConferenceData{EntryPoints: []*calendar.EntryPoint{
+			{EntryPointType: "phone", Uri: "tel:+1-555-0100"},
+			{EntryPointType: "video", Uri: "https://zoom.us/j/123"},
+		}},
+	}
+
+	assert.Equal(t, "https://meet.google.com/abc-defg-hij", joinURL(meet))
+	assert.Equal(t, "https://zoom.us/j/123", joinURL(zoom))
+	assert.Equal(t, "", joinURL(&calendar.Event{}))
+}
+
+func TestToEvent_AllDay(t *testing.T) {
+	item := &calendar.Event{
+		Id: "e1", Summary: "Holiday", Organizer: &calendar.EventOrganizer{Email: "ann@example.com"},
+		Start: &calendar.EventDateTime{Date: "2022-06-02"}, End: &calendar.EventDateTime{Date: "2022-06-03"},
+	}
+
+	event := toEvent(item)
+
+	assert.Equal(t, "2022-06-02", event.Start)
+	assert.Equal(t, "2022-06-03", event.End)
+	assert.True(t, event.AllDay())
+}
@@ -0,0 +1,356 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/microsoft"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	graphBaseUrl = "https://graph.microsoft.com/v1.0"
+	// Graph requires an upper bound for calendarView, Google doesn't, so we look one year ahead.
+	graphCalendarViewHorizon = 365 * 24 * time.Hour
+	graphDateTimeLayout      = "2006-01-02T15:04:05.9999999"
+	graphMaxPageSize         = 100
+)
+
+var MicrosoftScopes = []string{"offline_access", "User.Read", "Calendars.Read", "MailboxSettings.Read"}
+
+type MicrosoftCalendar struct {
+	config  *oauth2.Config
+	baseUrl string
+}
+
+func NewMicrosoftCalendar(c *oauth2.Config) *MicrosoftCalendar {
+	return &MicrosoftCalendar{config: c, baseUrl: graphBaseUrl}
+}
+
+// NewMicrosoftOAuthConfig builds the OAuth config for the Azure AD tenant, use "common" for multi-tenant apps.
+// MicrosoftScopes are requested when no scopes are given.
+func NewMicrosoftOAuthConfig(clientId, clientSecret, tenant, redirectUrl string, scopes []string) *oauth2.Config {
+	if len(scopes) == 0 {
+		scopes = MicrosoftScopes
+	}
+	return &oauth2.Config{
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		Endpoint:     microsoft.AzureADEndpoint(tenant),
+		RedirectURL:  redirectUrl,
+		Scopes:       scopes,
+	}
+}
+
+// MicrosoftDeviceAuthURL is the device authorization endpoint of the Azure AD tenant, for the device flow.
+func MicrosoftDeviceAuthURL(tenant string) string {
+	if tenant == "" {
+		tenant = "common"
+	}
+	return "https://login.microsoftonline.com/" + tenant + "/oauth2/v2.0/devicecode"
+}
+
+type graphEventsResponse struct {
+	Value    []graphEvent `json:"value"`
+	NextLink string       `json:"@odata.nextLink"`
+}
+
+type graphEvent struct {
+	Id          string           `json:"id"`
+	ICalUId     string           `json:"iCalUId"`
+	IsCancelled bool             `json:"isCancelled"`
+	IsAllDay    bool             `json:"isAllDay"`
+	Subject     string           `json:"subject"`
+	Start       graphDateTime    `json:"start"`
+	End         graphDateTime    `json:"end"`
+	Organizer   graphRecipient   `json:"organizer"`
+	Attendees   []graphRecipient `json:"attendees"`
+	// ResponseStatus is the response of the signed-in user
+	ResponseStatus struct {
+		Response string `json:"response"`
+	} `json:"responseStatus"`
+	// OnlineMeeting is set for Teams and other online meetings
+	OnlineMeeting *struct {
+		JoinUrl string `json:"joinUrl"`
+	} `json:"onlineMeeting"`
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphRecipient struct {
+	EmailAddress struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+}
+
+type graphMailboxSettings struct {
+	TimeZone     string `json:"timeZone"`
+	WorkingHours struct {
+		DaysOfWeek []string `json:"daysOfWeek"`
+		StartTime  string   `json:"startTime"`
+		EndTime    string   `json:"endTime"`
+	} `json:"workingHours"`
+}
+
+var graphDaysOfWeek = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+type graphUser struct {
+	Mail              string `json:"mail"`
+	UserPrincipalName string `json:"userPrincipalName"`
+}
+
+type graphErrorResponse struct {
+	Error graphErrorDetails `json:"error"`
+}
+
+type graphErrorDetails struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// graphError is returned for non-successful Graph responses, 429 means the request was throttled.
+type graphError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *graphError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("graph request failed with status %d", e.Status)
+	}
+	return fmt.Sprintf("graph request failed: %s: %s", e.Code, e.Message)
+}
+
+func (c MicrosoftCalendar) GetEventsForUser(ctx context.Context, tok oauth2.Token, pageToken string, size int) (_ *models.Events, _ string, err error) {
+	defer observe(models.ProviderMicrosoft, "events.list", time.Now(), &err)
+
+	query := pageToken
+	if query == "" {
+		now := time.Now().UTC()
+		query = calendarViewQuery(now, now.Add(graphCalendarViewHorizon), size)
+	}
+
+	return c.getCalendarView(ctx, tok, query)
+}
+
+// GetEventsInRange returns every event overlapping the range, following all pages, cancelled meetings included.
+func (c MicrosoftCalendar) GetEventsInRange(ctx context.Context, tok oauth2.Token, from time.Time, to time.Time) (_ *models.Events, err error) {
+	defer observe(models.ProviderMicrosoft, "events.range", time.Now(), &err)
+
+	var result models.Events
+	query := calendarViewQuery(from, to, graphMaxPageSize)
+	for query != "" {
+		events, npt, err := c.getCalendarView(ctx, tok, query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *events...)
+		query = npt
+	}
+
+	return &result, nil
+}
+
+func (c MicrosoftCalendar) getCalendarView(ctx context.Context, tok oauth2.Token, query string) (*models.Events, string, error) {
+	var events graphEventsResponse
+	err := c.get(ctx, tok, "/me/calendarView?"+query, &events)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var result models.Events
+	for _, item := range events.Value {
+		event, err := item.toEvent()
+		if err != nil {
+			return nil, "", err
+		}
+		result = append(result, event)
+	}
+
+	return &result, nextPageToken(events.NextLink), nil
+}
+
+func calendarViewQuery(from time.Time, to time.Time, size int) string {
+	q := url.Values{}
+	q.Set("startDateTime", from.UTC().Format(time.RFC3339))
+	q.Set("endDateTime", to.UTC().Format(time.RFC3339))
+	q.Set("$top", strconv.Itoa(size))
+	q.Set("$orderby", "start/dateTime")
+	return q.Encode()
+}
+
+// GetEmail returns the mail of the signed-in user, falling back to the principal name for accounts without a mailbox.
+func (c MicrosoftCalendar) GetEmail(ctx context.Context, tok oauth2.Token) (_ string, err error) {
+	defer observe(models.ProviderMicrosoft, "email", time.Now(), &err)
+
+	var user graphUser
+	err = c.get(ctx, tok, "/me", &user)
+	if err != nil {
+		return "", err
+	}
+	if user.Mail != "" {
+		return user.Mail, nil
+	}
+	return user.UserPrincipalName, nil
+}
+
+// GetTimeZone returns the time zone of the mailbox. Outlook names zones the Windows way, e.g. "Pacific Standard Time",
+// unless the user picked an IANA one.
+func (c MicrosoftCalendar) GetTimeZone(ctx context.Context, tok oauth2.Token) (_ string, err error) {
+	defer observe(models.ProviderMicrosoft, "timezone", time.Now(), &err)
+
+	var settings graphMailboxSettings
+	err = c.get(ctx, tok, "/me/mailboxSettings", &settings)
+	if err != nil {
+		return "", err
+	}
+	return settings.TimeZone, nil
+}
+
+// GetWorkingHours returns the working hours set in Outlook, nil when there are none. They are in the time zone of the
+// mailbox.
+func (c MicrosoftCalendar) GetWorkingHours(ctx context.Context, tok oauth2.Token) (_ *models.WorkingHours, err error) {
+	defer observe(models.ProviderMicrosoft, "workinghours", time.Now(), &err)
+
+	var settings graphMailboxSettings
+	err = c.get(ctx, tok, "/me/mailboxSettings", &settings)
+	if err != nil {
+		return nil, err
+	}
+	w := settings.WorkingHours
+	if len(w.DaysOfWeek) == 0 || len(w.StartTime) < 5 || len(w.EndTime) < 5 {
+		return nil, nil
+	}
+	// times come as 08:00:00.0000000
+	hours := &models.WorkingHours{Start: w.StartTime[:5], End: w.EndTime[:5], Days: []time.Weekday{}}
+	for _, day := range w.DaysOfWeek {
+		if weekday, ok := graphDaysOfWeek[day]; ok {
+			hours.Days = append(hours.Days, weekday)
+		}
+	}
+	return hours, nil
+}
+
+func (c MicrosoftCalendar) get(ctx context.Context, tok oauth2.Token, path string, body interface{}) error {
+	client := c.config.Client(tracing.OAuthContext(ctx), &tok)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseUrl+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var gErr graphErrorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&gErr)
+		return &graphError{Status: resp.StatusCode, Code: gErr.Error.Code, Message: gErr.Error.Message}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(body)
+}
+
+func (e graphEvent) toEvent() (models.Event, error) {
+	start, err := e.Start.format(e.IsAllDay)
+	if err != nil {
+		return models.Event{}, err
+	}
+	end, err := e.End.format(e.IsAllDay)
+	if err != nil {
+		return models.Event{}, err
+	}
+	var attendees []string
+	for _, attendee := range e.Attendees {
+		attendees = append(attendees, attendee.EmailAddress.Address)
+	}
+	status := models.EventConfirmed
+	if e.IsCancelled {
+		status = models.EventCancelled
+	}
+	var joinURL string
+	if e.OnlineMeeting != nil {
+		joinURL = e.OnlineMeeting.JoinUrl
+	}
+	return models.Event{
+		Id:             e.Id,
+		ICalUID:        e.ICalUId,
+		Title:          e.Subject,
+		Start:          start,
+		End:            end,
+		Organizer:      e.Organizer.EmailAddress.Address,
+		Attendees:      attendees,
+		ResponseStatus: graphResponseStatus(e.ResponseStatus.Response),
+		Status:         status,
+		JoinURL:        joinURL,
+	}, nil
+}
+
+// graphResponseStatus maps Graph responses onto the Google names used by models.Event.
+func graphResponseStatus(response string) string {
+	switch response {
+	case "organizer", "accepted":
+		return models.ResponseAccepted
+	case "tentativelyAccepted":
+		return models.ResponseTentative
+	case "declined":
+		return models.ResponseDeclined
+	default:
+		return models.ResponseNeedsAction
+	}
+}
+
+// format converts Graph's zone-less date time into RFC3339, the format GoogleCalendar returns. All-day events keep
+// their date only, they start at midnight wherever the user is.
+func (d graphDateTime) format(allDay bool) (string, error) {
+	if allDay {
+		t, err := time.Parse(graphDateTimeLayout, d.DateTime)
+		if err != nil {
+			return "", err
+		}
+		return t.Format("2006-01-02"), nil
+	}
+	loc := time.UTC
+	if d.TimeZone != "" {
+		l, err := time.LoadLocation(d.TimeZone)
+		if err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(graphDateTimeLayout, d.DateTime, loc)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// nextPageToken keeps only the query of the next link, so a page token can't point requests outside Graph.
+func nextPageToken(nextLink string) string {
+	if nextLink == "" {
+		return ""
+	}
+	i := strings.Index(nextLink, "?")
+	if i < 0 {
+		return ""
+	}
+	return nextLink[i+1:]
+}
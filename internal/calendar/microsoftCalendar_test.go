@@ -0,0 +1,185 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"manny-reminder/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const graphEventsPage = `{
+  "value": [
+    {
+      "id": "AAMkAGI2TGuLAAA=",
+      "iCalUId": "040000008200E00074C5B7101A82E008",
+      "subject": "Standup",
+      "start": {"dateTime": "2022-06-01T09:00:00.0000000", "timeZone": "UTC"},
+      "end": {"dateTime": "2022-06-01T09:15:00.0000000", "timeZone": "UTC"},
+      "organizer": {"emailAddress": {"name": "Ann", "address": "ann@example.com"}},
+      "attendees": [
+        {"emailAddress": {"name": "Bob", "address": "bob@example.com"}},
+        {"emailAddress": {"name": "Eve", "address": "eve@example.com"}}
+      ],
+      "onlineMeeting": {"joinUrl": "https://teams.microsoft.com/l/meetup-join/abc"}
+    }
+  ],
+  "@odata.nextLink": "%s/me/calendarView?startDateTime=2022-06-01T00%%3A00%%3A00Z&$skiptoken=abc"
+}`
+
+func TestMicrosoftCalendar_GetEventsForUser(t *testing.T) {
+	var authHeader, preferHeader, top string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/me/calendarView", r.URL.Path)
+		authHeader = r.Header.Get("Authorization")
+		preferHeader = r.Header.Get("Prefer")
+		top = r.URL.Query().Get("$top")
+		_, _ = fmt.Fprintf(w, graphEventsPage, "https://graph.microsoft.com/v1.0")
+	}))
+	defer srv.Close()
+	c := newTestMicrosoftCalendar(srv.URL)
+
+	events, npt, err := c.GetEventsForUser(context.Background(), testToken(), "", 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer graph-token", authHeader)
+	assert.Equal(t, `outlook.timezone="UTC"`, preferHeader)
+	assert.Equal(t, "5", top)
+	assert.Exactly(t, 1, len(*events))
+	event := (*events)[0]
+	assert.Equal(t, "AAMkAGI2TGuLAAA=", event.Id)
+	assert.Equal(t, models.EventConfirmed, event.Status)
+	assert.Equal(t, "040000008200E00074C5B7101A82E008", event.ICalUID)
+	assert.Equal(t, "Standup", event.Title)
+	assert.Equal(t, "2022-06-01T09:00:00Z", event.Start)
+	assert.Equal(t, "2022-06-01T09:15:00Z", event.End)
+	assert.Equal(t, "ann@example.com", event.Organizer)
+	assert.Equal(t, []string{"bob@example.com", "eve@example.com"}, event.Attendees)
+	assert.Equal(t, "https://teams.microsoft.com/l/meetup-join/abc", event.JoinURL)
+	assert.Equal(t, "startDateTime=2022-06-01T00%3A00%3A00Z&$skiptoken=abc", npt)
+}
+
+func TestMicrosoftCalendar_GetEventsForUser_PageToken(t *testing.T) {
+	var skipToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		skipToken = r.URL.Query().Get("$skiptoken")
+		_, _ = fmt.Fprint(w, `{"value": []}`)
+	}))
+	defer srv.Close()
+	c := newTestMicrosoftCalendar(srv.URL)
+
+	events, npt, err := c.GetEventsForUser(context.Background(), testToken(), "$skiptoken=abc", 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", skipToken)
+	assert.Empty(t, *events)
+	assert.Equal(t, "", npt)
+}
+
+func TestMicrosoftCalendar_GetEventsForUser_GraphError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"error": {"code": "InvalidAuthenticationToken", "message": "Access token has expired."}}`)
+	}))
+	defer srv.Close()
+	c := newTestMicrosoftCalendar(srv.URL)
+
+	events, _, err := c.GetEventsForUser(context.Background(), testToken(), "", 5)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "InvalidAuthenticationToken")
+	assert.Nil(t, events)
+}
+
+func TestMicrosoftCalendar_GetEmail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/me", r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"mail": null, "userPrincipalName": "ann@contoso.onmicrosoft.com"}`)
+	}))
+	defer srv.Close()
+	c := newTestMicrosoftCalendar(srv.URL)
+
+	email, err := c.GetEmail(context.Background(), testToken())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ann@contoso.onmicrosoft.com", email)
+}
+
+func TestMicrosoftCalendar_GetTimeZone(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/me/mailboxSettings", r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"timeZone": "Europe/Paris", "language": {"locale": "fr-FR"}}`)
+	}))
+	defer srv.Close()
+	c := newTestMicrosoftCalendar(srv.URL)
+
+	timeZone, err := c.GetTimeZone(context.Background(), testToken())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Europe/Paris", timeZone)
+}
+
+func TestMicrosoftCalendar_GetWorkingHours(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/me/mailboxSettings", r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"timeZone": "Europe/Paris", "workingHours": {"daysOfWeek": ["monday", "tuesday", "friday"],
+"startTime": "08:30:00.0000000", "endTime": "17:00:00.0000000", "timeZone": {"name": "Romance Standard Time"}}}`)
+	}))
+	defer srv.Close()
+	c := newTestMicrosoftCalendar(srv.URL)
+
+	hours, err := c.GetWorkingHours(context.Background(), testToken())
+
+	assert.Nil(t, err)
+	assert.Equal(t, &models.WorkingHours{Start: "08:30", End: "17:00", Days: []time.Weekday{time.Monday, time.Tuesday, time.Friday}}, hours)
+}
+
+func TestGraphEvent_AllDay(t *testing.T) {
+	e := graphEvent{
+		IsAllDay: true,
+		Start:    graphDateTime{DateTime: "2022-06-02T00:00:00.0000000", TimeZone: "UTC"},
+		End:      graphDateTime{DateTime: "2022-06-03T00:00:00.0000000", TimeZone: "UTC"},
+	}
+
+	event, err := e.toEvent()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "2022-06-02", event.Start)
+	assert.Equal(t, "2022-06-03", event.End)
+}
+
+func TestMicrosoftCalendar_GetEventsInRange_DeadlineStopsRequest(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	c := newTestMicrosoftCalendar(srv.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	events, err := c.GetEventsInRange(ctx, testToken(), time.Now(), time.Now().Add(time.Hour))
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, events)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func newTestMicrosoftCalendar(url string) *MicrosoftCalendar {
+	c := NewMicrosoftCalendar(NewMicrosoftOAuthConfig("client", "secret", "common", "", nil))
+	c.baseUrl = url
+	return c
+}
+
+func testToken() oauth2.Token {
+	return oauth2.Token{AccessToken: "graph-token", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}
+}
@@ -0,0 +1,752 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service. Values are read, from lowest to highest precedence, from the
+// defaults, the optional YAML file, the environment and the command line flags.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Google    GoogleConfig    `yaml:"google"`
+	Microsoft MicrosoftConfig `yaml:"microsoft"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Reminders RemindersConfig `yaml:"reminders"`
+	Slack     SlackConfig     `yaml:"slack"`
+	Telegram  TelegramConfig  `yaml:"telegram"`
+	WebPush   WebPushConfig   `yaml:"webPush"`
+	SMS       SMSConfig       `yaml:"sms"`
+	Email     EmailConfig     `yaml:"email"`
+	Templates TemplatesConfig `yaml:"templates"`
+	// Command is the admin command and its arguments, what's left of the command line after the flags. The server
+	// runs when it's empty.
+	Command []string `yaml:"-"`
+}
+
+type ServerConfig struct {
+	BindAddress     string        `yaml:"bindAddress"`
+	TLSCertFile     string        `yaml:"tlsCertFile"`
+	TLSKeyFile      string        `yaml:"tlsKeyFile"`
+	ReadTimeout     time.Duration `yaml:"readTimeout"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout"`
+	IdleTimeout     time.Duration `yaml:"idleTimeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// DrainDelay is how long the server keeps serving once it reports not ready, so load balancers stop sending it
+	// requests before it closes its listener
+	DrainDelay time.Duration `yaml:"drainDelay"`
+	// CursorSecret signs pagination cursors, without it a random key is used and cursors break on restart
+	CursorSecret string `yaml:"cursorSecret"`
+	// StateSecret signs the OAuth state of account links, without it a random key is used and links in progress
+	// fail on restart
+	StateSecret string `yaml:"stateSecret"`
+	// PublicURL is where users reach the server, links sent to them start with it
+	PublicURL string `yaml:"publicUrl"`
+}
+
+// DatabaseConfig either holds a full DSN or the parts to build one from. SSLMode defaults to disable, set require or
+// verify-full when the database isn't on a local network.
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslMode"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+}
+
+// GoogleConfig holds the OAuth client, either as a path to the credentials.json downloaded from the Google
+// console or as its content inline.
+type GoogleConfig struct {
+	CredentialsFile string   `yaml:"credentialsFile"`
+	CredentialsJSON string   `yaml:"credentialsJson"`
+	RedirectURL     string   `yaml:"redirectUrl"`
+	Scopes          []string `yaml:"scopes"`
+	// DeviceAuthURL is the device authorization endpoint of the device flow, linking accounts from the command line,
+	// off when empty. Google only grants a few scopes to the device flow, not the Calendar ones, and only to
+	// clients of the "TVs and Limited Input devices" type.
+	DeviceAuthURL string `yaml:"deviceAuthUrl"`
+}
+
+// MicrosoftConfig enables the Microsoft provider when the client id is set.
+type MicrosoftConfig struct {
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	Tenant       string   `yaml:"tenant"`
+	RedirectURL  string   `yaml:"redirectUrl"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// SlackConfig sets up the slack reminders channel. Reminders go to the DM of the Slack user with the email of the
+// user, or to Channel when set. With a signing secret, the buttons of reminders are handled in Slack through the
+// interactivity endpoint instead of opening the action links.
+type SlackConfig struct {
+	BotToken      string `yaml:"botToken"`
+	Channel       string `yaml:"channel"`
+	SigningSecret string `yaml:"signingSecret"`
+	APIURL        string `yaml:"apiUrl"`
+}
+
+// TelegramConfig sets up the telegram reminders channel. Users link their chat by sending /start with a link code
+// to the bot, which long polls for updates when Polling is set. Only one replica may poll a bot.
+type TelegramConfig struct {
+	BotToken    string        `yaml:"botToken"`
+	BotName     string        `yaml:"botName"`
+	APIURL      string        `yaml:"apiUrl"`
+	Polling     bool          `yaml:"polling"`
+	PollTimeout time.Duration `yaml:"pollTimeout"`
+	LinkCodeTTL time.Duration `yaml:"linkCodeTtl"`
+}
+
+// WebPushConfig sets up the webpush reminders channel. Subject is the mailto: or https: contact push services reach
+// the operator at. Without PrivateKey the VAPID key is generated once and kept in the database.
+type WebPushConfig struct {
+	Subject    string        `yaml:"subject"`
+	PrivateKey string        `yaml:"privateKey"`
+	TTL        time.Duration `yaml:"ttl"`
+}
+
+// SMSConfig sets up the sms reminders channel over a Twilio compatible API. Users verify their phone number with a
+// code sent to it, and get at most DailyCap messages a day, codes included.
+type SMSConfig struct {
+	APIURL     string        `yaml:"apiUrl"`
+	AccountSID string        `yaml:"accountSid"`
+	AuthToken  string        `yaml:"authToken"`
+	From       string        `yaml:"from"`
+	DailyCap   int           `yaml:"dailyCap"`
+	CodeTTL    time.Duration `yaml:"codeTtl"`
+}
+
+// EmailConfig sets up the email reminders channel, sending to the email of the user through the SMTP server at
+// Host:Port. Username and Password log in with PLAIN auth, which is only done over TLS or to localhost.
+type EmailConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// TemplatesConfig locates the message templates on disk, laid out as <channel>/<locale>/<kind>.<format>.tmpl.
+// Templates saved in the database win over them, and the built-in ones in DefaultLocale are the last resort.
+type TemplatesConfig struct {
+	Dir           string `yaml:"dir"`
+	DefaultLocale string `yaml:"defaultLocale"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// TracingConfig selects where spans go: nowhere, stdout for local testing, or an OTLP/HTTP collector at the
+// host:port endpoint.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// RemindersConfig drives the scheduler: every SyncInterval the events starting within Horizon are synced and their
+// reminders scheduled at each offset before the start on each channel, every DispatchInterval the due ones are sent.
+type RemindersConfig struct {
+	Offsets          []time.Duration `yaml:"offsets"`
+	Channels         []string        `yaml:"channels"`
+	Horizon          time.Duration   `yaml:"horizon"`
+	SyncInterval     time.Duration   `yaml:"syncInterval"`
+	DispatchInterval time.Duration   `yaml:"dispatchInterval"`
+	BatchSize        int             `yaml:"batchSize"`
+	ClaimLease       time.Duration   `yaml:"claimLease"`
+	MaxAttempts      int             `yaml:"maxAttempts"`
+	RetryDelay       time.Duration   `yaml:"retryDelay"`
+	// NotifyChanges tells users when an event they have reminders for moves or is cancelled
+	NotifyChanges bool `yaml:"notifyChanges"`
+	// SnoozeOptions are offered as action links on every reminder, next to marking it done
+	SnoozeOptions []time.Duration `yaml:"snoozeOptions"`
+	// ActionSecret signs the action links, without it a random key is used and links break on restart
+	ActionSecret string `yaml:"actionSecret"`
+	// Escalation replaces the reminders of important events with a chain over several channels
+	Escalation EscalationConfig `yaml:"escalation"`
+}
+
+// EscalationConfig flags events as important when their title holds one of Keywords, whatever the case, their
+// organizer is one of Organizers or they are on one of Calendars, named by the email of the connected account.
+// Their reminders go through Steps in order, each step only sent while the user acknowledged none before it.
+type EscalationConfig struct {
+	Keywords   []string         `yaml:"keywords"`
+	Organizers []string         `yaml:"organizers"`
+	Calendars  []string         `yaml:"calendars"`
+	Steps      []EscalationStep `yaml:"steps"`
+}
+
+// EscalationStep sends the reminder on Channel at Offset before the start of the event.
+type EscalationStep struct {
+	Channel string        `yaml:"channel"`
+	Offset  time.Duration `yaml:"offset"`
+}
+
+// Enabled tells whether any event can be flagged important.
+func (c EscalationConfig) Enabled() bool {
+	return len(c.Steps) > 0 && len(c.Keywords)+len(c.Organizers)+len(c.Calendars) > 0
+}
+
+// AllChannels lists the channels reminders go out on, plainly or through escalations.
+func (c RemindersConfig) AllChannels() []string {
+	channels := append([]string(nil), c.Channels...)
+	if !c.Escalation.Enabled() {
+		return channels
+	}
+	for _, step := range c.Escalation.Steps {
+		if !contains(channels, step.Channel) {
+			channels = append(channels, step.Channel)
+		}
+	}
+	return channels
+}
+
+func (c RemindersConfig) HasChannel(channel string) bool {
+	return contains(c.AllChannels(), channel)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sslModes are the modes lib/pq supports.
+var sslModes = map[string]bool{"disable": true, "require": true, "verify-ca": true, "verify-full": true}
+
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+var tracingExporters = map[string]bool{"none": true, "stdout": true, "otlp": true}
+
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			BindAddress:     ":8080",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     120 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Google: GoogleConfig{
+			CredentialsFile: "credentials.json",
+			Scopes:          []string{"https://www.googleapis.com/auth/calendar.readonly"},
+		},
+		Microsoft: MicrosoftConfig{
+			Tenant: "common",
+		},
+		Slack: SlackConfig{
+			APIURL: "https://slack.com/api/",
+		},
+		Telegram: TelegramConfig{
+			APIURL:      "https://api.telegram.org",
+			Polling:     true,
+			PollTimeout: 30 * time.Second,
+			LinkCodeTTL: 15 * time.Minute,
+		},
+		WebPush: WebPushConfig{
+			TTL: 10 * time.Minute,
+		},
+		SMS: SMSConfig{
+			APIURL:   "https://api.twilio.com",
+			DailyCap: 10,
+			CodeTTL:  10 * time.Minute,
+		},
+		Email: EmailConfig{
+			Port: 587,
+		},
+		Templates: TemplatesConfig{
+			DefaultLocale: "en",
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		Reminders: RemindersConfig{
+			Offsets:          []time.Duration{10 * time.Minute},
+			Channels:         []string{"log"},
+			Horizon:          24 * time.Hour,
+			SyncInterval:     5 * time.Minute,
+			DispatchInterval: 15 * time.Second,
+			BatchSize:        50,
+			ClaimLease:       2 * time.Minute,
+			MaxAttempts:      3,
+			RetryDelay:       time.Minute,
+			NotifyChanges:    true,
+			SnoozeOptions:    []time.Duration{5 * time.Minute, 10 * time.Minute, 30 * time.Minute},
+			Escalation: EscalationConfig{
+				Steps: []EscalationStep{
+					{Channel: "email", Offset: time.Hour},
+					{Channel: "slack", Offset: 10 * time.Minute},
+					{Channel: "sms", Offset: 2 * time.Minute},
+				},
+			},
+		},
+	}
+}
+
+// Load builds the config from the command line arguments (without the program name), the environment and the
+// YAML file given by -config or CONFIG_FILE, then validates it.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("manny-reminder", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	bindAddress := fs.String("bind-address", "", "address the server listens on")
+	tlsCertFile := fs.String("tls-cert-file", "", "TLS certificate, serves HTTPS when set with -tls-key-file")
+	tlsKeyFile := fs.String("tls-key-file", "", "TLS private key")
+	dsn := fs.String("db-dsn", "", "Postgres connection string")
+	sslMode := fs.String("db-ssl-mode", "", "Postgres SSL mode when the DSN is built from parts")
+	credentialsFile := fs.String("google-credentials-file", "", "path to the Google OAuth credentials.json")
+	logLevel := fs.String("log-level", "", "minimum level logged: debug, info, warn or error")
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Default()
+	if *configFile != "" {
+		err = c.loadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = c.loadEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "bind-address":
+			c.Server.BindAddress = *bindAddress
+		case "tls-cert-file":
+			c.Server.TLSCertFile = *tlsCertFile
+		case "tls-key-file":
+			c.Server.TLSKeyFile = *tlsKeyFile
+		case "db-dsn":
+			c.Database.DSN = *dsn
+		case "db-ssl-mode":
+			c.Database.SSLMode = *sslMode
+		case "google-credentials-file":
+			c.Google.CredentialsFile = *credentialsFile
+		case "log-level":
+			c.Log.Level = *logLevel
+		}
+	})
+
+	err = c.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Command = fs.Args()
+	return c, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+	err = yaml.Unmarshal(b, c)
+	if err != nil {
+		return fmt.Errorf("unable to parse config file: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	e := envReader{}
+
+	e.string("BIND_ADDRESS", &c.Server.BindAddress)
+	e.string("TLS_CERT_FILE", &c.Server.TLSCertFile)
+	e.string("TLS_KEY_FILE", &c.Server.TLSKeyFile)
+	e.duration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	e.duration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	e.duration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	e.duration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	e.duration("SERVER_DRAIN_DELAY", &c.Server.DrainDelay)
+	e.string("CURSOR_SECRET", &c.Server.CursorSecret)
+	e.string("STATE_SECRET", &c.Server.StateSecret)
+	e.string("PUBLIC_URL", &c.Server.PublicURL)
+
+	e.string("PGSQL_DSN", &c.Database.DSN)
+	e.string("PGSQL_HOST", &c.Database.Host)
+	e.int("PGSQL_PORT", &c.Database.Port)
+	e.string("PGSQL_USER", &c.Database.User)
+	e.string("PGSQL_PASSWORD", &c.Database.Password)
+	e.string("PGSQL_DB", &c.Database.Name)
+	e.string("PGSQL_SSLMODE", &c.Database.SSLMode)
+	e.int("PGSQL_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	e.int("PGSQL_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	e.duration("PGSQL_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+
+	e.string("GOOGLE_CREDENTIALS_FILE", &c.Google.CredentialsFile)
+	e.string("GOOGLE_CREDENTIALS_JSON", &c.Google.CredentialsJSON)
+	e.string("GOOGLE_REDIRECT_URL", &c.Google.RedirectURL)
+	e.list("GOOGLE_SCOPES", &c.Google.Scopes)
+	e.string("GOOGLE_DEVICE_AUTH_URL", &c.Google.DeviceAuthURL)
+
+	e.string("MS_CLIENT_ID", &c.Microsoft.ClientID)
+	e.string("MS_CLIENT_SECRET", &c.Microsoft.ClientSecret)
+	e.string("MS_TENANT", &c.Microsoft.Tenant)
+	e.string("MS_REDIRECT_URL", &c.Microsoft.RedirectURL)
+	e.list("MS_SCOPES", &c.Microsoft.Scopes)
+
+	e.string("LOG_LEVEL", &c.Log.Level)
+
+	e.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	e.string("TRACING_OTLP_ENDPOINT", &c.Tracing.Endpoint)
+	e.bool("TRACING_OTLP_INSECURE", &c.Tracing.Insecure)
+	e.float("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	e.durations("REMINDER_OFFSETS", &c.Reminders.Offsets)
+	e.list("REMINDER_CHANNELS", &c.Reminders.Channels)
+	e.duration("REMINDER_HORIZON", &c.Reminders.Horizon)
+	e.duration("REMINDER_SYNC_INTERVAL", &c.Reminders.SyncInterval)
+	e.duration("REMINDER_DISPATCH_INTERVAL", &c.Reminders.DispatchInterval)
+	e.int("REMINDER_BATCH_SIZE", &c.Reminders.BatchSize)
+	e.duration("REMINDER_CLAIM_LEASE", &c.Reminders.ClaimLease)
+	e.int("REMINDER_MAX_ATTEMPTS", &c.Reminders.MaxAttempts)
+	e.duration("REMINDER_RETRY_DELAY", &c.Reminders.RetryDelay)
+	e.bool("REMINDER_NOTIFY_CHANGES", &c.Reminders.NotifyChanges)
+	e.durations("REMINDER_SNOOZE_OPTIONS", &c.Reminders.SnoozeOptions)
+	e.string("REMINDER_ACTION_SECRET", &c.Reminders.ActionSecret)
+	e.list("REMINDER_ESCALATION_KEYWORDS", &c.Reminders.Escalation.Keywords)
+	e.list("REMINDER_ESCALATION_ORGANIZERS", &c.Reminders.Escalation.Organizers)
+	e.list("REMINDER_ESCALATION_CALENDARS", &c.Reminders.Escalation.Calendars)
+	e.steps("REMINDER_ESCALATION_STEPS", &c.Reminders.Escalation.Steps)
+
+	e.string("SLACK_BOT_TOKEN", &c.Slack.BotToken)
+	e.string("SLACK_CHANNEL", &c.Slack.Channel)
+	e.string("SLACK_SIGNING_SECRET", &c.Slack.SigningSecret)
+	e.string("SLACK_API_URL", &c.Slack.APIURL)
+
+	e.string("TELEGRAM_BOT_TOKEN", &c.Telegram.BotToken)
+	e.string("TELEGRAM_BOT_NAME", &c.Telegram.BotName)
+	e.string("TELEGRAM_API_URL", &c.Telegram.APIURL)
+	e.bool("TELEGRAM_POLLING", &c.Telegram.Polling)
+	e.duration("TELEGRAM_POLL_TIMEOUT", &c.Telegram.PollTimeout)
+	e.duration("TELEGRAM_LINK_CODE_TTL", &c.Telegram.LinkCodeTTL)
+
+	e.string("WEBPUSH_SUBJECT", &c.WebPush.Subject)
+	e.string("WEBPUSH_PRIVATE_KEY", &c.WebPush.PrivateKey)
+	e.duration("WEBPUSH_TTL", &c.WebPush.TTL)
+
+	e.string("SMS_API_URL", &c.SMS.APIURL)
+	e.string("SMS_ACCOUNT_SID", &c.SMS.AccountSID)
+	e.string("SMS_AUTH_TOKEN", &c.SMS.AuthToken)
+	e.string("SMS_FROM", &c.SMS.From)
+	e.int("SMS_DAILY_CAP", &c.SMS.DailyCap)
+	e.duration("SMS_CODE_TTL", &c.SMS.CodeTTL)
+
+	e.string("EMAIL_SMTP_HOST", &c.Email.Host)
+	e.int("EMAIL_SMTP_PORT", &c.Email.Port)
+	e.string("EMAIL_SMTP_USERNAME", &c.Email.Username)
+	e.string("EMAIL_SMTP_PASSWORD", &c.Email.Password)
+	e.string("EMAIL_FROM", &c.Email.From)
+
+	e.string("TEMPLATES_DIR", &c.Templates.Dir)
+	e.string("TEMPLATES_DEFAULT_LOCALE", &c.Templates.DefaultLocale)
+
+	return e.err
+}
+
+func (c *Config) Validate() error {
+	var errs []string
+	if c.Server.BindAddress == "" {
+		errs = append(errs, "server bind address is required")
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, "TLS needs both a certificate and a key file")
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 || c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, "server timeouts must be positive")
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, "server drain delay can't be negative")
+	}
+	if c.Server.PublicURL != "" {
+		u, err := url.Parse(c.Server.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, "server public URL must be an absolute http or https URL")
+		}
+	}
+
+	if c.Database.DSN == "" {
+		if c.Database.Host == "" || c.Database.Name == "" {
+			errs = append(errs, "database DSN or host and name are required")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			errs = append(errs, "database port is invalid")
+		}
+		if !sslModes[c.Database.SSLMode] {
+			errs = append(errs, fmt.Sprintf("database SSL mode %q is invalid", c.Database.SSLMode))
+		}
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 || c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, "database pool sizes and lifetime can't be negative")
+	}
+
+	if c.Google.CredentialsFile == "" && c.Google.CredentialsJSON == "" {
+		errs = append(errs, "google credentials file or JSON is required")
+	}
+	if len(c.Google.Scopes) == 0 {
+		errs = append(errs, "google scopes are required")
+	}
+
+	if c.Microsoft.ClientID != "" && c.Microsoft.Tenant == "" {
+		errs = append(errs, "microsoft tenant is required")
+	}
+
+	if !logLevels[c.Log.Level] {
+		errs = append(errs, fmt.Sprintf("log level %q is invalid", c.Log.Level))
+	}
+
+	if !tracingExporters[c.Tracing.Exporter] {
+		errs = append(errs, fmt.Sprintf("tracing exporter %q is invalid", c.Tracing.Exporter))
+	}
+	if c.Tracing.Exporter == "otlp" && c.Tracing.Endpoint == "" {
+		errs = append(errs, "tracing OTLP endpoint is required")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, "tracing sample ratio must be between 0 and 1")
+	}
+
+	if len(c.Reminders.Channels) == 0 {
+		errs = append(errs, "at least one reminder channel is required")
+	}
+	for _, offset := range c.Reminders.Offsets {
+		if offset < 0 {
+			errs = append(errs, "reminder offsets can't be negative")
+			break
+		}
+	}
+	for _, option := range c.Reminders.SnoozeOptions {
+		if option < time.Minute || option > 4*time.Hour {
+			errs = append(errs, "reminder snooze options must be between a minute and 4 hours")
+			break
+		}
+	}
+	if c.Reminders.Horizon <= 0 || c.Reminders.SyncInterval <= 0 || c.Reminders.DispatchInterval <= 0 || c.Reminders.ClaimLease <= 0 {
+		errs = append(errs, "reminder horizon, intervals and claim lease must be positive")
+	}
+	if c.Reminders.HasChannel("slack") && c.Slack.BotToken == "" {
+		errs = append(errs, "slack bot token is required by the slack reminder channel")
+	}
+	if c.Reminders.HasChannel("telegram") {
+		if c.Telegram.BotToken == "" || c.Telegram.BotName == "" {
+			errs = append(errs, "telegram bot token and name are required by the telegram reminder channel")
+		}
+		if c.Telegram.PollTimeout <= 0 || c.Telegram.LinkCodeTTL <= 0 {
+			errs = append(errs, "telegram poll timeout and link code TTL must be positive")
+		}
+	}
+	if c.Reminders.HasChannel("webpush") {
+		if !strings.HasPrefix(c.WebPush.Subject, "mailto:") && !strings.HasPrefix(c.WebPush.Subject, "https://") {
+			errs = append(errs, "web push subject must be a mailto: or https: URL")
+		}
+		if c.WebPush.TTL <= 0 {
+			errs = append(errs, "web push TTL must be positive")
+		}
+	}
+	if c.Reminders.HasChannel("sms") {
+		if c.SMS.AccountSID == "" || c.SMS.AuthToken == "" || c.SMS.From == "" {
+			errs = append(errs, "sms account SID, auth token and sender are required by the sms reminder channel")
+		}
+		if c.SMS.DailyCap <= 0 || c.SMS.CodeTTL <= 0 {
+			errs = append(errs, "sms daily cap and code TTL must be positive")
+		}
+	}
+	if c.Reminders.HasChannel("email") {
+		if c.Email.Host == "" || c.Email.From == "" {
+			errs = append(errs, "email SMTP host and sender are required by the email reminder channel")
+		}
+		if c.Email.Port <= 0 || c.Email.Port > 65535 {
+			errs = append(errs, "email SMTP port is invalid")
+		}
+	}
+	if c.Reminders.Escalation.Enabled() {
+		for _, step := range c.Reminders.Escalation.Steps {
+			if step.Channel == "" || step.Offset < 0 {
+				errs = append(errs, "reminder escalation steps need a channel and an offset which isn't negative")
+				break
+			}
+		}
+	}
+	if c.Templates.DefaultLocale == "" {
+		errs = append(errs, "templates default locale is required")
+	}
+	if c.Reminders.BatchSize <= 0 || c.Reminders.MaxAttempts <= 0 {
+		errs = append(errs, "reminder batch size and max attempts must be positive")
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ConnectionString returns the DSN, or builds one from the parts.
+func (d DatabaseConfig) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), d.SSLMode)
+}
+
+// Credentials returns the content of the OAuth client credentials, the inline JSON wins over the file.
+func (g GoogleConfig) Credentials() ([]byte, error) {
+	if g.CredentialsJSON != "" {
+		return []byte(g.CredentialsJSON), nil
+	}
+	return ioutil.ReadFile(g.CredentialsFile)
+}
+
+// quote escapes a value of a key/value connection string.
+func quote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// envReader overrides values with the environment variables which aren't empty, keeping the first parse error.
+type envReader struct {
+	err error
+}
+
+func (e *envReader) string(name string, v *string) {
+	if s := os.Getenv(name); s != "" {
+		*v = s
+	}
+}
+
+func (e *envReader) list(name string, v *[]string) {
+	if s := os.Getenv(name); s != "" {
+		*v = nil
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*v = append(*v, item)
+			}
+		}
+	}
+}
+
+func (e *envReader) durations(name string, v *[]time.Duration) {
+	if s := os.Getenv(name); s != "" {
+		*v = nil
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			d, err := time.ParseDuration(item)
+			if err != nil {
+				e.fail(name, err)
+				return
+			}
+			*v = append(*v, d)
+		}
+	}
+}
+
+// steps reads escalation steps as a list of channel:offset, e.g. email:1h,slack:10m.
+func (e *envReader) steps(name string, v *[]EscalationStep) {
+	if s := os.Getenv(name); s != "" {
+		*v = nil
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			pair := strings.SplitN(item, ":", 2)
+			if len(pair) != 2 {
+				e.fail(name, fmt.Errorf("%q isn't a channel:offset pair", item))
+				return
+			}
+			d, err := time.ParseDuration(strings.TrimSpace(pair[1]))
+			if err != nil {
+				e.fail(name, err)
+				return
+			}
+			*v = append(*v, EscalationStep{Channel: strings.TrimSpace(pair[0]), Offset: d})
+		}
+	}
+}
+
+func (e *envReader) int(name string, v *int) {
+	if s := os.Getenv(name); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*v = i
+	}
+}
+
+func (e *envReader) duration(name string, v *time.Duration) {
+	if s := os.Getenv(name); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*v = d
+	}
+}
+
+func (e *envReader) float(name string, v *float64) {
+	if s := os.Getenv(name); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*v = f
+	}
+}
+
+func (e *envReader) bool(name string, v *bool) {
+	if s := os.Getenv(name); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			e.fail(name, err)
+			return
+		}
+		*v = b
+	}
+}
+
+func (e *envReader) fail(name string, err error) {
+	if e.err == nil {
+		e.err = fmt.Errorf("%s is in invalid format: %w", name, err)
+	}
+}
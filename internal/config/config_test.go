@@ -0,0 +1,146 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfigFile = `
+server:
+  bindAddress: ":9090"
+  writeTimeout: 1m
+database:
+  host: db.internal
+  name: manny
+  user: manny
+  password: "it's secret"
+  sslMode: verify-full
+  maxOpenConns: 20
+google:
+  credentialsJson: '{"installed": {}}'
+`
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("PGSQL_HOST", "localhost")
+	t.Setenv("PGSQL_DB", "manny")
+
+	c, err := Load(nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ":8080", c.Server.BindAddress)
+	assert.Equal(t, 10*time.Second, c.Server.WriteTimeout)
+	assert.Equal(t, "disable", c.Database.SSLMode)
+	assert.Equal(t, "credentials.json", c.Google.CredentialsFile)
+	assert.Empty(t, c.Google.DeviceAuthURL)
+	assert.Equal(t, "info", c.Log.Level)
+	assert.Equal(t, "host='localhost' port=5432 user='' password='' dbname='manny' sslmode=disable",
+		c.Database.ConnectionString())
+}
+
+func TestLoad_File(t *testing.T) {
+	c, err := Load([]string{"-config", writeConfigFile(t)})
+
+	assert.Nil(t, err)
+	assert.Equal(t, ":9090", c.Server.BindAddress)
+	assert.Equal(t, time.Minute, c.Server.WriteTimeout)
+	assert.Equal(t, 5*time.Second, c.Server.ReadTimeout)
+	assert.Equal(t, 20, c.Database.MaxOpenConns)
+	assert.Equal(t, "host='db.internal' port=5432 user='manny' password='it\\'s secret' dbname='manny' sslmode=verify-full",
+		c.Database.ConnectionString())
+	credentials, err := c.Google.Credentials()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"installed": {}}`, string(credentials))
+}
+
+func TestLoad_EnvOverridesFileAndFlagsOverrideEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t))
+	t.Setenv("BIND_ADDRESS", ":7070")
+	t.Setenv("PGSQL_MAX_OPEN_CONNS", "3")
+	t.Setenv("PGSQL_DSN", "postgres://env")
+	t.Setenv("GOOGLE_SCOPES", "a, b")
+	t.Setenv("TRACING_SAMPLE_RATIO", "0.25")
+	t.Setenv("TRACING_OTLP_INSECURE", "true")
+	t.Setenv("REMINDER_OFFSETS", "1h, 10m")
+
+	c, err := Load([]string{"-db-dsn", "postgres://flag"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, ":7070", c.Server.BindAddress)
+	assert.Equal(t, 3, c.Database.MaxOpenConns)
+	assert.Equal(t, "postgres://flag", c.Database.ConnectionString())
+	assert.Equal(t, []string{"a", "b"}, c.Google.Scopes)
+	assert.Equal(t, 0.25, c.Tracing.SampleRatio)
+	assert.True(t, c.Tracing.Insecure)
+	assert.Equal(t, []time.Duration{time.Hour, 10 * time.Minute}, c.Reminders.Offsets)
+}
+
+func TestLoad_Command(t *testing.T) {
+	c, err := Load([]string{"-db-dsn", "postgres://flag", "users", "show", "42"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "postgres://flag", c.Database.ConnectionString())
+	assert.Equal(t, []string{"users", "show", "42"}, c.Command)
+}
+
+func TestLoad_InvalidEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t))
+	t.Setenv("PGSQL_PORT", "five")
+
+	c, err := Load(nil)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "PGSQL_PORT")
+	assert.Nil(t, c)
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.Server.TLSCertFile = "cert.pem"
+	c.Database.SSLMode = "prefer"
+	c.Log.Level = "verbose"
+	c.Tracing.Exporter = "otlp"
+	c.Server.PublicURL = "reminders.example.com"
+	c.Reminders.Channels = []string{"log", "slack", "telegram", "webpush", "sms"}
+	c.Templates.DefaultLocale = ""
+	c.Reminders.Escalation.Keywords = []string{"urgent"}
+
+	err := c.Validate()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TLS needs both a certificate and a key file")
+	assert.Contains(t, err.Error(), "database DSN or host and name are required")
+	assert.Contains(t, err.Error(), `database SSL mode "prefer" is invalid`)
+	assert.Contains(t, err.Error(), `log level "verbose" is invalid`)
+	assert.Contains(t, err.Error(), "tracing OTLP endpoint is required")
+	assert.Contains(t, err.Error(), "server public URL must be an absolute http or https URL")
+	assert.Contains(t, err.Error(), "slack bot token is required by the slack reminder channel")
+	assert.Contains(t, err.Error(), "telegram bot token and name are required by the telegram reminder channel")
+	assert.Contains(t, err.Error(), "web push subject must be a mailto: or https: URL")
+	assert.Contains(t, err.Error(), "sms account SID, auth token and sender are required by the sms reminder channel")
+	assert.Contains(t, err.Error(), "templates default locale is required")
+	assert.Contains(t, err.Error(), "email SMTP host and sender are required by the email reminder channel")
+}
+
+func TestLoad_EscalationStepsFromEnv(t *testing.T) {
+	t.Setenv("PGSQL_HOST", "localhost")
+	t.Setenv("PGSQL_DB", "manny")
+	t.Setenv("REMINDER_ESCALATION_KEYWORDS", "urgent, board")
+	t.Setenv("REMINDER_ESCALATION_STEPS", "log:30m, log:5m")
+
+	c, err := Load(nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []EscalationStep{{Channel: "log", Offset: 30 * time.Minute}, {Channel: "log", Offset: 5 * time.Minute}},
+		c.Reminders.Escalation.Steps)
+	assert.Equal(t, []string{"log"}, c.Reminders.AllChannels())
+}
+
+func writeConfigFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(path, []byte(testConfigFile), 0600)
+	assert.Nil(t, err)
+	return path
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"manny-reminder/internal/logging"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"sort"
+	"time"
+)
+
+// ConflictAlerter is told about the conflicts found on a user's calendar when an alert is requested.
+type ConflictAlerter interface {
+	AlertConflicts(ctx context.Context, user *models.User, conflicts models.Conflicts) error
+}
+
+type LogConflictAlerter struct {
+	l *zap.Logger
+}
+
+func NewLogConflictAlerter(l *zap.Logger) *LogConflictAlerter {
+	return &LogConflictAlerter{l}
+}
+
+func (a LogConflictAlerter) AlertConflicts(ctx context.Context, user *models.User, conflicts models.Conflicts) error {
+	for _, c := range conflicts {
+		logging.FromContext(ctx, a.l).Warn("User is double-booked",
+			zap.Stringer("userId", user.Id),
+			zap.String("first", c.First.Title),
+			zap.String("second", c.Second.Title),
+			zap.String("overlap", c.Overlap))
+	}
+	return nil
+}
+
+// GetUserConflicts returns the pairs of overlapping accepted events of the user within the range.
+func (s ServiceImpl) GetUserConflicts(ctx context.Context, userId string, from time.Time, to time.Time, alert bool) (models.Conflicts, error) {
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	events, err := s.getUserEventsInRange(ctx, user, from, to, false)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := findConflicts(userId, events)
+	if alert && len(conflicts) > 0 {
+		err = s.alertConflicts(ctx, user, conflicts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+func (s ServiceImpl) alertConflicts(ctx context.Context, user *models.User, conflicts models.Conflicts) (err error) {
+	ctx, span := tracing.Start(ctx, "ConflictAlerter.AlertConflicts",
+		attribute.String("user.id", user.Id.String()),
+		attribute.Int("conflicts", len(conflicts)))
+	defer tracing.End(span, &err)
+
+	return s.ca.AlertConflicts(ctx, user, conflicts)
+}
+
+// GetSharedConflicts returns the meetings on the calendars of several users which overlap other accepted events of
+// at least one of them.
+func (s ServiceImpl) GetSharedConflicts(ctx context.Context, from time.Time, to time.Time) ([]models.SharedConflict, error) {
+	users, err := s.as.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	meetings := make(map[string]*models.SharedConflict)
+	var conflicts models.Conflicts
+	for _, user := range users {
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+		events, err := s.getUserEventsInRange(ctx, &user, from, to, false)
+		if err != nil {
+			return nil, err
+		}
+
+		userId := user.Id.String()
+		for _, event := range events {
+			if event.ICalUID == "" {
+				continue
+			}
+			key := eventKey(event)
+			meeting, ok := meetings[key]
+			if !ok {
+				meeting = &models.SharedConflict{Meeting: event}
+				meetings[key] = meeting
+			}
+			meeting.UserIds = append(meeting.UserIds, userId)
+		}
+		conflicts = append(conflicts, findConflicts(userId, events)...)
+	}
+
+	for _, c := range conflicts {
+		for _, event := range []models.Event{c.First, c.Second} {
+			if event.ICalUID == "" {
+				continue
+			}
+			meeting := meetings[eventKey(event)]
+			if len(meeting.UserIds) > 1 {
+				meeting.Conflicts = append(meeting.Conflicts, c)
+			}
+		}
+	}
+
+	var result []models.SharedConflict
+	for _, meeting := range meetings {
+		if len(meeting.Conflicts) > 0 {
+			result = append(result, *meeting)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		si, _ := result[i].Meeting.StartTime()
+		sj, _ := result[j].Meeting.StartTime()
+		return si.Before(sj)
+	})
+
+	return result, nil
+}
+
+type timedEvent struct {
+	event models.Event
+	start time.Time
+	end   time.Time
+}
+
+// findConflicts pairs every two overlapping accepted events. Events without a start and end time, like all-day
+// events, never conflict.
+func findConflicts(userId string, events models.Events) models.Conflicts {
+	var timed []timedEvent
+	for _, event := range events {
+		if event.ResponseStatus != models.ResponseAccepted {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, event.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(time.RFC3339, event.End)
+		if err != nil || !end.After(start) {
+			continue
+		}
+		timed = append(timed, timedEvent{event, start, end})
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].start.Before(timed[j].start)
+	})
+
+	var conflicts models.Conflicts
+	for i, first := range timed {
+		for _, second := range timed[i+1:] {
+			if !second.start.Before(first.end) {
+				break
+			}
+			end := first.end
+			if second.end.Before(end) {
+				end = second.end
+			}
+			conflicts = append(conflicts, models.Conflict{
+				UserId:  userId,
+				First:   first.event,
+				Second:  second.event,
+				Overlap: end.Sub(second.start).String(),
+			})
+		}
+	}
+
+	return conflicts
+}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/oauth2"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFindConflicts_NoOverlap(t *testing.T) {
+	events := models.Events{
+		acceptedEvent("a", "A", "2022-06-01T09:00:00Z", "2022-06-01T10:00:00Z"),
+		acceptedEvent("b", "B", "2022-06-01T10:00:00Z", "2022-06-01T11:00:00Z"),
+	}
+
+	conflicts := findConflicts("user", events)
+
+	assert.Empty(t, conflicts)
+}
+
+func TestFindConflicts_OverlappingPairs(t *testing.T) {
+	events := models.Events{
+		acceptedEvent("a", "A", "2022-06-01T09:00:00Z", "2022-06-01T11:00:00Z"),
+		acceptedEvent("c", "C", "2022-06-01T12:15:00+02:00", "2022-06-01T14:00:00+02:00"),
+		acceptedEvent("b", "B", "2022-06-01T10:00:00Z", "2022-06-01T10:30:00Z"),
+	}
+
+	conflicts := findConflicts("user", events)
+
+	assert.Exactly(t, 3, len(conflicts))
+	assert.Equal(t, "A", conflicts[0].First.Title)
+	assert.Equal(t, "B", conflicts[0].Second.Title)
+	assert.Equal(t, "30m0s", conflicts[0].Overlap)
+	assert.Equal(t, "A", conflicts[1].First.Title)
+	assert.Equal(t, "C", conflicts[1].Second.Title)
+	assert.Equal(t, "45m0s", conflicts[1].Overlap)
+	assert.Equal(t, "B", conflicts[2].First.Title)
+	assert.Equal(t, "C", conflicts[2].Second.Title)
+	assert.Equal(t, "15m0s", conflicts[2].Overlap)
+}
+
+func TestFindConflicts_IgnoresNotAcceptedAndAllDay(t *testing.T) {
+	declined := acceptedEvent("b", "B", "2022-06-01T09:30:00Z", "2022-06-01T10:00:00Z")
+	declined.ResponseStatus = models.ResponseDeclined
+	events := models.Events{
+		acceptedEvent("a", "A", "2022-06-01T09:00:00Z", "2022-06-01T11:00:00Z"),
+		declined,
+		acceptedEvent("c", "C", "2022-06-01", "2022-06-02"),
+	}
+
+	conflicts := findConflicts("user", events)
+
+	assert.Empty(t, conflicts)
+}
+
+func TestService_GetUserConflicts_Alert(t *testing.T) {
+	_, as, c, es := initService(t)
+	ca := &recordingAlerter{}
+	es.SetConflictAlerter(ca)
+
+	users := generateUsers(1)
+	mockedEvents := make(map[string]models.Events)
+	mockedEvents[*users[0].Accounts[0].Token] = models.Events{
+		acceptedEvent("a", "A", "2022-06-01T09:00:00Z", "2022-06-01T10:00:00Z"),
+		acceptedEvent("b", "B", "2022-06-01T09:45:00Z", "2022-06-01T10:30:00Z"),
+	}
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+	mockCalendarGetEventsInRange(c, mockedEvents, nil)
+
+	conflicts, err := es.GetUserConflicts(context.Background(), users[0].Id.String(), time.Now(), time.Now().Add(time.Hour), true)
+
+	assert.Nil(t, err)
+	assert.Exactly(t, 1, len(conflicts))
+	assert.Equal(t, "15m0s", conflicts[0].Overlap)
+	assert.Equal(t, conflicts, ca.conflicts)
+}
+
+func TestService_GetSharedConflicts(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(3)
+	shared := acceptedEvent("shared", "Planning", "2022-06-01T09:00:00Z", "2022-06-01T10:00:00Z")
+	mockedEvents := make(map[string]models.Events)
+	mockedEvents[*users[0].Accounts[0].Token] = models.Events{
+		shared,
+		acceptedEvent("dentist", "Dentist", "2022-06-01T09:30:00Z", "2022-06-01T10:30:00Z"),
+	}
+	mockedEvents[*users[1].Accounts[0].Token] = models.Events{shared}
+	mockedEvents[*users[2].Accounts[0].Token] = models.Events{
+		acceptedEvent("solo", "Solo", "2022-06-01T13:00:00Z", "2022-06-01T14:00:00Z"),
+		acceptedEvent("other", "Other", "2022-06-01T13:30:00Z", "2022-06-01T14:00:00Z"),
+	}
+	mockAuthServiceGetUsers(as, users, nil)
+	mockCalendarGetEventsInRange(c, mockedEvents, nil)
+
+	conflicts, err := es.GetSharedConflicts(context.Background(), time.Now(), time.Now().Add(time.Hour))
+
+	assert.Nil(t, err)
+	assert.Exactly(t, 1, len(conflicts))
+	assert.Equal(t, "Planning", conflicts[0].Meeting.Title)
+	assert.Equal(t, []string{users[0].Id.String(), users[1].Id.String()}, conflicts[0].UserIds)
+	assert.Exactly(t, 1, len(conflicts[0].Conflicts))
+	assert.Equal(t, users[0].Id.String(), conflicts[0].Conflicts[0].UserId)
+	assert.Equal(t, "30m0s", conflicts[0].Conflicts[0].Overlap)
+}
+
+type recordingAlerter struct {
+	conflicts models.Conflicts
+}
+
+func (a *recordingAlerter) AlertConflicts(_ context.Context, _ *models.User, conflicts models.Conflicts) error {
+	a.conflicts = conflicts
+	return nil
+}
+
+func acceptedEvent(uid string, title string, start string, end string) models.Event {
+	return models.Event{ICalUID: uid, Title: title, Start: start, End: end, ResponseStatus: models.ResponseAccepted}
+}
+
+func mockCalendarGetEventsInRange(c *mocks.Calendar, events map[string]models.Events, err error) {
+	c.On("GetEventsInRange",
+		mock.Anything,
+		mock.Anything,
+		mock.Anything,
+		mock.Anything).Return(
+		func(_ context.Context, tok oauth2.Token, _ time.Time, _ time.Time) *models.Events {
+			token, err := json.Marshal(tok)
+			if err != nil {
+				return nil
+			}
+			event := events[string(token)]
+			return &event
+		},
+		func(_ context.Context, _ oauth2.Token, _ time.Time, _ time.Time) error {
+			return err
+		})
+}
+
+func TestHandler_GetUserConflicts_DaysInTimeZoneOfUser(t *testing.T) {
+	es := mocks.NewEventsService(t)
+	as := mocks.NewAuthService(t)
+	timeZone := "Europe/Paris"
+	as.On("GetUser", mock.Anything, "user").Return(&models.User{TimeZone: &timeZone}, nil)
+	paris, _ := time.LoadLocation(timeZone)
+	// the last Sunday of March is 23 hours long in Paris
+	from, to := time.Date(2022, 3, 27, 0, 0, 0, 0, paris), time.Date(2022, 3, 28, 0, 0, 0, 0, paris)
+	es.On("GetUserConflicts", mock.Anything, "user", from, to, false).Return(models.Conflicts{}, nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{userId}/conflicts", NewHandler(es, as).GetUserConflicts)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/user/conflicts?from=2022-03-27&to=2022-03-28", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 23*time.Hour, to.Sub(from))
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const cursorVersion = 1
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// usersCursor is the position of a merged stream in the calendar of each user. A user missing from Users was
+// exhausted on a previous page.
+type usersCursor struct {
+	Version int                     `json:"v"`
+	Users   map[string]streamCursor `json:"u"`
+}
+
+// streamCursor points at the event to resume from in the events of a user or of an account: the page they were read
+// with, and how many events of that page were already returned.
+type streamCursor struct {
+	PageToken string `json:"t,omitempty"`
+	Offset    int    `json:"o,omitempty"`
+}
+
+// cursorCodec signs cursors so clients can't forge positions or page tokens of users, the payload is not secret.
+type cursorCodec struct {
+	key []byte
+}
+
+func newCursorCodec(key []byte) cursorCodec {
+	return cursorCodec{key: key}
+}
+
+// randomCursorKey is used when no key is configured, cursors then stop being valid when the service restarts.
+func randomCursorKey() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}
+
+func (c cursorCodec) encode(cursor usersCursor) (string, error) {
+	if len(cursor.Users) == 0 {
+		return "", nil
+	}
+	cursor.Version = cursorVersion
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+// decode returns nil for the empty cursor of the first page.
+func (c cursorCodec) decode(s string) (*usersCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 {
+		return nil, errInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return nil, errInvalidCursor
+	}
+
+	var cursor usersCursor
+	err = json.Unmarshal(payload, &cursor)
+	if err != nil || cursor.Version != cursorVersion {
+		return nil, errInvalidCursor
+	}
+	for _, uc := range cursor.Users {
+		if uc.Offset < 0 {
+			return nil, errInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+func (c cursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
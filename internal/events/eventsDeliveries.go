@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+var deliveryStatuses = map[string]bool{
+	models.DeliveryPending:      true,
+	models.DeliverySending:      true,
+	models.DeliverySent:         true,
+	models.DeliveryFailed:       true,
+	models.DeliveryCancelled:    true,
+	models.DeliveryAcknowledged: true,
+}
+
+// GetDeliveries lists the reminder deliveries matching the filter, latest first.
+func (s ServiceImpl) GetDeliveries(ctx context.Context, filter models.DeliveryFilter) (models.Deliveries, error) {
+	if filter.Status != "" && !deliveryStatuses[filter.Status] {
+		return nil, fmt.Errorf("delivery status %q is invalid", filter.Status)
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return nil, fmt.Errorf("to must be after from")
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultDeliveriesLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxDeliveriesLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxDeliveriesLimit)
+	}
+
+	deliveries, err := s.r.GetDeliveries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = models.Deliveries{}
+	}
+	return deliveries, nil
+}
+
+// ResendDelivery sends the delivery again at the next dispatch. It returns nil when the delivery doesn't exist, is
+// being sent or was acknowledged by the user.
+func (s ServiceImpl) ResendDelivery(ctx context.Context, id string) (*models.Delivery, error) {
+	deliveryId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+	delivery, err := s.r.ResendDelivery(ctx, &deliveryId)
+	if err != nil || delivery == nil {
+		return nil, err
+	}
+	s.l.Info("Resending delivery", zap.String("deliveryId", id))
+	return delivery, nil
+}
@@ -1,11 +1,22 @@
 package events
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
+	"manny-reminder/internal/auth"
 	"manny-reminder/internal/models"
 	"manny-reminder/internal/utils"
 	"net/http"
 	"strconv"
+	"time"
+)
+
+const (
+	// defaultDays is the length of the range of conflicts, in days of the time zone of the range
+	defaultDays     = 7
+	defaultPageSize = 10
+	maxPageSize     = 100
 )
 
 type EventsHandler interface {
@@ -13,21 +24,23 @@ type EventsHandler interface {
 
 type HandlerImpl struct {
 	es EventsService
+	as auth.AuthService
 }
 
-func NewHandler(es EventsService) *HandlerImpl {
-	return &HandlerImpl{es: es}
+func NewHandler(es EventsService, as auth.AuthService) *HandlerImpl {
+	return &HandlerImpl{es: es, as: as}
 }
 
-type GetUsersEventsResponse map[string][]models.Event
-
+// GetUsersEvents pages through the events of all users merged by start, pageToken is the cursor returned as
+// nextPageToken by the previous page.
 func (h HandlerImpl) GetUsersEvents(w http.ResponseWriter, r *http.Request) {
-	_, s, err := h.getPagingData(r)
+	pt, s, err := h.getPagingData(r)
 	if err != nil {
 		utils.SendHttpError(w, err)
+		return
 	}
 
-	events, err := h.es.GetUsersEvents("", s)
+	events, err := h.es.GetUsersEvents(r.Context(), pt, s)
 	if err != nil {
 		utils.SendHttpError(w, err)
 		return
@@ -46,9 +59,10 @@ func (h HandlerImpl) GetUserEvents(w http.ResponseWriter, r *http.Request) {
 	pt, s, err := h.getPagingData(r)
 	if err != nil {
 		utils.SendHttpError(w, err)
+		return
 	}
 
-	events, err := h.es.GetUserEvents(userId, pt, s)
+	events, err := h.es.GetUserEvents(r.Context(), userId, pt, s)
 	if err != nil {
 		utils.SendHttpError(w, err)
 		return
@@ -56,8 +70,123 @@ func (h HandlerImpl) GetUserEvents(w http.ResponseWriter, r *http.Request) {
 	utils.SendJson(w, events)
 }
 
+// GetTeamEvents returns the merged calendar of the members of the team, within the from and to query params like
+// the conflicts.
+func (h HandlerImpl) GetTeamEvents(w http.ResponseWriter, r *http.Request) {
+	loc, err := h.location(r, "")
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	from, to, err := h.getRange(r, loc)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+
+	events, err := h.es.GetTeamEvents(r.Context(), mux.Vars(r)["teamId"], from, to, loc)
+	if errors.Is(err, ErrTeamNotFound) {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, events)
+}
+
+func (h HandlerImpl) GetUserConflicts(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userId := params["userId"]
+	if userId == "" {
+		utils.SendHttpStringError(w, "User id not defined")
+		return
+	}
+	loc, err := h.location(r, userId)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	from, to, err := h.getRange(r, loc)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	alert := r.URL.Query().Get("alert") == "true"
+
+	conflicts, err := h.es.GetUserConflicts(r.Context(), userId, from, to, alert)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, conflicts)
+}
+
+func (h HandlerImpl) GetSharedConflicts(w http.ResponseWriter, r *http.Request) {
+	loc, err := h.location(r, "")
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	from, to, err := h.getRange(r, loc)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+
+	conflicts, err := h.es.GetSharedConflicts(r.Context(), from, to)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, conflicts)
+}
+
+// GetDeliveries lists reminder deliveries, filtered by the userId, eventId, channel, status, from, to and limit
+// query params.
+func (h HandlerImpl) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := models.DeliveryFilter{
+		UserId:  q.Get("userId"),
+		EventId: q.Get("eventId"),
+		Channel: q.Get("channel"),
+		Status:  q.Get("status"),
+	}
+	loc, err := h.location(r, filter.UserId)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	filter.From, err = parseOptionalTime(q.Get("from"), loc)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	filter.To, err = parseOptionalTime(q.Get("to"), loc)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			utils.SendHttpError(w, err)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deliveries, err := h.es.GetDeliveries(r.Context(), filter)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, deliveries)
+}
+
 func (h HandlerImpl) getPagingData(r *http.Request) (string, int, error) {
-	size := 10
+	size := defaultPageSize
 	var err error
 
 	pageToken := r.URL.Query().Get("pageToken")
@@ -69,6 +198,78 @@ func (h HandlerImpl) getPagingData(r *http.Request) (string, int, error) {
 			return "", 0, err
 		}
 	}
+	if size < 1 || size > maxPageSize {
+		return "", 0, fmt.Errorf("size must be between 1 and %d", maxPageSize)
+	}
 
 	return pageToken, size, nil
 }
+
+// location is the time zone dates are read in: the tz query param, else the time zone of the user, else UTC.
+func (h HandlerImpl) location(r *http.Request, userId string) (*time.Location, error) {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", tz)
+		}
+		return loc, nil
+	}
+	if userId == "" {
+		return time.UTC, nil
+	}
+	user, err := h.as.GetUser(r.Context(), userId)
+	if err != nil {
+		return nil, err
+	}
+	return user.Location(), nil
+}
+
+// getRange reads the from and to query params as RFC3339 or dates, a date being midnight in the location. It
+// defaults to the coming week.
+func (h HandlerImpl) getRange(r *http.Request, loc *time.Location) (time.Time, time.Time, error) {
+	from := time.Now().In(loc)
+	var err error
+
+	fromStr := r.URL.Query().Get("from")
+	if fromStr != "" {
+		from, err = parseTime(fromStr, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	// days rather than hours, a week spanning a DST change is an hour shorter or longer
+	to := from.In(loc).AddDate(0, 0, defaultDays)
+	toStr := r.URL.Query().Get("to")
+	if toStr != "" {
+		to, err = parseTime(toStr, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+
+	return from, to, nil
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
+
+func parseOptionalTime(s string, loc *time.Location) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := parseTime(s, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -1,18 +1,452 @@
 package events
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"sort"
+	"strings"
+	"time"
 )
 
 type EventsRepository interface {
+	AddDelivery(ctx context.Context, delivery models.Delivery) (bool, error)
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) (models.Deliveries, error)
+	CompleteDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, sentAt time.Time) (bool, error)
+	FailDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, lastError string, retryAt *time.Time) (bool, error)
+	GetDelivery(ctx context.Context, id string) (*models.Delivery, error)
+	GetDeliveries(ctx context.Context, filter models.DeliveryFilter) (models.Deliveries, error)
+	CancelDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, reason string) (bool, error)
+	DeferDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, sendAt time.Time) (bool, error)
+	CancelDeliveries(ctx context.Context, userId string, eventId string, keepStart *time.Time, reason string) error
+	SnoozeDelivery(ctx context.Context, id *uuid.UUID, snoozes int, sendAt time.Time) (bool, error)
+	AcknowledgeDelivery(ctx context.Context, id *uuid.UUID, snoozes int) (bool, error)
+	IsAcknowledged(ctx context.Context, userId string, eventId string, eventStart time.Time) (bool, error)
+	ResendDelivery(ctx context.Context, id *uuid.UUID) (*models.Delivery, error)
+	GetEventSnapshots(ctx context.Context, userId string, from time.Time, to time.Time) (models.Events, error)
+	GetEventSnapshot(ctx context.Context, userId string, eventId string) (*models.Event, error)
+	SaveEventSnapshot(ctx context.Context, userId string, event models.Event, previous *models.Event) (bool, error)
+	DeleteEventSnapshot(ctx context.Context, userId string, previous models.Event) (bool, error)
+	PruneEventSnapshots(ctx context.Context, before time.Time) error
 }
 
 type RepositoryImpl struct {
-	l  *log.Logger
+	l  *zap.Logger
 	db *sql.DB
 }
 
-func NewRepository(l *log.Logger, db *sql.DB) *RepositoryImpl {
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
 	return &RepositoryImpl{l, db}
 }
+
+const deliveryColumns = `id, user_id, event_id, event_start, offset_minutes, channel, send_at, status, attempts,
+last_error, created_at, updated_at, claimed_at, sent_at, snoozes, escalation_step, acknowledged_at, cancel_reason`
+
+// AddDelivery schedules a delivery, returning false when the same reminder was already scheduled. One cancelled because
+// its event changed is scheduled again, as the event came back to that start after being moved away or cancelled.
+func (r RepositoryImpl) AddDelivery(ctx context.Context, d models.Delivery) (_ bool, err error) {
+	query := `INSERT INTO reminder_deliveries (id, user_id, event_id, event_start, offset_minutes, channel, send_at, status,
+escalation_step)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (user_id, event_id, event_start, offset_minutes, channel) DO UPDATE
+SET status = EXCLUDED.status, send_at = EXCLUDED.send_at, escalation_step = EXCLUDED.escalation_step, attempts = 0,
+    last_error = '', claimed_at = NULL, sent_at = NULL, snoozes = 0, cancel_reason = '', updated_at = now()
+WHERE reminder_deliveries.status = 'cancelled' AND reminder_deliveries.cancel_reason = $10`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.AddDelivery", query)
+	defer tracing.End(span, &err)
+
+	status := d.Status
+	if status == "" {
+		status = models.DeliveryPending
+	}
+	return r.execOne(ctx, query, d.Id, d.UserId, d.EventId, d.EventStart, d.OffsetMinutes, d.Channel, d.SendAt, status,
+		d.EscalationStep, models.CancelEventChanged)
+}
+
+// ClaimDeliveries marks up to limit due deliveries as sending and returns them ordered by send time. Rows claimed by
+// another scheduler are skipped rather than waited for, and claims older than lease are taken over, as their
+// scheduler likely died before finishing. The ClaimedAt of a returned delivery identifies the claim, the updates of
+// claimed deliveries only apply while it is still the current one.
+func (r RepositoryImpl) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) (_ models.Deliveries, err error) {
+	query := `UPDATE reminder_deliveries d
+SET status = 'sending', attempts = d.attempts + 1, claimed_at = $1, updated_at = $1
+FROM (SELECT id FROM reminder_deliveries
+      WHERE (status = 'pending' AND send_at <= $1) OR (status = 'sending' AND claimed_at < $2)
+      ORDER BY send_at
+      LIMIT $3
+      FOR UPDATE SKIP LOCKED) due
+WHERE d.id = due.id
+RETURNING ` + qualify(deliveryColumns, "d")
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.ClaimDeliveries", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, now, now.Add(-lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].SendAt.Before(deliveries[j].SendAt)
+	})
+	return deliveries, nil
+}
+
+// CompleteDelivery marks a claimed delivery as sent, returning false when the claim was taken over.
+func (r RepositoryImpl) CompleteDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, sentAt time.Time) (_ bool, err error) {
+	query := `UPDATE reminder_deliveries SET status = 'sent', sent_at = $3, updated_at = $3, last_error = ''
+WHERE id = $1 AND status = 'sending' AND claimed_at = $2`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.CompleteDelivery", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, id, claimedAt, sentAt)
+}
+
+// FailDelivery records the error of a claimed delivery, putting it back to pending at retryAt, or failing it for
+// good when retryAt is nil. It returns false when the claim was taken over.
+func (r RepositoryImpl) FailDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, lastError string, retryAt *time.Time) (_ bool, err error) {
+	query := `UPDATE reminder_deliveries SET status = 'failed', last_error = $3, updated_at = now()
+WHERE id = $1 AND status = 'sending' AND claimed_at = $2`
+	args := []interface{}{id, claimedAt, lastError}
+	if retryAt != nil {
+		query = `UPDATE reminder_deliveries SET status = 'pending', last_error = $3, send_at = $4, updated_at = now()
+WHERE id = $1 AND status = 'sending' AND claimed_at = $2`
+		args = append(args, *retryAt)
+	}
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.FailDelivery", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, args...)
+}
+
+// CancelDelivery cancels a claimed delivery which must not be sent anymore for the reason, returning false when the
+// claim was taken over.
+func (r RepositoryImpl) CancelDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, reason string) (_ bool, err error) {
+	query := `UPDATE reminder_deliveries SET status = 'cancelled', cancel_reason = $3, updated_at = now()
+WHERE id = $1 AND status = 'sending' AND claimed_at = $2`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.CancelDelivery", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, id, claimedAt, reason)
+}
+
+// DeferDelivery puts a claimed delivery back to pending at sendAt, the claim not counting as an attempt. It returns
+// false when the claim was taken over.
+func (r RepositoryImpl) DeferDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, sendAt time.Time) (_ bool, err error) {
+	query := `UPDATE reminder_deliveries
+SET status = 'pending', send_at = $3, attempts = attempts - 1, claimed_at = NULL, updated_at = now()
+WHERE id = $1 AND status = 'sending' AND claimed_at = $2`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.DeferDelivery", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, id, claimedAt, sendAt)
+}
+
+// CancelDeliveries cancels the pending deliveries of an event for the reason, except those of the occurrence at
+// keepStart when set.
+func (r RepositoryImpl) CancelDeliveries(ctx context.Context, userId string, eventId string, keepStart *time.Time, reason string) (err error) {
+	query := `UPDATE reminder_deliveries SET status = 'cancelled', cancel_reason = $3, updated_at = now()
+WHERE user_id = $1 AND event_id = $2 AND status = 'pending'`
+	args := []interface{}{userId, eventId, reason}
+	if keepStart != nil {
+		query += " AND event_start <> $4"
+		args = append(args, *keepStart)
+	}
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.CancelDeliveries", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// SnoozeDelivery puts a sent delivery back to pending at sendAt. It only applies to the delivery as it was after
+// its given number of snoozes, so an action link works once, and returns false otherwise.
+func (r RepositoryImpl) SnoozeDelivery(ctx context.Context, id *uuid.UUID, snoozes int, sendAt time.Time) (_ bool, err error) {
+	query := `UPDATE reminder_deliveries
+SET status = 'pending', send_at = $3, snoozes = snoozes + 1, attempts = 0, claimed_at = NULL, updated_at = now()
+WHERE id = $1 AND status = 'sent' AND snoozes = $2`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.SnoozeDelivery", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, id, snoozes, sendAt)
+}
+
+// AcknowledgeDelivery closes a sent delivery the user marked as done, with the same single use as SnoozeDelivery.
+func (r RepositoryImpl) AcknowledgeDelivery(ctx context.Context, id *uuid.UUID, snoozes int) (_ bool, err error) {
+	query := `UPDATE reminder_deliveries SET status = 'acknowledged', acknowledged_at = now(), updated_at = now()
+WHERE id = $1 AND status = 'sent' AND snoozes = $2`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.AcknowledgeDelivery", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, id, snoozes)
+}
+
+// IsAcknowledged tells whether the user acknowledged any delivery of the occurrence of the event at eventStart.
+func (r RepositoryImpl) IsAcknowledged(ctx context.Context, userId string, eventId string, eventStart time.Time) (_ bool, err error) {
+	query := `SELECT EXISTS (SELECT 1 FROM reminder_deliveries
+WHERE user_id = $1 AND event_id = $2 AND event_start = $3 AND acknowledged_at IS NOT NULL)`
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.IsAcknowledged", query)
+	defer tracing.End(span, &err)
+
+	var acknowledged bool
+	err = r.db.QueryRowContext(ctx, query, userId, eventId, eventStart).Scan(&acknowledged)
+	return acknowledged, err
+}
+
+// ResendDelivery puts a delivery back to pending to be sent right away, with its attempts reset. Deliveries the user
+// acknowledged or a scheduler holds are left alone, it returns nil for them and for those which don't exist.
+func (r RepositoryImpl) ResendDelivery(ctx context.Context, id *uuid.UUID) (_ *models.Delivery, err error) {
+	query := `UPDATE reminder_deliveries
+SET status = 'pending', send_at = now(), attempts = 0, claimed_at = NULL, cancel_reason = '', updated_at = now()
+WHERE id = $1 AND status IN ('pending', 'sent', 'failed', 'cancelled')
+RETURNING ` + deliveryColumns
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.ResendDelivery", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	return &deliveries[0], nil
+}
+
+func (r RepositoryImpl) GetDelivery(ctx context.Context, id string) (_ *models.Delivery, err error) {
+	query := "SELECT " + deliveryColumns + " FROM reminder_deliveries WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.GetDelivery", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	return &deliveries[0], nil
+}
+
+// GetDeliveries returns the deliveries matching the filter, latest send time first.
+func (r RepositoryImpl) GetDeliveries(ctx context.Context, filter models.DeliveryFilter) (_ models.Deliveries, err error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.UserId != "" {
+		where("user_id = $%d", filter.UserId)
+	}
+	if filter.EventId != "" {
+		where("event_id = $%d", filter.EventId)
+	}
+	if filter.Channel != "" {
+		where("channel = $%d", filter.Channel)
+	}
+	if filter.Status != "" {
+		where("status = $%d", filter.Status)
+	}
+	if filter.From != nil {
+		where("send_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("send_at < $%d", *filter.To)
+	}
+
+	query := "SELECT " + deliveryColumns + " FROM reminder_deliveries"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY send_at DESC LIMIT $%d", len(args))
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.GetDeliveries", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	return scanDeliveries(rows)
+}
+
+const snapshotColumns = "event_id, ical_uid, title, start_at, end_at, organizer, status, response_status, join_url, attendees"
+
+// GetEventSnapshots returns the last seen state of the events of the user starting within the range.
+func (r RepositoryImpl) GetEventSnapshots(ctx context.Context, userId string, from time.Time, to time.Time) (_ models.Events, err error) {
+	query := "SELECT " + snapshotColumns + " FROM event_snapshots WHERE user_id = $1 AND start_at >= $2 AND start_at < $3 ORDER BY start_at"
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.GetEventSnapshots", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	return scanSnapshots(rows)
+}
+
+func (r RepositoryImpl) GetEventSnapshot(ctx context.Context, userId string, eventId string) (_ *models.Event, err error) {
+	query := "SELECT " + snapshotColumns + " FROM event_snapshots WHERE user_id = $1 AND event_id = $2"
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.GetEventSnapshot", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, userId, eventId)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	events, err := scanSnapshots(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+// SaveEventSnapshot stores the state of an event. Without a previous state it inserts or overwrites it, with one it
+// only replaces that exact state, so when syncs run concurrently only the one returning true acts on the change.
+func (r RepositoryImpl) SaveEventSnapshot(ctx context.Context, userId string, event models.Event, previous *models.Event) (_ bool, err error) {
+	start, err := event.StartTime()
+	if err != nil {
+		return false, err
+	}
+	end, err := event.EndTime()
+	if err != nil {
+		return false, err
+	}
+	attendees := event.Attendees
+	if attendees == nil {
+		attendees = []string{}
+	}
+	args := []interface{}{userId, event.Id, event.ICalUID, event.Title, start, end, event.Organizer, event.Status,
+		event.ResponseStatus, event.JoinURL, pq.Array(attendees)}
+
+	query := `INSERT INTO event_snapshots (user_id, event_id, ical_uid, title, start_at, end_at, organizer, status, response_status, join_url, attendees)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (user_id, event_id) DO UPDATE
+SET ical_uid = $3, title = $4, start_at = $5, end_at = $6, organizer = $7, status = $8, response_status = $9,
+    join_url = $10, attendees = $11, updated_at = now()`
+	if previous != nil {
+		previousStart, err := previous.StartTime()
+		if err != nil {
+			return false, err
+		}
+		query = `UPDATE event_snapshots
+SET ical_uid = $3, title = $4, start_at = $5, end_at = $6, organizer = $7, status = $8, response_status = $9,
+    join_url = $10, attendees = $11, updated_at = now()
+WHERE user_id = $1 AND event_id = $2 AND start_at = $12 AND status = $13 AND response_status = $14`
+		args = append(args, previousStart, previous.Status, previous.ResponseStatus)
+	}
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.SaveEventSnapshot", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, args...)
+}
+
+// DeleteEventSnapshot forgets an event which is gone from the calendar, returning false when a concurrent sync
+// already did or saw another state.
+func (r RepositoryImpl) DeleteEventSnapshot(ctx context.Context, userId string, previous models.Event) (_ bool, err error) {
+	query := "DELETE FROM event_snapshots WHERE user_id = $1 AND event_id = $2 AND start_at = $3"
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.DeleteEventSnapshot", query)
+	defer tracing.End(span, &err)
+
+	start, err := previous.StartTime()
+	if err != nil {
+		return false, err
+	}
+	return r.execOne(ctx, query, userId, previous.Id, start)
+}
+
+// PruneEventSnapshots forgets the events which ended before the given time.
+func (r RepositoryImpl) PruneEventSnapshots(ctx context.Context, before time.Time) (err error) {
+	query := "DELETE FROM event_snapshots WHERE end_at < $1"
+	ctx, span := tracing.StartQuery(ctx, "EventsRepository.PruneEventSnapshots", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, before)
+	return err
+}
+
+// execOne runs a statement meant to change a single row, telling whether it did.
+func (r RepositoryImpl) execOne(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r RepositoryImpl) closeRows(rows *sql.Rows) {
+	err := rows.Close()
+	if err != nil {
+		r.l.Error("Unable to close rows", zap.Error(err))
+	}
+}
+
+// qualify prefixes every column of the list with the table alias.
+func qualify(columns string, alias string) string {
+	var result []string
+	for _, column := range strings.Split(columns, ",") {
+		result = append(result, alias+"."+strings.TrimSpace(column))
+	}
+	return strings.Join(result, ", ")
+}
+
+func scanDeliveries(rows *sql.Rows) (models.Deliveries, error) {
+	var deliveries models.Deliveries
+	for rows.Next() {
+		var d models.Delivery
+		err := rows.Scan(&d.Id, &d.UserId, &d.EventId, &d.EventStart, &d.OffsetMinutes, &d.Channel, &d.SendAt, &d.Status,
+			&d.Attempts, &d.LastError, &d.CreatedAt, &d.UpdatedAt, &d.ClaimedAt, &d.SentAt, &d.Snoozes,
+			&d.EscalationStep, &d.AcknowledgedAt, &d.CancelReason)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func scanSnapshots(rows *sql.Rows) (models.Events, error) {
+	var events models.Events
+	for rows.Next() {
+		var e models.Event
+		var start, end time.Time
+		err := rows.Scan(&e.Id, &e.ICalUID, &e.Title, &start, &end, &e.Organizer, &e.Status, &e.ResponseStatus, &e.JoinURL,
+			pq.Array(&e.Attendees))
+		if err != nil {
+			return nil, err
+		}
+		e.Start = start.UTC().Format(time.RFC3339)
+		e.End = end.UTC().Format(time.RFC3339)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,211 @@
+package events
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"testing"
+	"time"
+)
+
+var deliveryColumnNames = []string{"id", "user_id", "event_id", "event_start", "offset_minutes", "channel", "send_at",
+	"status", "attempts", "last_error", "created_at", "updated_at", "claimed_at", "sent_at", "snoozes",
+	"escalation_step", "acknowledged_at", "cancel_reason"}
+
+func TestRepository_AddDelivery_AlreadyScheduled(t *testing.T) {
+	r, db := initRepository(t)
+	db.ExpectExec(`INSERT INTO reminder_deliveries .* ON CONFLICT \(user_id, event_id, event_start, offset_minutes, channel\) DO UPDATE`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	created, err := r.AddDelivery(context.Background(), delivery(time.Now()))
+
+	assert.Nil(t, err)
+	assert.False(t, created)
+}
+
+func TestRepository_AddDelivery_EventMovedBackRevivesCancelled(t *testing.T) {
+	r, db := initRepository(t)
+	// the event moved from A to B, cancelling the delivery at A, then back to A
+	d := delivery(time.Now())
+	d.Status = models.DeliveryPending
+	db.ExpectExec(`ON CONFLICT .* DO UPDATE SET status = EXCLUDED.status, send_at = EXCLUDED.send_at, .*attempts = 0, `+
+		`.*claimed_at = NULL, .* WHERE reminder_deliveries.status = 'cancelled' AND reminder_deliveries.cancel_reason = \$10`).
+		WithArgs(d.Id, d.UserId, d.EventId, d.EventStart, d.OffsetMinutes, d.Channel, d.SendAt, models.DeliveryPending,
+			d.EscalationStep, models.CancelEventChanged).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	created, err := r.AddDelivery(context.Background(), d)
+
+	assert.Nil(t, err)
+	assert.True(t, created)
+}
+
+func TestRepository_ClaimDeliveries_SkipsLockedAndOrdersBySendTime(t *testing.T) {
+	r, db := initRepository(t)
+	now := time.Date(2022, 6, 1, 9, 0, 0, 0, time.UTC)
+	first, second := delivery(now.Add(-2*time.Minute)), delivery(now.Add(-time.Minute))
+	db.ExpectQuery(`FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, now.Add(-5*time.Minute), 10).
+		WillReturnRows(sqlmock.NewRows(deliveryColumnNames).AddRow(deliveryRow(second)...).AddRow(deliveryRow(first)...))
+
+	deliveries, err := r.ClaimDeliveries(context.Background(), now, 5*time.Minute, 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, models.Deliveries{first, second}, deliveries)
+}
+
+func TestRepository_FailDelivery_RetryPutsBackToPending(t *testing.T) {
+	r, db := initRepository(t)
+	d := delivery(time.Now())
+	claimedAt := time.Now()
+	retryAt := time.Now().Add(time.Minute)
+	db.ExpectExec(`UPDATE reminder_deliveries SET status = 'pending'`).
+		WithArgs(d.Id, &claimedAt, "timeout", retryAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	held, err := r.FailDelivery(context.Background(), d.Id, &claimedAt, "timeout", &retryAt)
+
+	assert.Nil(t, err)
+	assert.True(t, held)
+}
+
+func TestRepository_DeferDelivery_GivesBackAttempt(t *testing.T) {
+	r, db := initRepository(t)
+	d := delivery(time.Now())
+	claimedAt := time.Now()
+	sendAt := time.Now().Add(time.Hour)
+	db.ExpectExec(`UPDATE reminder_deliveries\s+SET status = 'pending', send_at = \$3, attempts = attempts - 1`).
+		WithArgs(d.Id, &claimedAt, sendAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	held, err := r.DeferDelivery(context.Background(), d.Id, &claimedAt, sendAt)
+
+	assert.Nil(t, err)
+	assert.True(t, held)
+}
+
+func TestRepository_CompleteDelivery_StaleClaimerLeavesTheNewClaim(t *testing.T) {
+	r, db := initRepository(t)
+	d := delivery(time.Now())
+	// the delivery was claimed again by another scheduler once the lease of this claim was over
+	staleClaim := time.Now().Add(-10 * time.Minute)
+	sentAt := time.Now()
+	db.ExpectExec(`UPDATE reminder_deliveries SET status = 'sent'.*\s+WHERE id = \$1 AND status = 'sending' AND claimed_at = \$2`).
+		WithArgs(d.Id, &staleClaim, sentAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	held, err := r.CompleteDelivery(context.Background(), d.Id, &staleClaim, sentAt)
+
+	assert.Nil(t, err)
+	assert.False(t, held)
+}
+
+func TestRepository_CancelDeliveries_RecordsReason(t *testing.T) {
+	r, db := initRepository(t)
+	start := time.Now()
+	db.ExpectExec(`UPDATE reminder_deliveries SET status = 'cancelled', cancel_reason = \$3, .* AND event_start <> \$4`).
+		WithArgs("user", "event", models.CancelEventChanged, start).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := r.CancelDeliveries(context.Background(), "user", "event", &start, models.CancelEventChanged)
+
+	assert.Nil(t, err)
+}
+
+func TestRepository_IsAcknowledged(t *testing.T) {
+	r, db := initRepository(t)
+	start := time.Now()
+	db.ExpectQuery(`SELECT EXISTS .* event_start = \$3 AND acknowledged_at IS NOT NULL`).
+		WithArgs("user", "event", start).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	acknowledged, err := r.IsAcknowledged(context.Background(), "user", "event", start)
+
+	assert.Nil(t, err)
+	assert.True(t, acknowledged)
+}
+
+func TestRepository_SnoozeDelivery_OnlyOnce(t *testing.T) {
+	r, db := initRepository(t)
+	d := delivery(time.Now())
+	sendAt := time.Now().Add(5 * time.Minute)
+	db.ExpectExec(`UPDATE reminder_deliveries .* WHERE id = \$1 AND status = 'sent' AND snoozes = \$2`).
+		WithArgs(d.Id, 0, sendAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	db.ExpectExec(`UPDATE reminder_deliveries .* WHERE id = \$1 AND status = 'sent' AND snoozes = \$2`).
+		WithArgs(d.Id, 0, sendAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	first, err := r.SnoozeDelivery(context.Background(), d.Id, 0, sendAt)
+	assert.Nil(t, err)
+	second, err := r.SnoozeDelivery(context.Background(), d.Id, 0, sendAt)
+	assert.Nil(t, err)
+
+	assert.True(t, first)
+	assert.False(t, second)
+}
+
+func TestRepository_ResendDelivery_SkipsAcknowledgedAndSending(t *testing.T) {
+	r, db := initRepository(t)
+	d := delivery(time.Now())
+	d.Status = models.DeliveryPending
+	d.Attempts = 0
+	db.ExpectQuery(`UPDATE reminder_deliveries .* WHERE id = \$1 AND status IN \('pending', 'sent', 'failed', 'cancelled'\)`).
+		WithArgs(d.Id).
+		WillReturnRows(sqlmock.NewRows(deliveryColumnNames).AddRow(deliveryRow(d)...))
+	db.ExpectQuery(`UPDATE reminder_deliveries`).
+		WithArgs(d.Id).
+		WillReturnRows(sqlmock.NewRows(deliveryColumnNames))
+
+	resent, err := r.ResendDelivery(context.Background(), d.Id)
+	assert.Nil(t, err)
+	skipped, err := r.ResendDelivery(context.Background(), d.Id)
+	assert.Nil(t, err)
+
+	assert.Equal(t, d.Id, resent.Id)
+	assert.Equal(t, models.DeliveryPending, resent.Status)
+	assert.Nil(t, skipped)
+}
+
+func TestRepository_GetDeliveries_Filters(t *testing.T) {
+	r, db := initRepository(t)
+	from := time.Now()
+	db.ExpectQuery(`WHERE user_id = \$1 AND status = \$2 AND send_at >= \$3 ORDER BY send_at DESC LIMIT \$4`).
+		WithArgs("user", models.DeliveryFailed, from, 20).
+		WillReturnRows(sqlmock.NewRows(deliveryColumnNames))
+
+	deliveries, err := r.GetDeliveries(context.Background(), models.DeliveryFilter{
+		UserId: "user", Status: models.DeliveryFailed, From: &from, Limit: 20,
+	})
+
+	assert.Nil(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func initRepository(t *testing.T) (*RepositoryImpl, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, mock.ExpectationsWereMet())
+		_ = db.Close()
+	})
+	return NewRepository(zap.NewNop(), db), mock
+}
+
+func delivery(sendAt time.Time) models.Delivery {
+	id, userId := uuid.New(), uuid.New()
+	return models.Delivery{
+		Id: &id, UserId: &userId, EventId: "event", EventStart: sendAt.Add(10 * time.Minute), OffsetMinutes: 10,
+		Channel: "log", SendAt: sendAt, Status: models.DeliverySending, Attempts: 1, CreatedAt: sendAt, UpdatedAt: sendAt,
+	}
+}
+
+func deliveryRow(d models.Delivery) []driver.Value {
+	return []driver.Value{d.Id.String(), d.UserId.String(), d.EventId, d.EventStart, d.OffsetMinutes, d.Channel, d.SendAt,
+		d.Status, d.Attempts, d.LastError, d.CreatedAt, d.UpdatedAt, d.ClaimedAt, d.SentAt, d.Snoozes,
+		d.EscalationStep, d.AcknowledgedAt, d.CancelReason}
+}
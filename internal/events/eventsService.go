@@ -2,62 +2,199 @@ package events
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"golang.org/x/oauth2"
 	calendar2 "manny-reminder/internal/calendar"
+	"sort"
 	"time"
 
-	"log"
+	"go.uber.org/zap"
 	"manny-reminder/internal/auth"
+	"manny-reminder/internal/logging"
 	"manny-reminder/internal/models"
 )
 
+var ErrTeamNotFound = errors.New("team not found")
+
 type EventsService interface {
-	GetUsersEvents(pageToken string, size int) (map[string]models.EventsResponse, error)
-	GetUserEvents(userId string, pageToken string, size int) (models.EventsResponse, error)
+	GetUsersEvents(ctx context.Context, cursor string, size int) (models.UserEventsResponse, error)
+	GetUserEvents(ctx context.Context, userId string, pageToken string, size int) (models.EventsResponse, error)
+	GetUserEventsInRange(ctx context.Context, userId string, from time.Time, to time.Time) (models.Events, error)
+	GetEventsInRangeForUser(ctx context.Context, user *models.User, from time.Time, to time.Time) (models.Events, error)
+	GetEventsToSync(ctx context.Context, user *models.User, from time.Time, to time.Time) (models.Events, error)
+	GetTeamEvents(ctx context.Context, teamId string, from time.Time, to time.Time, loc *time.Location) (models.TeamEvents, error)
+	GetUserConflicts(ctx context.Context, userId string, from time.Time, to time.Time, alert bool) (models.Conflicts, error)
+	GetSharedConflicts(ctx context.Context, from time.Time, to time.Time) ([]models.SharedConflict, error)
+	GetDeliveries(ctx context.Context, filter models.DeliveryFilter) (models.Deliveries, error)
+	ResendDelivery(ctx context.Context, id string) (*models.Delivery, error)
 }
 
 type ServiceImpl struct {
-	l  *log.Logger
-	r  EventsRepository
-	as auth.AuthService
-	c  calendar2.Calendar
+	l       *zap.Logger
+	r       EventsRepository
+	as      auth.AuthService
+	cs      calendar2.Calendars
+	ca      ConflictAlerter
+	cursors cursorCodec
+}
+
+func NewService(r EventsRepository, l *zap.Logger, as auth.AuthService, cs calendar2.Calendars) *ServiceImpl {
+	return &ServiceImpl{l: l, r: r, as: as, cs: cs, ca: NewLogConflictAlerter(l), cursors: newCursorCodec(randomCursorKey())}
+}
+
+// SetCursorKey sets the key signing the cursors of the merged events stream, so they survive restarts and work
+// across replicas.
+func (s *ServiceImpl) SetCursorKey(key []byte) {
+	s.cursors = newCursorCodec(key)
 }
 
-func NewService(r EventsRepository, l *log.Logger, as auth.AuthService, c calendar2.Calendar) *ServiceImpl {
-	return &ServiceImpl{l: l, r: r, as: as, c: c}
+// SetConflictAlerter replaces the alerter used when conflicts are requested with an alert.
+func (s *ServiceImpl) SetConflictAlerter(ca ConflictAlerter) {
+	s.ca = ca
 }
 
-func (s ServiceImpl) GetUsersEvents(pageToken string, size int) (map[string]models.EventsResponse, error) {
-	response := make(map[string]models.EventsResponse)
-	users, err := s.as.GetUsers()
+// GetUsersEvents returns a page of the events of all users merged into one stream ordered by start. The cursor
+// holds the position in the calendar of every user who has events left.
+func (s ServiceImpl) GetUsersEvents(ctx context.Context, cursor string, size int) (models.UserEventsResponse, error) {
+	position, err := s.cursors.decode(cursor)
 	if err != nil {
-		return nil, err
+		return models.UserEventsResponse{}, err
 	}
-	if len(users) == 0 {
-		return response, nil
+	users, err := s.as.GetUsers(ctx)
+	if err != nil {
+		return models.UserEventsResponse{}, err
 	}
-	ctx := context.Background()
-	for _, user := range users {
-		events, err := s.getUserEvents(ctx, &user, pageToken, size)
+
+	var streams []*eventStream
+	for i := range users {
+		user := &users[i]
+		sc := streamCursor{}
+		if position != nil {
+			var ok bool
+			sc, ok = position.Users[user.Id.String()]
+			if !ok {
+				continue
+			}
+		}
+		// stop between users once the caller gave up, a cancelled call may not reach every provider
+		err = ctx.Err()
 		if err != nil {
-			return nil, err
+			return models.UserEventsResponse{}, err
 		}
+		stream := &eventStream{
+			id: user.Id.String(), loc: user.Location(), next: sc.PageToken, offset: sc.Offset,
+			read: func(ctx context.Context, pageToken string) (models.Events, string, error) {
+				page, err := s.getUserEvents(ctx, user, pageToken, size)
+				return page.Items, page.NextPageToken, err
+			},
+		}
+		err = stream.readPage(ctx)
+		if err != nil {
+			return models.UserEventsResponse{}, err
+		}
+		streams = append(streams, stream)
+	}
+
+	var items models.UserEvents
+	for len(items) < size {
+		stream, err := earliestStream(ctx, streams)
+		if err != nil {
+			return models.UserEventsResponse{}, err
+		}
+		if stream == nil {
+			break
+		}
+		items = append(items, models.UserEvent{UserId: stream.id, Event: stream.events[stream.offset]})
+		stream.offset++
+	}
+
+	nextCursor, err := s.cursors.encode(usersCursor{Users: streamCursors(streams)})
+	if err != nil {
+		return models.UserEventsResponse{}, err
+	}
+
+	return models.UserEventsResponse{Items: items, NextPageToken: nextCursor}, nil
+}
+
+// eventStream is the page of the events of a user, or of an account, being merged. pageToken is the token the page
+// was read with, next the token of the following page, empty on the last one.
+type eventStream struct {
+	id        string
+	loc       *time.Location
+	read      func(ctx context.Context, pageToken string) (models.Events, string, error)
+	pageToken string
+	events    models.Events
+	offset    int
+	next      string
+}
+
+// readPage reads the page of the stream at its next token, keeping the offset reached within it.
+func (stream *eventStream) readPage(ctx context.Context) error {
+	events, next, err := stream.read(ctx, stream.next)
+	if err != nil {
+		return err
+	}
+	stream.pageToken = stream.next
+	stream.events = events
+	stream.next = next
+	// the calendar may have changed since the cursor was made
+	if stream.offset > len(stream.events) {
+		stream.offset = len(stream.events)
+	}
+	return nil
+}
+
+// earliestStream returns the stream whose next event starts first, reading the following page of the streams which
+// ran out of events, or nil when all are exhausted. Ties go to the stream listed first.
+func earliestStream(ctx context.Context, streams []*eventStream) (*eventStream, error) {
+	var earliest *eventStream
+	var earliestStart time.Time
+	for _, stream := range streams {
+		for stream.offset == len(stream.events) && stream.next != "" {
+			stream.offset = 0
+			err := stream.readPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if stream.offset == len(stream.events) {
+			continue
+		}
+		// all-day events start at midnight where the user is
+		start, _ := stream.events[stream.offset].StartIn(stream.loc)
+		if earliest == nil || start.Before(earliestStart) {
+			earliest = stream
+			earliestStart = start
+		}
+	}
+	return earliest, nil
+}
 
-		response[user.Id.String()] = events
+// streamCursors returns where to resume each stream which has events left.
+func streamCursors(streams []*eventStream) map[string]streamCursor {
+	cursors := make(map[string]streamCursor)
+	for _, stream := range streams {
+		switch {
+		case stream.offset < len(stream.events):
+			cursors[stream.id] = streamCursor{PageToken: stream.pageToken, Offset: stream.offset}
+		case stream.next != "":
+			cursors[stream.id] = streamCursor{PageToken: stream.next}
+		}
 	}
-	return response, nil
+	return cursors
 }
 
-func (s ServiceImpl) GetUserEvents(userId string, pageToken string, size int) (models.EventsResponse, error) {
-	user, err := s.as.GetUser(userId)
+func (s ServiceImpl) GetUserEvents(ctx context.Context, userId string, pageToken string, size int) (models.EventsResponse, error) {
+	user, err := s.as.GetUser(ctx, userId)
 	if err != nil {
 		return models.EventsResponse{}, err
 	}
 	if user == nil {
 		return models.EventsResponse{}, nil
 	}
-	ctx := context.Background()
 	events, err := s.getUserEvents(ctx, user, pageToken, size)
 	if err != nil {
 		return models.EventsResponse{}, err
@@ -66,26 +203,303 @@ func (s ServiceImpl) GetUserEvents(userId string, pageToken string, size int) (m
 	return events, nil
 }
 
+// GetUserEventsInRange returns the merged events of all accounts of the user overlapping the range, cancelled events
+// left out.
+func (s ServiceImpl) GetUserEventsInRange(ctx context.Context, userId string, from time.Time, to time.Time) (models.Events, error) {
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	return s.getUserEventsInRange(ctx, user, from, to, false)
+}
+
+// GetEventsInRangeForUser is GetUserEventsInRange for a user the caller already loaded.
+func (s ServiceImpl) GetEventsInRangeForUser(ctx context.Context, user *models.User, from time.Time, to time.Time) (models.Events, error) {
+	return s.getUserEventsInRange(ctx, user, from, to, false)
+}
+
+// GetEventsToSync returns the merged events of all accounts of the user overlapping the range, with the cancelled
+// ones, so the sync tells the user about the cancellation rather than seeing the event go.
+func (s ServiceImpl) GetEventsToSync(ctx context.Context, user *models.User, from time.Time, to time.Time) (models.Events, error) {
+	return s.getUserEventsInRange(ctx, user, from, to, true)
+}
+
+// GetTeamEvents merges the events of the members of the team overlapping the range, ordered by start. A meeting
+// several members attend shows once. All-day events start at midnight in the location. Members whose calendars
+// can't be read are left out, so one expired token doesn't hide the calendar of the whole team.
+func (s ServiceImpl) GetTeamEvents(ctx context.Context, teamId string, from time.Time, to time.Time, loc *time.Location) (models.TeamEvents, error) {
+	members, err := s.as.GetTeamMembers(ctx, teamId)
+	if err != nil {
+		return nil, err
+	}
+	if members == nil {
+		return nil, ErrTeamNotFound
+	}
+
+	result := models.TeamEvents{}
+	meetings := make(map[string]int)
+	for i := range members {
+		userId := members[i].Id.String()
+		events, err := s.getUserEventsInRange(ctx, &members[i], from, to, false)
+		if err != nil {
+			// the caller gave up, the other members can't be read either
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logging.FromContext(ctx, s.l).Warn("Unable to read the calendar of a team member", zap.String("teamId", teamId),
+				zap.String("userId", userId), zap.Error(err))
+			continue
+		}
+		for _, event := range events {
+			if event.ICalUID != "" {
+				key := eventKey(event)
+				if j, ok := meetings[key]; ok {
+					result[j].UserIds = append(result[j].UserIds, userId)
+					continue
+				}
+				meetings[key] = len(result)
+			}
+			result = append(result, models.TeamEvent{UserIds: []string{userId}, Event: event})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		si, _ := result[i].StartIn(loc)
+		sj, _ := result[j].StartIn(loc)
+		return si.Before(sj)
+	})
+	return result, nil
+}
+
+func (s ServiceImpl) getUserEventsInRange(ctx context.Context, user *models.User, from time.Time, to time.Time, withCancelled bool) (models.Events, error) {
+	var result models.Events
+	for i := range user.Accounts {
+		err := ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+		account := &user.Accounts[i]
+		c, tok, err := s.accountCalendar(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+
+		events, err := c.GetEventsInRange(ctx, *tok, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if events == nil {
+			continue
+		}
+		// left out before merging, a copy of a meeting cancelled on one account must not hide the one on another
+		if !withCancelled {
+			*events = withoutCancelled(*events)
+		}
+		result = append(result, onCalendar(*events, account)...)
+	}
+
+	return mergeEvents(result, user.Location()), nil
+}
+
+// getUserEvents merges the events of the accounts of the user into a page of at most size events. The page token
+// maps each account that has more events to where to resume them, accounts missing from it were exhausted on a
+// previous page. A meeting on several accounts shows once.
 func (s ServiceImpl) getUserEvents(ctx context.Context, user *models.User, pageToken string, size int) (models.EventsResponse, error) {
-	var result []models.Event
-	var tok *oauth2.Token
-	err := json.Unmarshal([]byte(*user.Token), &tok)
+	position, err := decodePageToken(pageToken)
 	if err != nil {
 		return models.EventsResponse{}, err
 	}
 
-	if tok.Expiry.Before(time.Now()) {
-		tok, err = s.as.RefreshUser(user)
+	var streams []*eventStream
+	for i := range user.Accounts {
+		account := &user.Accounts[i]
+		sc, ok := position[account.Id.String()]
+		if pageToken != "" && !ok {
+			continue
+		}
+		err = ctx.Err()
 		if err != nil {
 			return models.EventsResponse{}, err
 		}
+		stream := &eventStream{
+			id: account.Id.String(), loc: user.Location(), next: sc.PageToken, offset: sc.Offset,
+			read: func(ctx context.Context, pageToken string) (models.Events, string, error) {
+				events, next, err := s.getAccountEvents(ctx, account, pageToken, size)
+				// providers order all-day events by their own midnight
+				return mergeEvents(events, user.Location()), next, err
+			},
+		}
+		err = stream.readPage(ctx)
+		if err != nil {
+			return models.EventsResponse{}, err
+		}
+		streams = append(streams, stream)
 	}
 
-	events, npt, err := s.c.GetEventsForUser(ctx, *tok, pageToken, size)
+	var result models.Events
+	seen := make(map[string]bool)
+	for len(result) < size {
+		stream, err := earliestStream(ctx, streams)
+		if err != nil {
+			return models.EventsResponse{}, err
+		}
+		if stream == nil {
+			break
+		}
+		event := stream.events[stream.offset]
+		stream.offset++
+		if event.ICalUID != "" {
+			key := eventKey(event)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		result = append(result, event)
+	}
+	// the other copies of the last meetings start together with them, don't leave them for the next page
+	for _, stream := range streams {
+		for stream.offset < len(stream.events) && stream.events[stream.offset].ICalUID != "" &&
+			seen[eventKey(stream.events[stream.offset])] {
+			stream.offset++
+		}
+	}
 
-	if events == nil || len(*events) == 0 {
-		return models.EventsResponse{Items: result, NextPageToken: ""}, nil
+	npt, err := encodePageToken(streamCursors(streams))
+	if err != nil {
+		return models.EventsResponse{}, err
+	}
+
+	return models.EventsResponse{Items: result, NextPageToken: npt}, nil
+}
+
+func (s ServiceImpl) getAccountEvents(ctx context.Context, account *models.ConnectedAccount, pageToken string, size int) (models.Events, string, error) {
+	c, tok, err := s.accountCalendar(ctx, account)
+	if err != nil {
+		return nil, "", err
+	}
+
+	events, npt, err := c.GetEventsForUser(ctx, *tok, pageToken, size)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if events == nil {
+		return nil, npt, nil
 	}
 
-	return models.EventsResponse{Items: *events, NextPageToken: npt}, nil
+	return onCalendar(withoutCancelled(*events), account), npt, nil
+}
+
+// withoutCancelled filters out the cancelled events, which listings don't show.
+func withoutCancelled(events models.Events) models.Events {
+	var result models.Events
+	for _, event := range events {
+		if event.Status != models.EventCancelled {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// onCalendar sets the calendar of the events read from the account.
+func onCalendar(events models.Events, account *models.ConnectedAccount) models.Events {
+	if account.Email == nil {
+		return events
+	}
+	for i := range events {
+		events[i].Calendar = *account.Email
+	}
+	return events
+}
+
+// accountCalendar returns the calendar of the account's provider and a valid token, refreshing expired ones.
+func (s ServiceImpl) accountCalendar(ctx context.Context, account *models.ConnectedAccount) (calendar2.Calendar, *oauth2.Token, error) {
+	c, ok := s.cs[account.Provider]
+	if !ok {
+		return nil, nil, fmt.Errorf("calendar provider %q is not configured", account.Provider)
+	}
+
+	var tok *oauth2.Token
+	err := json.Unmarshal([]byte(*account.Token), &tok)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tok.Expiry.Before(time.Now()) {
+		tok, err = s.as.RefreshAccount(ctx, account)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return c, tok, nil
+}
+
+// mergeEvents orders events of several accounts by start and drops the copies of a meeting seen through more
+// than one account. All-day events start at midnight in the location.
+func mergeEvents(events models.Events, loc *time.Location) models.Events {
+	var result models.Events
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.ICalUID != "" {
+			key := eventKey(event)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		result = append(result, event)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		// Providers format times in different zones, so compare instants rather than strings.
+		si, _ := result[i].StartIn(loc)
+		sj, _ := result[j].StartIn(loc)
+		return si.Before(sj)
+	})
+	return result
+}
+
+// eventKey identifies an occurrence of a meeting across calendars. Instances of a recurring event share the
+// iCalUID, so the start is part of the key.
+func eventKey(event models.Event) string {
+	start, _ := event.StartTime()
+	return event.ICalUID + "|" + start.UTC().Format(time.RFC3339)
+}
+
+func decodePageToken(pageToken string) (map[string]streamCursor, error) {
+	pageTokens := make(map[string]streamCursor)
+	if pageToken == "" {
+		return pageTokens, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+	err = json.Unmarshal(b, &pageTokens)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+	for _, sc := range pageTokens {
+		if sc.Offset < 0 {
+			return nil, fmt.Errorf("invalid page token: negative offset %d", sc.Offset)
+		}
+	}
+	return pageTokens, nil
+}
+
+func encodePageToken(pageTokens map[string]streamCursor) (string, error) {
+	if len(pageTokens) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(pageTokens)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -2,17 +2,23 @@ package events
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
 	"golang.org/x/oauth2"
-	"log"
+	calendar2 "manny-reminder/internal/calendar"
 	"manny-reminder/internal/models"
 	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -24,7 +30,7 @@ func TestService_GetUsersEvents_WhenNoUsers_Empty(t *testing.T) {
 
 	mockAuthServiceGetUsers(as, models.Users{}, nil)
 
-	events, err := es.GetUsersEvents("", 10)
+	events, err := es.GetUsersEvents(context.Background(), "", 10)
 
 	assert.Nil(t, err)
 	assert.Empty(t, events)
@@ -37,11 +43,11 @@ func TestService_GetUsersEvents_WhenAsErr_Err(t *testing.T) {
 	var users []models.User
 	mockAuthServiceGetUsers(as, users, err)
 
-	events, err := es.GetUsersEvents("", 10)
+	events, err := es.GetUsersEvents(context.Background(), "", 10)
 
 	assert.Error(t, err)
 	assert.Exactly(t, err.Error(), test_error_msg)
-	assert.Nil(t, events)
+	assert.Empty(t, events)
 }
 
 func TestService_GetUsersEvents_WhenUsersAndNoEvents(t *testing.T) {
@@ -52,15 +58,11 @@ func TestService_GetUsersEvents_WhenUsersAndNoEvents(t *testing.T) {
 	mockAuthServiceGetUsers(as, users, nil)
 	mockCalendarGetEventsForUser(c, mockedEvents, nil)
 
-	events, err := es.GetUsersEvents("", 10)
+	events, err := es.GetUsersEvents(context.Background(), "", 10)
 
 	assert.Nil(t, err)
-	assert.NotEmpty(t, events)
-	assert.Exactly(t, 2, len(events))
-	for _, event := range events {
-		assert.Empty(t, event.Items)
-		assert.Exactly(t, event.NextPageToken, "")
-	}
+	assert.Empty(t, events.Items)
+	assert.Exactly(t, "", events.NextPageToken)
 }
 
 func TestService_GetUsersEvents_WhenUsersAndEvents(t *testing.T) {
@@ -71,30 +73,108 @@ func TestService_GetUsersEvents_WhenUsersAndEvents(t *testing.T) {
 
 	mockedEvents := make(map[string]models.Events)
 	user1Events := generateEvents("1", 3)
-	mockedEvents[*users[0].Token] = user1Events
+	mockedEvents[*users[0].Accounts[0].Token] = user1Events
 	user2Events := generateEvents("2", 2)
-	mockedEvents[*users[1].Token] = user2Events
+	mockedEvents[*users[1].Accounts[0].Token] = user2Events
 	user3Events := generateEvents("3", 0)
-	mockedEvents[*users[2].Token] = user3Events
+	mockedEvents[*users[2].Accounts[0].Token] = user3Events
 
 	mockCalendarGetEventsForUser(c, mockedEvents, nil)
 
-	events, err := es.GetUsersEvents("", 10)
+	events, err := es.GetUsersEvents(context.Background(), "", 10)
 
 	assert.Nil(t, err)
-	assert.NotEmpty(t, events)
-	assert.Exactly(t, 3, len(events))
+	assert.Exactly(t, 5, len(events.Items))
+	assert.Exactly(t, "", events.NextPageToken)
+
+	perUser := make(map[string]int)
+	for _, event := range events.Items {
+		perUser[event.UserId]++
+	}
+	assert.Exactly(t, 3, perUser[users[0].Id.String()])
+	assert.Exactly(t, 2, perUser[users[1].Id.String()])
+	assert.Exactly(t, 0, perUser[users[2].Id.String()])
+}
+
+func TestService_GetUsersEvents_MergesUsersByStartAcrossPages(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(2)
+	mockAuthServiceGetUsers(as, users, nil)
+	mockedEvents := make(map[string]models.Events)
+	mockedEvents[*users[0].Accounts[0].Token] = models.Events{
+		{Title: "A1", Start: "2022-06-01T09:00:00Z"},
+		{Title: "A2", Start: "2022-06-01T11:00:00Z"},
+		{Title: "A3", Start: "2022-06-01T13:00:00Z"},
+	}
+	mockedEvents[*users[1].Accounts[0].Token] = models.Events{
+		{Title: "B1", Start: "2022-06-01T12:00:00+02:00"},
+		{Title: "B2", Start: "2022-06-01T12:00:00Z"},
+	}
+	mockCalendarGetEventsForUser(c, mockedEvents, nil)
+
+	var titles []string
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		events, err := es.GetUsersEvents(context.Background(), cursor, 2)
+		assert.Nil(t, err)
+		for _, event := range events.Items {
+			titles = append(titles, event.Title)
+		}
+		cursor = events.NextPageToken
+		if cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"A1", "B1", "A2", "B2", "A3"}, titles)
+	assert.Exactly(t, "", cursor)
+}
 
-	assert.NotEmpty(t, events[users[0].Id.String()].Items)
-	assert.Exactly(t, 3, len(events[users[0].Id.String()].Items))
-	assert.Exactly(t, events[users[0].Id.String()].NextPageToken, "")
+func TestService_GetUsersEvents_ReadsNextProviderPageWithinPage(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(1)
+	mockAuthServiceGetUsers(as, users, nil)
+	c.On("GetEventsForUser", mock.Anything, mock.Anything, "", 3).
+		Return(&models.Events{{Title: "1", Start: "2022-06-01T09:00:00Z"}, {Title: "2", Start: "2022-06-01T10:00:00Z"}}, "p2", nil)
+	c.On("GetEventsForUser", mock.Anything, mock.Anything, "p2", 3).
+		Return(&models.Events{{Title: "3", Start: "2022-06-01T11:00:00Z"}, {Title: "4", Start: "2022-06-01T12:00:00Z"}}, "", nil)
+
+	events, err := es.GetUsersEvents(context.Background(), "", 3)
+
+	assert.Nil(t, err)
+	assert.Exactly(t, 3, len(events.Items))
+	assert.Equal(t, "3", events.Items[2].Title)
+	assert.NotEmpty(t, events.NextPageToken)
+
+	events, err = es.GetUsersEvents(context.Background(), events.NextPageToken, 3)
+
+	assert.Nil(t, err)
+	assert.Exactly(t, 1, len(events.Items))
+	assert.Equal(t, "4", events.Items[0].Title)
+	assert.Exactly(t, "", events.NextPageToken)
+}
 
-	assert.NotEmpty(t, events[users[1].Id.String()].Items)
-	assert.Exactly(t, 2, len(events[users[1].Id.String()].Items))
-	assert.Exactly(t, events[users[1].Id.String()].NextPageToken, "")
+func TestService_GetUsersEvents_TamperedCursor(t *testing.T) {
+	_, as, c, es := initService(t)
 
-	assert.Empty(t, events[users[2].Id.String()].Items)
-	assert.Exactly(t, events[users[2].Id.String()].NextPageToken, "")
+	users := generateUsers(1)
+	mockAuthServiceGetUsers(as, users, nil)
+	mockedEvents := make(map[string]models.Events)
+	mockedEvents[*users[0].Accounts[0].Token] = generateEvents("1", 3)
+	mockCalendarGetEventsForUser(c, mockedEvents, nil)
+	events, err := es.GetUsersEvents(context.Background(), "", 1)
+	assert.Nil(t, err)
+
+	other := NewService(nil, zap.NewNop(), as, nil)
+	_, err = other.GetUsersEvents(context.Background(), events.NextPageToken, 1)
+	assert.Error(t, err)
+
+	payload := strings.SplitN(events.NextPageToken, ".", 2)[0]
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"v":1,"u":{"x":{"o":5}}}`)) + "." + payload
+	_, err = es.GetUsersEvents(context.Background(), forged, 1)
+	assert.Error(t, err)
 }
 
 func TestService_GetUsersEvents_UserInvalidToken(t *testing.T) {
@@ -104,12 +184,12 @@ func TestService_GetUsersEvents_UserInvalidToken(t *testing.T) {
 	users := generateUsers(2)
 	mockedEvents := make(map[string]models.Events)
 	user1Events := generateEvents("1", 0)
-	users[0].Token = &userToken
+	users[0].Accounts[0].Token = &userToken
 	mockedEvents[userToken] = user1Events
 
 	mockAuthServiceGetUsers(as, users, nil)
 
-	events, err := es.GetUsersEvents("", 10)
+	events, err := es.GetUsersEvents(context.Background(), "", 10)
 
 	assert.NotNil(t, err)
 	assert.Empty(t, events)
@@ -120,7 +200,7 @@ func TestService_GetUserEvents_UserDoesNotExist(t *testing.T) {
 
 	mockAuthServiceGetUser(as, nil, nil)
 
-	events, err := es.GetUserEvents(uuid.New().String(), "", 10)
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
 
 	assert.Nil(t, err)
 	assert.Empty(t, events)
@@ -130,7 +210,7 @@ func TestService_GetUserEvents_GetUserErr(t *testing.T) {
 
 	mockAuthServiceGetUser(as, nil, errors.New(test_error_msg))
 
-	events, err := es.GetUserEvents(uuid.New().String(), "", 10)
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
 
 	assert.NotNil(t, err)
 	assert.Equal(t, test_error_msg, err.Error())
@@ -144,7 +224,7 @@ func TestService_GetUserEvents_NoUserEvents(t *testing.T) {
 	mockAuthServiceGetUser(as, &(users[0]), nil)
 	mockCalendarGetEventsForUser(c, make(map[string]models.Events), nil)
 
-	events, err := es.GetUserEvents(uuid.New().String(), "", 10)
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
 
 	assert.Nil(t, err)
 	assert.Empty(t, events)
@@ -155,7 +235,7 @@ func TestService_GetUserEvents_UserTokenExpired(t *testing.T) {
 
 	users := generateUsers(1)
 	expiredToken := generateUserToken(1, time.Now().Add(time.Hour*-2))
-	users[0].Token = &expiredToken
+	users[0].Accounts[0].Token = &expiredToken
 	var tok oauth2.Token
 	_ = json.Unmarshal([]byte(generateUserToken(1, time.Now().Add(time.Hour*2))), &tok)
 
@@ -165,10 +245,10 @@ func TestService_GetUserEvents_UserTokenExpired(t *testing.T) {
 	user1Events := generateEvents("1", 3)
 	mockedEvents[string(tokStr)] = user1Events
 	mockAuthServiceGetUser(as, &(users[0]), nil)
-	mockAuthServiceRefreshUser(as, &tok, nil)
+	mockAuthServiceRefreshAccount(as, &tok, nil)
 	mockCalendarGetEventsForUser(c, mockedEvents, nil)
 
-	events, err := es.GetUserEvents(uuid.New().String(), "", 10)
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
 
 	assert.Nil(t, err)
 	assert.NotEmpty(t, events)
@@ -180,12 +260,12 @@ func TestService_GetUserEvents_UserTokenExpiredInvalidRefresh(t *testing.T) {
 
 	users := generateUsers(1)
 	expiredToken := generateUserToken(1, time.Now().Add(time.Hour*-2))
-	users[0].Token = &expiredToken
+	users[0].Accounts[0].Token = &expiredToken
 
 	mockAuthServiceGetUser(as, &(users[0]), nil)
-	mockAuthServiceRefreshUser(as, nil, errors.New(test_error_msg))
+	mockAuthServiceRefreshAccount(as, nil, errors.New(test_error_msg))
 
-	events, err := es.GetUserEvents(uuid.New().String(), "", 10)
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
 
 	assert.NotNil(t, err)
 	assert.Equal(t, test_error_msg, err.Error())
@@ -198,36 +278,344 @@ func TestService_GetUserEvents_UserEvents(t *testing.T) {
 	users := generateUsers(1)
 	var mockedEvents = make(map[string]models.Events)
 	user1Events := generateEvents("1", 3)
-	mockedEvents[*users[0].Token] = user1Events
+	mockedEvents[*users[0].Accounts[0].Token] = user1Events
 
 	mockAuthServiceGetUser(as, &(users[0]), nil)
 	mockCalendarGetEventsForUser(c, mockedEvents, nil)
 
-	events, err := es.GetUserEvents(uuid.New().String(), "", 10)
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
 
 	assert.Nil(t, err)
 	assert.NotEmpty(t, events)
 	assert.Exactly(t, 3, len(events.Items))
 }
 
+func TestService_GetUserEvents_ProviderNotConfigured(t *testing.T) {
+	_, as, _, es := initService(t)
+
+	users := generateUsers(1)
+	users[0].Accounts[0].Provider = models.ProviderMicrosoft
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+
+	events, err := es.GetUserEvents(context.Background(), uuid.New().String(), "", 10)
+
+	assert.NotNil(t, err)
+	assert.Empty(t, events)
+}
+
+func TestService_GetUserEvents_MergesAccounts(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(1)
+	accountId, _ := uuid.NewUUID()
+	secondToken := generateUserToken(1, time.Now().Add(time.Hour*2))
+	users[0].Accounts = append(users[0].Accounts, models.ConnectedAccount{
+		Id: &accountId, UserId: users[0].Id, Provider: models.ProviderGoogle, Token: &secondToken,
+	})
+
+	mockedEvents := make(map[string]models.Events)
+	mockedEvents[*users[0].Accounts[0].Token] = models.Events{
+		{ICalUID: "shared", Title: "Shared", Start: "2022-06-01T10:00:00+02:00"},
+		{ICalUID: "work", Title: "Work", Start: "2022-06-01T12:00:00+02:00"},
+	}
+	mockedEvents[secondToken] = models.Events{
+		{ICalUID: "shared", Title: "Shared", Start: "2022-06-01T08:00:00Z"},
+		{ICalUID: "personal", Title: "Personal", Start: "2022-06-01T09:00:00Z"},
+	}
+
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+	mockCalendarGetEventsForUser(c, mockedEvents, nil)
+
+	events, err := es.GetUserEvents(context.Background(), users[0].Id.String(), "", 10)
+
+	assert.Nil(t, err)
+	assert.Exactly(t, 3, len(events.Items))
+	assert.Equal(t, "Shared", events.Items[0].Title)
+	assert.Equal(t, "Personal", events.Items[1].Title)
+	assert.Equal(t, "Work", events.Items[2].Title)
+	assert.Exactly(t, "", events.NextPageToken)
+}
+
+func TestService_GetUserEvents_AllDayEventsStartWhereUserIs(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(1)
+	timeZone := "America/Los_Angeles"
+	users[0].TimeZone = &timeZone
+	mockedEvents := make(map[string]models.Events)
+	mockedEvents[*users[0].Accounts[0].Token] = models.Events{
+		{Title: "Holiday", Start: "2022-06-02", End: "2022-06-03"},
+		{Title: "Late call", Start: "2022-06-01T20:00:00-07:00"},
+		{Title: "Breakfast", Start: "2022-06-02T08:00:00-07:00"},
+	}
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+	mockCalendarGetEventsForUser(c, mockedEvents, nil)
+
+	events, err := es.GetUserEvents(context.Background(), users[0].Id.String(), "", 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Late call", events.Items[0].Title)
+	assert.Equal(t, "Holiday", events.Items[1].Title)
+	assert.Equal(t, "Breakfast", events.Items[2].Title)
+}
+
+func TestService_GetUserEvents_PagesOnlyRemainingAccounts(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(1)
+	accountId, _ := uuid.NewUUID()
+	secondToken := generateUserToken(1, time.Now().Add(time.Hour*2))
+	users[0].Accounts = append(users[0].Accounts, models.ConnectedAccount{
+		Id: &accountId, UserId: users[0].Id, Provider: models.ProviderGoogle, Token: &secondToken,
+	})
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+	first := mock.MatchedBy(func(tok oauth2.Token) bool { return tok.AccessToken == "test 1" })
+	second := mock.MatchedBy(func(tok oauth2.Token) bool { return tok.AccessToken == "test 2" })
+	c.On("GetEventsForUser", mock.Anything, first, "", 2).Return(&models.Events{
+		{Title: "A1", Start: "2022-06-01T09:00:00Z"}, {Title: "A2", Start: "2022-06-01T11:00:00Z"},
+	}, "", nil).Twice()
+	c.On("GetEventsForUser", mock.Anything, second, "", 2).Return(&models.Events{
+		{Title: "B1", Start: "2022-06-01T10:00:00Z"},
+	}, "second-page", nil).Once()
+	c.On("GetEventsForUser", mock.Anything, second, "second-page", 2).Return(&models.Events{
+		{Title: "B2", Start: "2022-06-01T12:00:00Z"},
+	}, "", nil).Once()
+
+	events, err := es.GetUserEvents(context.Background(), users[0].Id.String(), "", 2)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"A1", "B1"}, titles(events.Items))
+	assert.NotEmpty(t, events.NextPageToken)
+
+	events, err = es.GetUserEvents(context.Background(), users[0].Id.String(), events.NextPageToken, 2)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"A2", "B2"}, titles(events.Items))
+	assert.Exactly(t, "", events.NextPageToken)
+	// the first page of B was done with, only its second one is read again
+	c.AssertNumberOfCalls(t, "GetEventsForUser", 4)
+}
+
+func TestService_GetUserEvents_MeetingOnSeveralAccountsShowsOnceAcrossPages(t *testing.T) {
+	_, as, c, es := initService(t)
+
+	users := generateUsers(1)
+	accountId, _ := uuid.NewUUID()
+	secondToken := generateUserToken(1, time.Now().Add(time.Hour*2))
+	users[0].Accounts = append(users[0].Accounts, models.ConnectedAccount{
+		Id: &accountId, UserId: users[0].Id, Provider: models.ProviderGoogle, Token: &secondToken,
+	})
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+	mockCalendarGetEventsForUser(c, map[string]models.Events{
+		*users[0].Accounts[0].Token: {{ICalUID: "shared", Title: "Shared", Start: "2022-06-01T10:00:00+02:00"}},
+		secondToken: {
+			{ICalUID: "shared", Title: "Shared", Start: "2022-06-01T08:00:00Z"},
+			{ICalUID: "personal", Title: "Personal", Start: "2022-06-01T09:00:00Z"},
+		},
+	}, nil)
+
+	var pages [][]string
+	pageToken := ""
+	for {
+		events, err := es.GetUserEvents(context.Background(), users[0].Id.String(), pageToken, 1)
+		assert.Nil(t, err)
+		pages = append(pages, titles(events.Items))
+		pageToken = events.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, [][]string{{"Shared"}, {"Personal"}}, pages)
+}
+
+func TestService_GetUsersEvents_CancelledStopsRemainingUsers(t *testing.T) {
+	_, as, c, es := initService(t)
+	users := generateUsers(3)
+	mockAuthServiceGetUsers(as, users, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	// the client goes away while the first calendar is being read
+	c.On("GetEventsForUser", mock.Anything, mock.Anything, "", 10).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&models.Events{}, "", nil)
+
+	events, err := es.GetUsersEvents(ctx, "", 10)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, events)
+	c.AssertNumberOfCalls(t, "GetEventsForUser", 1)
+}
+
+func TestService_GetUserEventsInRange_PassesDeadlineToCalendar(t *testing.T) {
+	_, as, c, es := initService(t)
+	users := generateUsers(1)
+	mockAuthServiceGetUser(as, &users[0], nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	c.On("GetEventsInRange", mock.MatchedBy(func(callCtx context.Context) bool {
+		deadline, ok := callCtx.Deadline()
+		expected, _ := ctx.Deadline()
+		return ok && deadline.Equal(expected)
+	}), mock.Anything, mock.Anything, mock.Anything).Return(&models.Events{}, nil)
+
+	_, err := es.GetUserEventsInRange(ctx, users[0].Id.String(), time.Now(), time.Now().Add(time.Hour))
+
+	assert.Nil(t, err)
+}
+
+func TestService_GetUserEventsInRange_LeavesCancelledToTheSync(t *testing.T) {
+	_, as, c, es := initService(t)
+	users := generateUsers(1)
+	accountId, _ := uuid.NewUUID()
+	secondToken := generateUserToken(1, time.Now().Add(time.Hour*2))
+	users[0].Accounts = append(users[0].Accounts, models.ConnectedAccount{
+		Id: &accountId, UserId: users[0].Id, Provider: models.ProviderGoogle, Token: &secondToken,
+	})
+	cancelled := acceptedEvent("shared", "Planning", "2022-06-01T09:00:00Z", "2022-06-01T10:00:00Z")
+	cancelled.Status = models.EventCancelled
+	mockAuthServiceGetUser(as, &users[0], nil)
+	// the meeting was cancelled on the first account, the second one is still invited to it
+	mockCalendarGetEventsInRange(c, map[string]models.Events{
+		*users[0].Accounts[0].Token: {cancelled},
+		secondToken:                 {acceptedEvent("shared", "Planning", "2022-06-01T09:00:00Z", "2022-06-01T10:00:00Z")},
+	}, nil)
+
+	listed, err := es.GetUserEventsInRange(context.Background(), users[0].Id.String(), time.Now(), time.Now().Add(time.Hour))
+
+	assert.Nil(t, err)
+	assert.Len(t, listed, 1)
+	assert.NotEqual(t, models.EventCancelled, listed[0].Status)
+
+	synced, err := es.GetEventsToSync(context.Background(), &users[0], time.Now(), time.Now().Add(time.Hour))
+
+	assert.Nil(t, err)
+	assert.Len(t, synced, 1)
+	assert.Equal(t, models.EventCancelled, synced[0].Status)
+}
+
+func TestService_GetTeamEvents_MeetingOfSeveralMembersShowsOnce(t *testing.T) {
+	_, as, c, es := initService(t)
+	users := generateUsers(2)
+	shared := acceptedEvent("shared", "Planning", "2022-06-01T09:00:00Z", "2022-06-01T10:00:00Z")
+	mockedEvents := map[string]models.Events{
+		*users[0].Accounts[0].Token: {shared, acceptedEvent("late", "Late", "2022-06-01T15:00:00Z", "2022-06-01T16:00:00Z")},
+		*users[1].Accounts[0].Token: {acceptedEvent("early", "Early", "2022-06-01T08:00:00Z", "2022-06-01T08:30:00Z"), shared},
+	}
+	as.On("GetTeamMembers", mock.Anything, "team").Return([]models.User(users), nil)
+	mockCalendarGetEventsInRange(c, mockedEvents, nil)
+
+	events, err := es.GetTeamEvents(context.Background(), "team", time.Now(), time.Now().Add(time.Hour), time.UTC)
+
+	assert.Nil(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, "Early", events[0].Title)
+	assert.Equal(t, []string{users[1].Id.String()}, events[0].UserIds)
+	assert.Equal(t, "Planning", events[1].Title)
+	assert.Equal(t, []string{users[0].Id.String(), users[1].Id.String()}, events[1].UserIds)
+	assert.Equal(t, "Late", events[2].Title)
+}
+
+func TestService_GetTeamEvents_LeavesOutMemberWhoseCalendarFails(t *testing.T) {
+	_, as, c, es := initService(t)
+	users := generateUsers(2)
+	as.On("GetTeamMembers", mock.Anything, "team").Return([]models.User(users), nil)
+	c.On("GetEventsInRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, tok oauth2.Token, _ time.Time, _ time.Time) *models.Events {
+			return &models.Events{acceptedEvent("standup", "Standup", "2022-06-01T09:00:00Z", "2022-06-01T09:15:00Z")}
+		},
+		func(_ context.Context, tok oauth2.Token, _ time.Time, _ time.Time) error {
+			if tok.AccessToken == "test 1" {
+				return errors.New(test_error_msg)
+			}
+			return nil
+		})
+
+	events, err := es.GetTeamEvents(context.Background(), "team", time.Now(), time.Now().Add(time.Hour), time.UTC)
+
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, []string{users[1].Id.String()}, events[0].UserIds)
+}
+
+func TestService_GetTeamEvents_UnknownTeam(t *testing.T) {
+	_, as, _, es := initService(t)
+	as.On("GetTeamMembers", mock.Anything, "team").Return(nil, nil)
+
+	events, err := es.GetTeamEvents(context.Background(), "team", time.Now(), time.Now().Add(time.Hour), time.UTC)
+
+	assert.ErrorIs(t, err, ErrTeamNotFound)
+	assert.Nil(t, events)
+}
+
+func TestHandler_GetTeamEvents(t *testing.T) {
+	es := mocks.NewEventsService(t)
+	es.On("GetTeamEvents", mock.Anything, "empty", mock.Anything, mock.Anything, mock.Anything).Return(models.TeamEvents{}, nil)
+	es.On("GetTeamEvents", mock.Anything, "missing", mock.Anything, mock.Anything, mock.Anything).Return(nil, ErrTeamNotFound)
+	router := mux.NewRouter()
+	router.HandleFunc("/teams/{teamId}/events", NewHandler(es, mocks.NewAuthService(t)).GetTeamEvents)
+
+	empty := httptest.NewRecorder()
+	router.ServeHTTP(empty, httptest.NewRequest(http.MethodGet, "/teams/empty/events", nil))
+	missing := httptest.NewRecorder()
+	router.ServeHTTP(missing, httptest.NewRequest(http.MethodGet, "/teams/missing/events", nil))
+
+	assert.Equal(t, http.StatusOK, empty.Code)
+	assert.JSONEq(t, "[]", empty.Body.String())
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+}
+
+func TestService_GetUserEvents_InvalidPageToken(t *testing.T) {
+	_, as, _, es := initService(t)
+
+	users := generateUsers(1)
+	mockAuthServiceGetUser(as, &(users[0]), nil)
+
+	events, err := es.GetUserEvents(context.Background(), users[0].Id.String(), "not a token", 10)
+
+	assert.Error(t, err)
+	assert.Empty(t, events)
+}
+
+func TestService_GetDeliveries_DefaultsLimit(t *testing.T) {
+	er, _, _, es := initService(t)
+	er.On("GetDeliveries", mock.Anything, models.DeliveryFilter{Status: models.DeliveryFailed, Limit: defaultDeliveriesLimit}).
+		Return(nil, nil)
+
+	deliveries, err := es.GetDeliveries(context.Background(), models.DeliveryFilter{Status: models.DeliveryFailed})
+
+	assert.Nil(t, err)
+	assert.Equal(t, models.Deliveries{}, deliveries)
+}
+
+func TestService_GetDeliveries_InvalidFilter(t *testing.T) {
+	_, _, _, es := initService(t)
+
+	for _, filter := range []models.DeliveryFilter{{Status: "lost"}, {Limit: maxDeliveriesLimit + 1}} {
+		deliveries, err := es.GetDeliveries(context.Background(), filter)
+
+		assert.Error(t, err)
+		assert.Nil(t, deliveries)
+	}
+}
+
 func initService(t *testing.T) (*mocks.EventsRepository, *mocks.AuthService, *mocks.Calendar, *ServiceImpl) {
 	er := mocks.NewEventsRepository(t)
 	as := mocks.NewAuthService(t)
 	c := mocks.NewCalendar(t)
-	es := NewService(er, log.Default(), as, c)
+	es := NewService(er, zap.NewNop(), as, calendar2.Calendars{models.ProviderGoogle: c})
 	return er, as, c, es
 }
 
 func mockAuthServiceGetUsers(as *mocks.AuthService, users []models.User, err error) {
-	as.On("GetUsers").Return(users, err)
+	as.On("GetUsers", mock.Anything).Return(users, err)
 }
 
 func mockAuthServiceGetUser(as *mocks.AuthService, user *models.User, err error) {
-	as.On("GetUser", mock.Anything).Return(user, err)
+	as.On("GetUser", mock.Anything, mock.Anything).Return(user, err)
 }
 
-func mockAuthServiceRefreshUser(as *mocks.AuthService, tok *oauth2.Token, err error) {
-	as.On("RefreshUser", mock.Anything).Return(tok, err)
+func mockAuthServiceRefreshAccount(as *mocks.AuthService, tok *oauth2.Token, err error) {
+	as.On("RefreshAccount", mock.Anything, mock.Anything).Return(tok, err)
 }
 
 func mockCalendarGetEventsForUser(c *mocks.Calendar, events map[string]models.Events, err error) {
@@ -253,6 +641,14 @@ func mockCalendarGetEventsForUser(c *mocks.Calendar, events map[string]models.Ev
 		})
 }
 
+func titles(events models.Events) []string {
+	var result []string
+	for _, event := range events {
+		result = append(result, event.Title)
+	}
+	return result
+}
+
 func generateEvents(prefix string, count int) models.Events {
 	var events models.Events
 	for i := 0; i < count; i++ {
@@ -270,7 +666,9 @@ func generateUsers(amount int) models.Users {
 	for i := 0; i < amount; i++ {
 		id, _ := uuid.NewUUID()
 		userToken := generateUserToken(i, time.Now().Add(time.Hour*2))
-		users = append(users, models.User{Id: &id, Token: &userToken})
+		accountId, _ := uuid.NewUUID()
+		account := models.ConnectedAccount{Id: &accountId, UserId: &id, Provider: models.ProviderGoogle, Token: &userToken}
+		users = append(users, models.User{Id: &id, Accounts: models.ConnectedAccounts{account}})
 	}
 	return users
 }
@@ -0,0 +1,28 @@
+package health
+
+import (
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+type HandlerImpl struct {
+	hs HealthService
+}
+
+func NewHandler(hs HealthService) *HandlerImpl {
+	return &HandlerImpl{hs: hs}
+}
+
+// Live only tells the process is up and serving, dependencies are left to Ready.
+func (h HandlerImpl) Live(w http.ResponseWriter, _ *http.Request) {
+	utils.SendJson(w, map[string]string{"status": StatusUp})
+}
+
+func (h HandlerImpl) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.hs.Ready(r.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	utils.SendJsonWithStatus(w, status, report)
+}
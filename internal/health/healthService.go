@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	checkTimeout = 2 * time.Second
+)
+
+// Check reports whether a dependency is usable, returning the reason when it isn't.
+type Check func(ctx context.Context) error
+
+type HealthService interface {
+	Register(name string, check Check)
+	Ready(ctx context.Context) Report
+	SetShuttingDown()
+}
+
+// Report is the readiness of the service with the status of each dependency.
+type Report struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckStatus `json:"checks"`
+}
+
+type CheckStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ServiceImpl struct {
+	l            *zap.Logger
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown int32
+}
+
+func NewService(l *zap.Logger) *ServiceImpl {
+	return &ServiceImpl{l: l, checks: make(map[string]Check)}
+}
+
+func (s *ServiceImpl) Register(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+// SetShuttingDown makes the service report not ready from now on, so no new traffic is routed to it while the
+// server drains.
+func (s *ServiceImpl) SetShuttingDown() {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+}
+
+// Ready runs every check concurrently, each bounded by checkTimeout.
+func (s *ServiceImpl) Ready(ctx context.Context) Report {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	statuses := make([]CheckStatus, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			statuses[i] = s.run(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := Report{Ready: true, Checks: make(map[string]CheckStatus)}
+	if atomic.LoadInt32(&s.shuttingDown) == 1 {
+		report.Ready = false
+		report.Checks["shutdown"] = CheckStatus{Status: StatusDown, Error: "server is shutting down"}
+	}
+	for i, name := range names {
+		report.Checks[name] = statuses[i]
+		if statuses[i].Status != StatusUp {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func (s *ServiceImpl) run(ctx context.Context, name string) CheckStatus {
+	s.mu.RLock()
+	check := s.checks[name]
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	err := check(ctx)
+	if err != nil {
+		s.l.Warn("Health check failed", zap.String("check", name), zap.Error(err))
+		return CheckStatus{Status: StatusDown, Error: err.Error()}
+	}
+	return CheckStatus{Status: StatusUp}
+}
+
+// Heartbeat is beaten by a background loop on every run, its check fails when the loop hasn't run within maxAge.
+type Heartbeat struct {
+	maxAge time.Duration
+	last   int64
+}
+
+func NewHeartbeat(maxAge time.Duration) *Heartbeat {
+	return &Heartbeat{maxAge: maxAge}
+}
+
+func (h *Heartbeat) Beat() {
+	atomic.StoreInt64(&h.last, time.Now().UnixNano())
+}
+
+func (h *Heartbeat) Check(_ context.Context) error {
+	last := atomic.LoadInt64(&h.last)
+	if last == 0 {
+		return errors.New("no heartbeat yet")
+	}
+	age := time.Since(time.Unix(0, last))
+	if age > h.maxAge {
+		return fmt.Errorf("last heartbeat %s ago", age.Truncate(time.Second))
+	}
+	return nil
+}
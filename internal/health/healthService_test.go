@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReady_AllChecksUp(t *testing.T) {
+	hs := NewService(zap.NewNop())
+	hs.Register("postgres", up)
+	hs.Register("oauth", up)
+
+	report := hs.Ready(context.Background())
+
+	assert.True(t, report.Ready)
+	assert.Equal(t, map[string]CheckStatus{
+		"postgres": {Status: StatusUp},
+		"oauth":    {Status: StatusUp},
+	}, report.Checks)
+}
+
+func TestReady_CheckDown(t *testing.T) {
+	hs := NewService(zap.NewNop())
+	hs.Register("postgres", func(_ context.Context) error { return errors.New("connection refused") })
+	hs.Register("oauth", up)
+
+	report := hs.Ready(context.Background())
+
+	assert.False(t, report.Ready)
+	assert.Equal(t, CheckStatus{Status: StatusDown, Error: "connection refused"}, report.Checks["postgres"])
+	assert.Equal(t, CheckStatus{Status: StatusUp}, report.Checks["oauth"])
+}
+
+func TestReady_CheckTimesOut(t *testing.T) {
+	hs := NewService(zap.NewNop())
+	hs.Register("postgres", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	report := hs.Ready(ctx)
+
+	assert.False(t, report.Ready)
+	assert.Equal(t, StatusDown, report.Checks["postgres"].Status)
+}
+
+func TestReady_ShuttingDown(t *testing.T) {
+	hs := NewService(zap.NewNop())
+	hs.Register("postgres", up)
+	hs.SetShuttingDown()
+
+	report := hs.Ready(context.Background())
+
+	assert.False(t, report.Ready)
+	assert.Equal(t, StatusDown, report.Checks["shutdown"].Status)
+}
+
+func TestHeartbeat(t *testing.T) {
+	h := NewHeartbeat(time.Minute)
+
+	assert.Error(t, h.Check(context.Background()))
+
+	h.Beat()
+	assert.Nil(t, h.Check(context.Background()))
+
+	h.last = time.Now().Add(-2 * time.Minute).UnixNano()
+	assert.Error(t, h.Check(context.Background()))
+}
+
+func TestHandler_ReadyStatusCode(t *testing.T) {
+	hs := NewService(zap.NewNop())
+	hs.Register("scheduler", NewHeartbeat(time.Minute).Check)
+	h := NewHandler(hs)
+
+	w := httptest.NewRecorder()
+	h.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"scheduler":{"status":"down","error":"no heartbeat yet"}`)
+}
+
+func up(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"manny-reminder/internal/utils"
+	"net/http"
+	"time"
+	"unicode"
+)
+
+const (
+	RequestIdHeader = "X-Request-ID"
+
+	// maxRequestIdLength bounds the ids accepted from clients, longer ones are replaced by a generated id.
+	maxRequestIdLength = 128
+)
+
+type contextKey int
+
+const (
+	requestIdKey contextKey = iota
+	loggerKey
+)
+
+// New builds the JSON logger of the service writing to stdout, level is one of debug, info, warn or error.
+func New(level string) (*zap.Logger, error) {
+	return build(level, "stdout")
+}
+
+// NewCommand builds the logger of the admin commands, the same as New's but writing to stderr so it doesn't mix with
+// the output of the command.
+func NewCommand(level string) (*zap.Logger, error) {
+	return build(level, "stderr")
+}
+
+func build(level string, output string) (*zap.Logger, error) {
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, err
+	}
+
+	c := zap.NewProductionConfig()
+	c.Level = zap.NewAtomicLevelAt(lvl)
+	c.Sampling = nil
+	c.EncoderConfig.TimeKey = "time"
+	c.EncoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	c.OutputPaths = []string{output}
+	c.ErrorOutputPaths = []string{"stderr"}
+	l, err := c.Build()
+	if err != nil {
+		return nil, err
+	}
+	return l.With(zap.String("service", "manny-reminder")), nil
+}
+
+// Middleware gives every request an id, taken from the X-Request-ID header when the client sent a usable one, echoes
+// it in the response and puts it in the context together with a logger tagging every line with it, and with the
+// trace id when a tracing middleware ran before.
+func Middleware(l *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIdHeader)
+			if !validRequestId(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIdHeader, id)
+
+			rl := l.With(zap.String("requestId", id))
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				rl = rl.With(zap.Stringer("traceId", sc.TraceID()))
+			}
+			ctx := WithLogger(WithRequestId(r.Context(), id), rl)
+			sw := utils.NewStatusWriter(w)
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			rl.Info("Request served",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", sw.Status),
+				zap.Duration("duration", time.Since(start)))
+		})
+	}
+}
+
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey, id)
+}
+
+// RequestId returns the id of the request the context belongs to, empty outside of a request.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey).(string)
+	return id
+}
+
+func WithLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the request scoped logger of the context, or fallback when there is none.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_PropagatesRequestId(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	h := Middleware(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc-123", RequestId(r.Context()))
+		FromContext(r.Context(), zap.NewNop()).Info("Handling")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(RequestIdHeader, "abc-123")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, "abc-123", rec.Header().Get(RequestIdHeader))
+	entries := logs.All()
+	assert.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "abc-123", e.ContextMap()["requestId"])
+	}
+	assert.Equal(t, int64(http.StatusTeapot), entries[1].ContextMap()["status"])
+}
+
+func TestMiddleware_GeneratesRequestId(t *testing.T) {
+	var seen string
+	h := Middleware(zap.NewNop())(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = RequestId(r.Context())
+	}))
+
+	for _, incoming := range []string{"", strings.Repeat("a", 200), "bad\nid"} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set(RequestIdHeader, incoming)
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.NotEmpty(t, seen)
+		assert.NotEqual(t, incoming, seen)
+		assert.Equal(t, seen, rec.Header().Get(RequestIdHeader))
+	}
+}
+
+func TestNew_InvalidLevel(t *testing.T) {
+	_, err := New("loud")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"manny-reminder/internal/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeQuota   = "quota"
+
+	ReminderScheduled = "scheduled"
+	ReminderSent      = "sent"
+	ReminderFailed    = "failed"
+	// reminders held back by the quiet hours of the user
+	ReminderDeferred = "deferred"
+	ReminderDropped  = "dropped"
+	// escalation steps not sent as the user acknowledged an earlier one
+	ReminderStopped = "stopped"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "manny_http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "manny_http_request_duration_seconds",
+		Help:    "HTTP request latencies by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	calendarRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "manny_calendar_requests_total",
+		Help: "Calendar provider API calls by operation and outcome, quota counts rate limited and quota exceeded responses.",
+	}, []string{"provider", "operation", "outcome"})
+
+	calendarDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "manny_calendar_request_duration_seconds",
+		Help:    "Calendar provider API call latencies by operation.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider", "operation"})
+
+	tokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "manny_token_refreshes_total",
+		Help: "OAuth token refreshes by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	syncLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "manny_sync_lag_seconds",
+		Help: "Time since the events of a user were last synced.",
+	}, []string{"user"})
+
+	reminders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "manny_reminders_total",
+		Help: "Reminders by channel and status: scheduled, sent, failed, deferred, dropped or stopped.",
+	}, []string{"channel", "status"})
+)
+
+// Middleware records the count and latency of requests per route template, so paths with ids share a series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := utils.NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.Status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+func ObserveCalendarRequest(provider string, operation string, outcome string, duration time.Duration) {
+	calendarRequests.WithLabelValues(provider, operation, outcome).Inc()
+	calendarDuration.WithLabelValues(provider, operation).Observe(duration.Seconds())
+}
+
+func ObserveTokenRefresh(provider string, outcome string) {
+	tokenRefreshes.WithLabelValues(provider, outcome).Inc()
+}
+
+func SetSyncLag(userId string, lag time.Duration) {
+	syncLag.WithLabelValues(userId).Set(lag.Seconds())
+}
+
+func DeleteSyncLag(userId string) {
+	syncLag.DeleteLabelValues(userId)
+}
+
+func ObserveReminder(channel string, status string) {
+	reminders.WithLabelValues(channel, status).Inc()
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	sm := mux.NewRouter()
+	sm.Use(Middleware)
+	sm.HandleFunc("/users/{userId}/events", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for _, path := range []string{"/users/1/events", "/users/2/events"} {
+		sm.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequests.WithLabelValues("/users/{userId}/events", http.MethodGet, "418")))
+}
+
+func TestObserveReminder(t *testing.T) {
+	ObserveReminder("slack", ReminderSent)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(reminders.WithLabelValues("slack", ReminderSent)))
+}
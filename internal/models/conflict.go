@@ -0,0 +1,18 @@
+package models
+
+// Conflict is a pair of overlapping accepted events on a user's calendar.
+type Conflict struct {
+	UserId  string `json:"userId"`
+	First   Event  `json:"first"`
+	Second  Event  `json:"second"`
+	Overlap string `json:"overlap"`
+}
+
+type Conflicts []Conflict
+
+// SharedConflict is a meeting on the calendars of several users which overlaps other events of some of them.
+type SharedConflict struct {
+	Meeting   Event     `json:"meeting"`
+	UserIds   []string  `json:"userIds"`
+	Conflicts Conflicts `json:"conflicts"`
+}
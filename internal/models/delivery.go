@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Statuses of a reminder delivery. A delivery is sending while a scheduler holds its claim.
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliverySent      = "sent"
+	DeliveryFailed    = "failed"
+	DeliveryCancelled = "cancelled"
+	// DeliveryAcknowledged is a sent delivery the user marked as done
+	DeliveryAcknowledged = "acknowledged"
+)
+
+// Reasons a delivery was cancelled. Only the deliveries cancelled because their event moved, was cancelled or declined
+// are scheduled again when the event comes back to their start, the others were stopped for good.
+const (
+	CancelEventChanged = "event_changed"
+	// CancelAcknowledged is a delivery of an occurrence the user marked as done
+	CancelAcknowledged = "acknowledged"
+	// CancelQuietHours is a delivery dropped as it came due during the quiet hours of the user
+	CancelQuietHours = "quiet_hours"
+	// CancelExpired is a delivery left over once its event ended or its user is gone
+	CancelExpired = "expired"
+)
+
+// Delivery is a reminder of an event sent, or to be sent, to a user over a channel. It is unique per user, event,
+// event start, offset and channel, so a reminder is never sent twice.
+type Delivery struct {
+	Id            *uuid.UUID `json:"id"`
+	UserId        *uuid.UUID `json:"userId"`
+	EventId       string     `json:"eventId"`
+	EventStart    time.Time  `json:"eventStart"`
+	OffsetMinutes int        `json:"offsetMinutes"`
+	Channel       string     `json:"channel"`
+	SendAt        time.Time  `json:"sendAt"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	ClaimedAt     *time.Time `json:"claimedAt,omitempty"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+	// Snoozes counts how many times the user snoozed the delivery, each snooze sends it again
+	Snoozes int `json:"snoozes"`
+	// EscalationStep is the index of the delivery in the escalation chain of an important event, nil for plain
+	// reminders
+	EscalationStep *int       `json:"escalationStep,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	CancelReason   string     `json:"cancelReason,omitempty"`
+}
+
+type Deliveries []Delivery
+
+// DeliveryFilter selects deliveries, empty fields match everything. From and To bound the send time.
+type DeliveryFilter struct {
+	UserId  string
+	EventId string
+	Channel string
+	Status  string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// DeviceCode is a pending OAuth device authorization (RFC 8628): the user enters UserCode at VerificationURI, on any
+// device with a browser, while the service polls the token endpoint with the device code. The account is linked to
+// UserId, or to a new user when it's empty.
+type DeviceCode struct {
+	Provider                string        `json:"provider"`
+	UserId                  string        `json:"userId,omitempty"`
+	DeviceCode              string        `json:"-"`
+	UserCode                string        `json:"userCode"`
+	VerificationURI         string        `json:"verificationUri"`
+	VerificationURIComplete string        `json:"verificationUriComplete,omitempty"`
+	ExpiresAt               time.Time     `json:"expiresAt"`
+	Interval                time.Duration `json:"-"`
+}
@@ -1,11 +1,83 @@
 package models
 
+import "time"
+
+// Response statuses of the calendar's owner to an event, as named by Google.
+const (
+	ResponseAccepted    = "accepted"
+	ResponseTentative   = "tentative"
+	ResponseDeclined    = "declined"
+	ResponseNeedsAction = "needsAction"
+)
+
+// Statuses of an event itself, as named by Google.
+const (
+	EventConfirmed = "confirmed"
+	EventTentative = "tentative"
+	EventCancelled = "cancelled"
+)
+
+// Event is an event of a calendar. Id is the provider's id of the event in that calendar, every occurrence of a
+// recurring event has its own.
 type Event struct {
-	Title     string   `json:"title"`
-	Start     string   `json:"start"`
-	End       string   `json:"end"`
-	Organizer string   `json:"organizer"`
-	Attendees []string `json:"attendees"`
+	Id             string   `json:"id"`
+	ICalUID        string   `json:"iCalUID"`
+	Title          string   `json:"title"`
+	Start          string   `json:"start"`
+	End            string   `json:"end"`
+	Organizer      string   `json:"organizer"`
+	Attendees      []string `json:"attendees"`
+	ResponseStatus string   `json:"responseStatus"`
+	Status         string   `json:"status"`
+	// JoinURL is the link to the video conference of the event, if any
+	JoinURL string `json:"joinUrl,omitempty"`
+	// Calendar is the email of the connected account the event was read from, the first one for a meeting seen
+	// through several accounts
+	Calendar string `json:"calendar,omitempty"`
+}
+
+// StartTime parses Start, which is RFC3339 or a plain date for all-day events.
+func (e Event) StartTime() (time.Time, error) {
+	return parseEventTime(e.Start)
+}
+
+// EndTime parses End, which is RFC3339 or a plain date for all-day events.
+func (e Event) EndTime() (time.Time, error) {
+	return parseEventTime(e.End)
+}
+
+// AllDay tells whether the event spans whole days rather than a time range.
+func (e Event) AllDay() bool {
+	return len(e.Start) == len(dateLayout)
+}
+
+// StartIn is the start of the event in the location. All-day events start at midnight there, so a day is 23 or 25
+// hours long across a DST change.
+func (e Event) StartIn(loc *time.Location) (time.Time, error) {
+	return parseEventTimeIn(e.Start, loc)
+}
+
+// EndIn is the end of the event in the location, see StartIn.
+func (e Event) EndIn(loc *time.Location) (time.Time, error) {
+	return parseEventTimeIn(e.End, loc)
+}
+
+const dateLayout = "2006-01-02"
+
+func parseEventTimeIn(s string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t.In(loc), nil
+	}
+	return time.ParseInLocation(dateLayout, s, loc)
+}
+
+func parseEventTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, s)
 }
 
 type Events []Event
@@ -14,3 +86,16 @@ type EventsResponse struct {
 	Items         Events `json:"items"`
 	NextPageToken string `json:"nextPageToken"`
 }
+
+// UserEvent is an event in a stream merging the calendars of several users.
+type UserEvent struct {
+	UserId string `json:"userId"`
+	Event
+}
+
+type UserEvents []UserEvent
+
+type UserEventsResponse struct {
+	Items         UserEvents `json:"items"`
+	NextPageToken string     `json:"nextPageToken"`
+}
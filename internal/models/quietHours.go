@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Policies for the reminders due during the quiet hours of a user.
+const (
+	// QuietDrop never sends them
+	QuietDrop = "drop"
+	// QuietDefer sends them once the quiet hours are over, unless the event started by then
+	QuietDefer = "defer"
+	// QuietDeliver sends the reminders of events starting within quiet hours anyway, the user is evidently up for
+	// them, and defers the others
+	QuietDeliver = "deliver"
+)
+
+// QuietHours are the times a user doesn't want reminders, in their time zone: every night from Start to End, like
+// 22:00 to 07:00, and all day outside their working days. Without Start and End only days off are quiet, without
+// working days every day is one.
+type QuietHours struct {
+	Start       string         `json:"start"`
+	End         string         `json:"end"`
+	WorkingDays []time.Weekday `json:"workingDays"`
+	Policy      string         `json:"policy"`
+}
+
+// WorkingHours are the hours a calendar says its owner works, in their time zone.
+type WorkingHours struct {
+	Start string
+	End   string
+	Days  []time.Weekday
+}
+
+// maxQuietSteps bounds the walk to the end of a quiet period, a week of nights and days off takes fewer.
+const maxQuietSteps = 16
+
+// Validate tells what's wrong with the quiet hours, nil when they're fine.
+func (q QuietHours) Validate() error {
+	if (q.Start == "") != (q.End == "") {
+		return errors.New("start and end go together")
+	}
+	for _, s := range []string{q.Start, q.End} {
+		if _, ok := clock(s); s != "" && !ok {
+			return fmt.Errorf("%q isn't a time of day like 22:00", s)
+		}
+	}
+	if q.WorkingDays != nil && len(q.WorkingDays) == 0 {
+		return errors.New("at least one working day is needed")
+	}
+	for _, day := range q.WorkingDays {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("%d isn't a day of the week, 0 is Sunday", day)
+		}
+	}
+	switch q.Policy {
+	case QuietDrop, QuietDefer, QuietDeliver:
+		return nil
+	default:
+		return errors.New("the policy must be drop, defer or deliver")
+	}
+}
+
+// Quiet tells whether t falls within the quiet hours, t being in the time zone of the user.
+func (q QuietHours) Quiet(t time.Time) bool {
+	if !q.workingDay(t.Weekday()) {
+		return true
+	}
+	start, end, ok := q.window()
+	if !ok {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	if start < end {
+		return m >= start && m < end
+	}
+	// the quiet hours span midnight
+	return m >= start || m < end
+}
+
+// Until returns when the quiet period t falls in is over, t itself when it isn't quiet. The result is still quiet
+// when there is no end in sight.
+func (q QuietHours) Until(t time.Time) time.Time {
+	for i := 0; i < maxQuietSteps && q.Quiet(t); i++ {
+		y, m, d := t.Date()
+		if !q.workingDay(t.Weekday()) {
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		_, end, _ := q.window()
+		next := time.Date(y, m, d, end/60, end%60, 0, 0, t.Location())
+		if !next.After(t) {
+			next = time.Date(y, m, d+1, end/60, end%60, 0, 0, t.Location())
+		}
+		t = next
+	}
+	return t
+}
+
+// QuietHoursOf makes the hours outside the working hours quiet.
+func QuietHoursOf(w WorkingHours, policy string) QuietHours {
+	return QuietHours{Start: w.End, End: w.Start, WorkingDays: w.Days, Policy: policy}
+}
+
+func (q QuietHours) workingDay(day time.Weekday) bool {
+	if q.WorkingDays == nil {
+		return true
+	}
+	for _, d := range q.WorkingDays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// window returns the nightly quiet hours in minutes of the day, false without any.
+func (q QuietHours) window() (int, int, bool) {
+	start, ok := clock(q.Start)
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok := clock(q.End)
+	if !ok || start == end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// clock parses a time of day like 07:30 into minutes.
+func clock(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
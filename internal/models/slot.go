@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Slot is a span of time, like a free window in calendars.
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type Slots []Slot
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// PhoneVerification is a one-time code sent to the phone number a user is verifying.
+type PhoneVerification struct {
+	Phone     string    `json:"phone"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// maxOffsetMinutes is a week, reminders are scheduled within a shorter horizon anyway.
+const maxOffsetMinutes = 7 * 24 * 60
+
+// ReminderRules are when and where a user is reminded of their events: at each offset before the start, in
+// minutes, on each channel. A nil field is inherited, members from their team and teams from the configured
+// defaults, an empty one turns the reminders off.
+type ReminderRules struct {
+	OffsetMinutes []int    `json:"offsetMinutes"`
+	Channels      []string `json:"channels"`
+}
+
+// Validate tells what's wrong with the rules, nil when they're fine.
+func (r ReminderRules) Validate() error {
+	for _, offset := range r.OffsetMinutes {
+		if offset < 0 || offset > maxOffsetMinutes {
+			return fmt.Errorf("offsets must be between 0 and %d minutes", maxOffsetMinutes)
+		}
+	}
+	for _, channel := range r.Channels {
+		if channel == "" {
+			return errors.New("channels can't be empty")
+		}
+	}
+	return nil
+}
+
+// Or returns the rules with their unset fields taken from parent.
+func (r ReminderRules) Or(parent ReminderRules) ReminderRules {
+	if r.OffsetMinutes == nil {
+		r.OffsetMinutes = parent.OffsetMinutes
+	}
+	if r.Channels == nil {
+		r.Channels = parent.Channels
+	}
+	return r
+}
+
+// Offsets are the offsets of the rules as durations.
+func (r ReminderRules) Offsets() []time.Duration {
+	var offsets []time.Duration
+	for _, offset := range r.OffsetMinutes {
+		offsets = append(offsets, time.Duration(offset)*time.Minute)
+	}
+	return offsets
+}
+
+// RulesOf makes rules of the configured offsets and channels.
+func RulesOf(offsets []time.Duration, channels []string) ReminderRules {
+	rules := ReminderRules{OffsetMinutes: []int{}, Channels: channels}
+	for _, offset := range offsets {
+		rules.OffsetMinutes = append(rules.OffsetMinutes, int(offset/time.Minute))
+	}
+	return rules
+}
+
+// Team is a group of users, such as a whole team onboarded at once, whose members share its reminder rules.
+type Team struct {
+	Id    *uuid.UUID    `json:"id"`
+	Name  string        `json:"name"`
+	Rules ReminderRules `json:"rules"`
+}
+
+type Teams []Team
+
+// TeamEvent is an event of the merged calendar of a team, with the members it is on the calendar of. A meeting
+// several members attend shows once.
+type TeamEvent struct {
+	UserIds []string `json:"userIds"`
+	Event
+}
+
+type TeamEvents []TeamEvent
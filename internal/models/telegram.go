@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// TelegramLink is a one-time code linking the Telegram chat which sends it to the bot to a user.
+type TelegramLink struct {
+	Code      string    `json:"code"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Kinds of notification: a reminder of an upcoming event, or a change to an event the user had reminders for.
+const (
+	KindReminder  = "reminder"
+	KindMoved     = "moved"
+	KindCancelled = "cancelled"
+)
+
+// Formats of a message template, plain text or HTML.
+const (
+	FormatText = "text"
+	FormatHTML = "html"
+)
+
+// MessageTemplate is a Go template of the message of a kind of notification, for a channel and a locale.
+type MessageTemplate struct {
+	Channel   string    `json:"channel"`
+	Locale    string    `json:"locale"`
+	Kind      string    `json:"kind"`
+	Format    string    `json:"format"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type MessageTemplates []MessageTemplate
@@ -1,11 +1,77 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
+const (
+	ProviderGoogle    = "google"
+	ProviderMicrosoft = "microsoft"
+)
+
+// User is a person, their calendars are reached through the accounts they connected. Phone is the verified phone
+// number of the user, in E.164 format. TimeZone is an IANA time zone, like Europe/Paris, and Locale a language tag,
+// like fr-CA. Rules override those of their team, if any.
 type User struct {
-	Id    *uuid.UUID `json:"id"`
-	Email *string    `json:"email"`
-	Token *string    `json:"-"`
+	Id         *uuid.UUID        `json:"id"`
+	Email      *string           `json:"email"`
+	Phone      *string           `json:"phone"`
+	TimeZone   *string           `json:"timeZone"`
+	Locale     *string           `json:"locale"`
+	QuietHours *QuietHours       `json:"quietHours"`
+	Team       *Team             `json:"team"`
+	Rules      ReminderRules     `json:"rules"`
+	Accounts   ConnectedAccounts `json:"accounts"`
+}
+
+// Location is the time zone of the user, UTC when unset or unknown.
+func (u *User) Location() *time.Location {
+	if u == nil || u.TimeZone == nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(*u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ReminderRules are the rules of the user, inheriting from their team then from the defaults.
+func (u *User) ReminderRules(defaults ReminderRules) ReminderRules {
+	if u == nil {
+		return defaults
+	}
+	rules := u.Rules
+	if u.Team != nil {
+		rules = rules.Or(u.Team.Rules)
+	}
+	return rules.Or(defaults)
+}
+
+// Language is the locale of the user, empty when unset.
+func (u *User) Language() string {
+	if u == nil || u.Locale == nil {
+		return ""
+	}
+	return *u.Locale
+}
+
+// UserPreferences are the settings a user chooses. An empty time zone follows the setting of their calendar.
+type UserPreferences struct {
+	TimeZone string `json:"timeZone"`
+	Locale   string `json:"locale"`
 }
 
 type Users []User
+
+// ConnectedAccount is a calendar account of a provider linked to a user.
+type ConnectedAccount struct {
+	Id       *uuid.UUID `json:"id"`
+	UserId   *uuid.UUID `json:"userId"`
+	Provider string     `json:"provider"`
+	Email    *string    `json:"email"`
+	Token    *string    `json:"-"`
+}
+
+type ConnectedAccounts []ConnectedAccount
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PushSubscription is a browser subscribed to push messages, as given by PushSubscription.toJSON() in the browser.
+type PushSubscription struct {
+	Id        *uuid.UUID `json:"id"`
+	UserId    *uuid.UUID `json:"userId"`
+	Endpoint  string     `json:"endpoint"`
+	Keys      PushKeys   `json:"keys"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// PushKeys are the base64url encoded P-256 public key of the browser and its authentication secret.
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
@@ -0,0 +1,187 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ChannelEmail = "email"
+
+var errNoEmail = errors.New("email: the user has no email")
+
+// EmailNotifier mails notifications to the email of the user over SMTP, as plain text along with the HTML rendered
+// from the templates when there is one. The action links let the user snooze the reminder or mark it as done.
+type EmailNotifier struct {
+	l       *zap.Logger
+	c       config.EmailConfig
+	timeout time.Duration
+}
+
+func NewEmailNotifier(l *zap.Logger, c config.EmailConfig) *EmailNotifier {
+	return &EmailNotifier{l: l, c: c, timeout: 30 * time.Second}
+}
+
+func (n *EmailNotifier) Channel() string {
+	return ChannelEmail
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.User.Email == nil {
+		return errNoEmail
+	}
+	from, err := mail.ParseAddress(n.c.From)
+	if err != nil {
+		return fmt.Errorf("email: invalid sender: %w", err)
+	}
+	msg, err := n.message(*notification.User.Email, notification)
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, from.Address, *notification.User.Email, msg)
+}
+
+// send hands the message over to the SMTP server, upgrading to TLS when the server offers it. It is smtp.SendMail
+// bound to the context.
+func (n *EmailNotifier) send(ctx context.Context, from string, to string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(n.c.Host, strconv.Itoa(n.c.Port)))
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, n.c.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: n.c.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if n.c.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", n.c.Username, n.c.Password, n.c.Host))
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds a multipart/alternative message with the text and, when rendered, the HTML of the notification.
+func (n *EmailNotifier) message(to string, notification Notification) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	parts := [][2]string{{"text/plain; charset=utf-8", emailText(notification)}}
+	if notification.HTML != "" {
+		parts = append(parts, [2]string{"text/html; charset=utf-8", notification.HTML})
+	}
+	for _, part := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part[0]},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		_, err = qw.Write([]byte(part[1]))
+		if err != nil {
+			return nil, err
+		}
+		err = qw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	for _, header := range [][2]string{
+		{"From", n.c.From},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", emailSubject(notification))},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	} {
+		msg.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func emailSubject(n Notification) string {
+	switch n.Kind {
+	case KindMoved:
+		return "Moved: " + n.Event.Title
+	case KindCancelled:
+		return "Cancelled: " + n.Event.Title
+	default:
+		return "Reminder: " + n.Event.Title
+	}
+}
+
+// emailText is the text of the notification followed by the join link and the action links.
+func emailText(n Notification) string {
+	text := n.Text()
+	if n.Event.JoinURL != "" && n.Kind != KindCancelled {
+		text += "\n\nJoin: " + n.Event.JoinURL
+	}
+	var links []string
+	for _, action := range n.Actions {
+		if action.URL != "" {
+			links = append(links, action.Label+": "+action.URL)
+		}
+	}
+	if len(links) > 0 {
+		text += "\n\n" + strings.Join(links, "\n")
+	}
+	return text
+}
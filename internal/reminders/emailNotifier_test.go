@@ -0,0 +1,130 @@
+package reminders
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"manny-reminder/internal/config"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts a single message without TLS nor auth, keeping its envelope and data.
+type fakeSMTP struct {
+	listener net.Listener
+	from     string
+	to       string
+	data     chan string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	f := &fakeSMTP{listener: listener, data: make(chan string, 1)}
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTP) serve() {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			f.from = strings.Trim(strings.TrimPrefix(command, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			f.to = strings.Trim(strings.TrimPrefix(command, "RCPT TO:"), "<>")
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			f.data <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Unknown command")
+		}
+	}
+}
+
+func TestEmailNotifier_Notify(t *testing.T) {
+	f := newFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	n := NewEmailNotifier(zap.NewNop(), config.EmailConfig{Host: host, Port: p, From: "Manny <manny@example.com>"})
+	event := generateEvent("e1", time.Date(2022, 6, 1, 13, 10, 0, 0, time.UTC))
+	event.Title = "Board meeting é"
+	notification := Notification{
+		Kind: KindReminder, User: generateUser(), Event: event, HTML: "<p>Board meeting</p>",
+		Actions: []Action{{Name: ActionDone, Label: "Done", URL: "https://reminders.example.com/reminders/actions/t"}},
+	}
+
+	err := n.Notify(context.Background(), notification)
+
+	assert.Nil(t, err)
+	msg, err := mail.ReadMessage(strings.NewReader(<-f.data))
+	assert.Nil(t, err)
+	assert.Equal(t, "manny@example.com", f.from)
+	assert.Equal(t, "user@example.com", f.to)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Reminder: Board meeting é", subject)
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := ioutil.ReadAll(part)
+		bodies = append(bodies, string(b))
+	}
+	assert.Equal(t, []string{
+		"\"Board meeting é\" starts at 1:10PM\r\n\r\nDone: https://reminders.example.com/reminders/actions/t",
+		"<p>Board meeting</p>",
+	}, bodies)
+}
+
+func TestEmailNotifier_NoEmail(t *testing.T) {
+	n := NewEmailNotifier(zap.NewNop(), config.EmailConfig{Host: "localhost", Port: 25, From: "manny@example.com"})
+	user := generateUser()
+	user.Email = nil
+
+	err := n.Notify(context.Background(), Notification{Kind: KindReminder, User: user})
+
+	assert.Equal(t, errNoEmail, err)
+}
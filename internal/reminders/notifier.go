@@ -0,0 +1,131 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"manny-reminder/internal/logging"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/templates"
+)
+
+const ChannelLog = "log"
+
+// Kinds of notification, see models.KindReminder.
+const (
+	KindReminder  = models.KindReminder
+	KindMoved     = models.KindMoved
+	KindCancelled = models.KindCancelled
+)
+
+// Notifier sends notifications over a channel, such as chat or SMS.
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Notifiers maps a channel to its notifier.
+type Notifiers map[string]Notifier
+
+// Notification is what a notifier sends. Previous is the former state of a moved event, Delivery and the actions
+// the user can take on it are set for reminders only. Message is rendered from a template set up for the channel,
+// notifiers keep their own layout without it. HTML is the message for channels showing HTML.
+type Notification struct {
+	Kind     string
+	User     *models.User
+	Event    models.Event
+	Previous *models.Event
+	Delivery *models.Delivery
+	Actions  []Action
+	Message  string
+	HTML     string
+}
+
+// Text is the plain text of the notification, e.g. `Your 3:00PM meeting "Standup" moved to 4:00PM`, with the times
+// in the time zone of the user.
+func (n Notification) Text() string {
+	if n.Message != "" {
+		return n.Message
+	}
+	start, _ := n.Event.StartIn(n.User.Location())
+	switch n.Kind {
+	case KindMoved:
+		previous := start
+		if n.Previous != nil {
+			previous, _ = n.Previous.StartIn(n.User.Location())
+		}
+		return fmt.Sprintf("Your %s meeting %q moved to %s", templates.FormatTime(previous, previous), n.Event.Title,
+			templates.FormatTime(start, previous))
+	case KindCancelled:
+		return fmt.Sprintf("Your %s meeting %q was cancelled", templates.FormatTime(start, start), n.Event.Title)
+	default:
+		return fmt.Sprintf("%q starts at %s", n.Event.Title, templates.FormatTime(start, start))
+	}
+}
+
+// Renderer renders the messages of notifications from templates, see templates.Engine.
+type Renderer interface {
+	Render(ctx context.Context, key templates.Key, data templates.Data) (string, string, error)
+}
+
+// SetRenderer renders the messages of the notifications. Without it notifiers word them on their own.
+func (s *ServiceImpl) SetRenderer(r Renderer) {
+	s.renderer = r
+}
+
+// render sets the message of the notification for the channel. The built-in text is left to the notifiers, which
+// lay it out their own way, and a failing template leaves it unset.
+func (s *ServiceImpl) render(ctx context.Context, channel string, n Notification) Notification {
+	if s.renderer == nil {
+		return n
+	}
+	data := templates.NewData(n.Kind, n.User, n.Event, n.Previous, n.User.Location())
+	for _, a := range n.Actions {
+		// without a public URL actions are buttons, not links
+		if a.URL == "" {
+			continue
+		}
+		data.Actions = append(data.Actions, templates.Action{Name: a.Name, Label: a.Label, URL: a.URL})
+	}
+
+	for format, message := range map[string]*string{models.FormatText: &n.Message, models.FormatHTML: &n.HTML} {
+		rendered, source, err := s.renderer.Render(ctx, templates.Key{Channel: channel, Locale: n.User.Language(), Kind: n.Kind, Format: format}, data)
+		if err != nil {
+			s.l.Error("Unable to render the notification", zap.String("channel", channel), zap.String("kind", n.Kind),
+				zap.String("format", format), zap.Error(err))
+			continue
+		}
+		if format == models.FormatText && source == templates.SourceBuiltin {
+			continue
+		}
+		*message = rendered
+	}
+	return n
+}
+
+// LogNotifier writes notifications to the log, it is the default channel and handy to try the scheduler out.
+type LogNotifier struct {
+	l *zap.Logger
+}
+
+func NewLogNotifier(l *zap.Logger) *LogNotifier {
+	return &LogNotifier{l}
+}
+
+func (n LogNotifier) Channel() string {
+	return ChannelLog
+}
+
+func (n LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	var links []string
+	for _, action := range notification.Actions {
+		links = append(links, action.URL)
+	}
+	logging.FromContext(ctx, n.l).Info("Notification",
+		zap.String("kind", notification.Kind),
+		zap.Stringer("userId", notification.User.Id),
+		zap.String("eventId", notification.Event.Id),
+		zap.String("text", notification.Text()),
+		zap.Strings("actions", links))
+	return nil
+}
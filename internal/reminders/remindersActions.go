@@ -0,0 +1,264 @@
+package reminders
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"manny-reminder/internal/models"
+	"math"
+	"strings"
+	"time"
+)
+
+// Actions a user can take on a reminder they received.
+const (
+	ActionSnooze = "snooze"
+	ActionDone   = "done"
+)
+
+const actionTokenVersion = 1
+
+// A token packs the version, delivery id, action, minutes, snoozes and expiry, then the truncated signature. Encoded
+// it fits the 64 bytes Telegram allows in the data of a button.
+const (
+	actionPayloadSize   = 1 + 16 + 1 + 1 + 2 + 4
+	actionSignatureSize = 16
+)
+
+var (
+	ErrInvalidAction = errors.New("invalid action link")
+	ErrActionExpired = errors.New("action link expired")
+	ErrActionUsed    = errors.New("action link already used")
+)
+
+// Action is offered with a reminder, as a link to follow or a button whose value is the token.
+type Action struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Token string `json:"token"`
+	URL   string `json:"url,omitempty"`
+}
+
+// ActionResult tells what an action link does, or did once followed.
+type ActionResult struct {
+	Action     string     `json:"action"`
+	DeliveryId string     `json:"deliveryId"`
+	EventTitle string     `json:"eventTitle"`
+	Message    string     `json:"message"`
+	SendAt     *time.Time `json:"sendAt,omitempty"`
+}
+
+// actionToken names the delivery, the action and the number of snoozes the delivery had when the link was sent,
+// which the repository checks so the link works once. It expires when the event ends.
+type actionToken struct {
+	DeliveryId string
+	Action     string
+	Minutes    int
+	Snoozes    int
+	Expires    int64
+}
+
+// actionCodec signs action tokens so recipients can't act on other deliveries, the payload is not secret.
+type actionCodec struct {
+	key []byte
+}
+
+func newActionCodec(key []byte) actionCodec {
+	return actionCodec{key: key}
+}
+
+// randomActionKey is used when no key is configured, links sent before a restart then stop working.
+func randomActionKey() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}
+
+func (c actionCodec) encode(t actionToken) (string, error) {
+	id, err := uuid.Parse(t.DeliveryId)
+	if err != nil {
+		return "", err
+	}
+	if t.Minutes < 0 || t.Minutes > math.MaxUint8 || t.Snoozes < 0 || t.Snoozes > math.MaxUint16 ||
+		t.Expires < 0 || t.Expires > math.MaxUint32 {
+		return "", fmt.Errorf("action token out of range: %+v", t)
+	}
+	payload := make([]byte, actionPayloadSize, actionPayloadSize+actionSignatureSize)
+	payload[0] = actionTokenVersion
+	copy(payload[1:17], id[:])
+	payload[17], payload[18] = t.Action[0], byte(t.Minutes)
+	binary.BigEndian.PutUint16(payload[19:21], uint16(t.Snoozes))
+	binary.BigEndian.PutUint32(payload[21:25], uint32(t.Expires))
+	return base64.RawURLEncoding.EncodeToString(append(payload, c.sign(payload)...)), nil
+}
+
+func (c actionCodec) decode(s string) (*actionToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) != actionPayloadSize+actionSignatureSize {
+		return nil, ErrInvalidAction
+	}
+	payload, signature := b[:actionPayloadSize], b[actionPayloadSize:]
+	if !hmac.Equal(signature, c.sign(payload)) || payload[0] != actionTokenVersion {
+		return nil, ErrInvalidAction
+	}
+
+	id, _ := uuid.FromBytes(payload[1:17])
+	t := actionToken{
+		DeliveryId: id.String(),
+		Minutes:    int(payload[18]),
+		Snoozes:    int(binary.BigEndian.Uint16(payload[19:21])),
+		Expires:    int64(binary.BigEndian.Uint32(payload[21:25])),
+	}
+	switch payload[17] {
+	case ActionDone[0]:
+		t.Action = ActionDone
+	case ActionSnooze[0]:
+		t.Action = ActionSnooze
+		if t.Minutes == 0 {
+			return nil, ErrInvalidAction
+		}
+	default:
+		return nil, ErrInvalidAction
+	}
+	return &t, nil
+}
+
+func (c actionCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:actionSignatureSize]
+}
+
+// SetActionKey sets the key signing the action links, so they survive restarts and work across replicas.
+func (s *ServiceImpl) SetActionKey(key []byte) {
+	s.actionCodec = newActionCodec(key)
+}
+
+// SetPublicURL sets where users reach the server. Without it reminders carry action tokens for buttons but no links.
+func (s *ServiceImpl) SetPublicURL(publicURL string) {
+	s.publicURL = strings.TrimSuffix(publicURL, "/")
+}
+
+// actions returns the snooze options and done action of a delivery, valid until the event ends.
+func (s *ServiceImpl) actions(d models.Delivery, event models.Event) ([]Action, error) {
+	end, err := event.EndTime()
+	if err != nil {
+		end = d.EventStart
+	}
+	t := actionToken{DeliveryId: d.Id.String(), Snoozes: d.Snoozes, Expires: end.Unix()}
+
+	var actions []Action
+	for _, option := range s.c.SnoozeOptions {
+		t.Action, t.Minutes = ActionSnooze, int(option/time.Minute)
+		action, err := s.action(t, fmt.Sprintf("Snooze %d min", t.Minutes))
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	t.Action, t.Minutes = ActionDone, 0
+	action, err := s.action(t, "Done")
+	if err != nil {
+		return nil, err
+	}
+	return append(actions, action), nil
+}
+
+func (s *ServiceImpl) action(t actionToken, label string) (Action, error) {
+	token, err := s.actionCodec.encode(t)
+	if err != nil {
+		return Action{}, err
+	}
+	action := Action{Name: t.Action, Label: label, Token: token}
+	if s.publicURL != "" {
+		action.URL = s.publicURL + "/reminders/actions/" + token
+	}
+	return action, nil
+}
+
+// DescribeAction checks an action token without using it, to confirm with the user before acting. Links are
+// fetched by mail scanners and chat previews, which mustn't snooze anything.
+func (s *ServiceImpl) DescribeAction(ctx context.Context, token string) (ActionResult, error) {
+	t, d, event, err := s.resolveAction(ctx, token)
+	if err != nil {
+		return ActionResult{}, err
+	}
+	if d.Status != models.DeliverySent || d.Snoozes != t.Snoozes {
+		return ActionResult{}, ErrActionUsed
+	}
+	result := ActionResult{Action: t.Action, DeliveryId: t.DeliveryId, EventTitle: event.Title, Message: "Mark as done"}
+	if t.Action == ActionSnooze {
+		result.Message = fmt.Sprintf("Snooze for %d minutes", t.Minutes)
+	}
+	return result, nil
+}
+
+// PerformAction snoozes or closes the delivery of the token. Marking a reminder as done also cancels the reminders
+// of the event still to come, the user is on it.
+func (s *ServiceImpl) PerformAction(ctx context.Context, token string) (ActionResult, error) {
+	t, d, event, err := s.resolveAction(ctx, token)
+	if err != nil {
+		return ActionResult{}, err
+	}
+	result := ActionResult{Action: t.Action, DeliveryId: t.DeliveryId, EventTitle: event.Title}
+
+	if t.Action == ActionSnooze {
+		sendAt := s.now().Add(time.Duration(t.Minutes) * time.Minute)
+		ok, err := s.r.SnoozeDelivery(ctx, d.Id, t.Snoozes, sendAt)
+		if err != nil {
+			return ActionResult{}, err
+		}
+		if !ok {
+			return ActionResult{}, ErrActionUsed
+		}
+		result.SendAt = &sendAt
+		result.Message = fmt.Sprintf("Snoozed for %d minutes", t.Minutes)
+		return result, nil
+	}
+
+	ok, err := s.r.AcknowledgeDelivery(ctx, d.Id, t.Snoozes)
+	if err != nil {
+		return ActionResult{}, err
+	}
+	if !ok {
+		return ActionResult{}, ErrActionUsed
+	}
+	err = s.r.CancelDeliveries(ctx, d.UserId.String(), d.EventId, nil, models.CancelAcknowledged)
+	if err != nil {
+		return ActionResult{}, err
+	}
+	result.Message = "Marked as done"
+	return result, nil
+}
+
+func (s *ServiceImpl) resolveAction(ctx context.Context, token string) (*actionToken, *models.Delivery, models.Event, error) {
+	t, err := s.actionCodec.decode(token)
+	if err != nil {
+		return nil, nil, models.Event{}, err
+	}
+	if !s.now().Before(time.Unix(t.Expires, 0)) {
+		return nil, nil, models.Event{}, ErrActionExpired
+	}
+	d, err := s.r.GetDelivery(ctx, t.DeliveryId)
+	if err != nil {
+		return nil, nil, models.Event{}, err
+	}
+	if d == nil {
+		return nil, nil, models.Event{}, ErrInvalidAction
+	}
+	event, err := s.r.GetEventSnapshot(ctx, d.UserId.String(), d.EventId)
+	if err != nil {
+		return nil, nil, models.Event{}, err
+	}
+	if event == nil {
+		// the event is gone from the calendar, its reminders were cancelled
+		return nil, nil, models.Event{}, ErrActionExpired
+	}
+	return t, d, *event, nil
+}
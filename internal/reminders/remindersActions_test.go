@@ -0,0 +1,200 @@
+package reminders
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_Actions_LinksEveryOption(t *testing.T) {
+	_, _, _, _, s := initService(t)
+	s.c.SnoozeOptions = []time.Duration{5 * time.Minute, 30 * time.Minute}
+	s.SetPublicURL("https://reminders.example.com/")
+	d := generateDelivery(generateUser(), generateEvent("e1", now.Add(10*time.Minute)), 1)
+
+	actions, err := s.actions(d, generateEvent("e1", now.Add(10*time.Minute)))
+
+	assert.Nil(t, err)
+	assert.Len(t, actions, 3)
+	assert.Equal(t, "Snooze 5 min", actions[0].Label)
+	assert.Equal(t, "Snooze 30 min", actions[1].Label)
+	assert.Equal(t, ActionDone, actions[2].Name)
+	assert.True(t, strings.HasPrefix(actions[2].URL, "https://reminders.example.com/reminders/actions/"))
+	for _, action := range actions {
+		assert.LessOrEqual(t, len(action.Token), 64)
+	}
+}
+
+func TestService_PerformAction_SnoozeWorksOnce(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(user, event)
+	token := actionTokenFor(t, s, d, event, 0)
+	mockResolveAction(er, d, &event)
+	sendAt := now.Add(5 * time.Minute)
+	er.On("SnoozeDelivery", mock.Anything, d.Id, 0, sendAt).Return(true, nil).Once()
+	er.On("SnoozeDelivery", mock.Anything, d.Id, 0, sendAt).Return(false, nil).Once()
+
+	result, err := s.PerformAction(context.Background(), token)
+	assert.Nil(t, err)
+	assert.Equal(t, &sendAt, result.SendAt)
+	assert.Equal(t, "Snoozed for 5 minutes", result.Message)
+
+	_, err = s.PerformAction(context.Background(), token)
+	assert.ErrorIs(t, err, ErrActionUsed)
+}
+
+func TestService_PerformAction_DoneCancelsRemainingReminders(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(user, event)
+	token := actionTokenFor(t, s, d, event, 2)
+	mockResolveAction(er, d, &event)
+	er.On("AcknowledgeDelivery", mock.Anything, d.Id, 0).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", (*time.Time)(nil), models.CancelAcknowledged).Return(nil)
+
+	result, err := s.PerformAction(context.Background(), token)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Marked as done", result.Message)
+}
+
+func TestService_PerformAction_DoneStaysDoneAfterSync(t *testing.T) {
+	er, es, as, n, s := initService(t)
+	s.c.Offsets = []time.Duration{30 * time.Minute, 10 * time.Minute}
+	user := generateUser()
+	event := generateEvent("e1", now.Add(40*time.Minute))
+	start, _ := event.StartTime()
+	first := sentDelivery(user, event)
+	first.OffsetMinutes = 30
+	token := actionTokenFor(t, s, first, event, 2)
+	mockResolveAction(er, first, &event)
+	acknowledged := false
+	er.On("AcknowledgeDelivery", mock.Anything, first.Id, 0).Run(func(mock.Arguments) { acknowledged = true }).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", (*time.Time)(nil), models.CancelAcknowledged).Return(nil)
+	// the next sync finds the event unchanged, its reminders still ahead are scheduled whatever the store makes of it
+	es.On("GetEventsToSync", mock.Anything, user, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, user.Id.String(), mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", &start, models.CancelEventChanged).Return(nil)
+	er.On("AddDelivery", mock.Anything, mock.Anything).Return(true, nil).Twice()
+	second := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{second}, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", start).Return(func(context.Context, string, string, time.Time) bool {
+		return acknowledged
+	}, nil)
+	er.On("CancelDelivery", mock.Anything, second.Id, second.ClaimedAt, models.CancelAcknowledged).Return(true, nil)
+
+	_, err := s.PerformAction(context.Background(), token)
+	assert.Nil(t, err)
+	assert.Nil(t, s.SyncUser(context.Background(), user))
+	assert.Nil(t, s.Dispatch(context.Background()))
+
+	assert.Empty(t, n.sent)
+}
+
+func TestService_PerformAction_ExpiresWhenEventEnds(t *testing.T) {
+	_, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(-time.Hour))
+	d := sentDelivery(generateUser(), event)
+	token := actionTokenFor(t, s, d, event, 0)
+
+	_, err := s.PerformAction(context.Background(), token)
+
+	assert.ErrorIs(t, err, ErrActionExpired)
+}
+
+func TestService_PerformAction_TamperedToken(t *testing.T) {
+	_, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(generateUser(), event)
+	forged, err := newActionCodec([]byte("another key")).encode(actionToken{DeliveryId: d.Id.String(), Action: ActionDone})
+	assert.Nil(t, err)
+
+	_, err = s.PerformAction(context.Background(), forged)
+
+	assert.ErrorIs(t, err, ErrInvalidAction)
+}
+
+func TestService_DescribeAction_UsedLink(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(generateUser(), event)
+	token := actionTokenFor(t, s, d, event, 0)
+	d.Snoozes = 1
+	d.Status = models.DeliveryPending
+	mockResolveAction(er, d, &event)
+
+	_, err := s.DescribeAction(context.Background(), token)
+
+	assert.ErrorIs(t, err, ErrActionUsed)
+}
+
+func TestHandler_OpeningLinkDoesNotAct(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(generateUser(), event)
+	token := actionTokenFor(t, s, d, event, 0)
+	mockResolveAction(er, d, &event)
+	req := httptest.NewRequest(http.MethodGet, "/reminders/actions/"+token, nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+
+	actionsRouter(s).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<form method="post"><button type="submit">Snooze for 5 minutes</button></form>`)
+	er.AssertNotCalled(t, "SnoozeDelivery", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandler_PerformAction_UsedLinkConflicts(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(generateUser(), event)
+	token := actionTokenFor(t, s, d, event, 2)
+	mockResolveAction(er, d, &event)
+	er.On("AcknowledgeDelivery", mock.Anything, d.Id, 0).Return(false, nil)
+	rec := httptest.NewRecorder()
+
+	actionsRouter(s).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/reminders/actions/"+token, nil))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "action link already used")
+}
+
+func sentDelivery(user *models.User, event models.Event) models.Delivery {
+	d := generateDelivery(user, event, 1)
+	d.Status = models.DeliverySent
+	return d
+}
+
+// actionTokenFor returns the token of the i-th action offered with the delivery: snooze 5 or 10 minutes, or done.
+func actionTokenFor(t *testing.T, s *ServiceImpl, d models.Delivery, event models.Event, i int) string {
+	s.c.SnoozeOptions = []time.Duration{5 * time.Minute, 10 * time.Minute}
+	actions, err := s.actions(d, event)
+	assert.Nil(t, err)
+	return actions[i].Token
+}
+
+func actionsRouter(s *ServiceImpl) *mux.Router {
+	h := NewHandler(s)
+	router := mux.NewRouter()
+	router.HandleFunc("/reminders/actions/{token}", h.DescribeAction).Methods(http.MethodGet)
+	router.HandleFunc("/reminders/actions/{token}", h.PerformAction).Methods(http.MethodPost)
+	return router
+}
+
+func mockResolveAction(er *mocks.EventsRepository, d models.Delivery, event *models.Event) {
+	er.On("GetDelivery", mock.Anything, d.Id.String()).Return(&d, nil)
+	er.On("GetEventSnapshot", mock.Anything, d.UserId.String(), d.EventId).Return(event, nil)
+}
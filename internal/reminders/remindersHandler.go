@@ -0,0 +1,100 @@
+package reminders
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"html/template"
+	"manny-reminder/internal/utils"
+	"net/http"
+	"strings"
+)
+
+// actionPage is shown when an action link is opened in a browser, the action only happens once the form is posted.
+var actionPage = template.Must(template.New("action").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>{{.Title}}</title></head>
+<body>
+<p>{{.EventTitle}}</p>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post"><button type="submit">{{.Confirm}}</button></form>{{end}}
+</body>
+</html>
+`))
+
+type actionView struct {
+	Title      string
+	EventTitle string
+	Message    string
+	Confirm    string
+}
+
+type HandlerImpl struct {
+	rs RemindersService
+}
+
+func NewHandler(rs RemindersService) *HandlerImpl {
+	return &HandlerImpl{rs: rs}
+}
+
+// DescribeAction shows what an action link does with a button to confirm it, fetching the link changes nothing.
+func (h HandlerImpl) DescribeAction(w http.ResponseWriter, r *http.Request) {
+	result, err := h.rs.DescribeAction(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if !wantsHtml(r) {
+		utils.SendJson(w, result)
+		return
+	}
+	sendActionPage(w, http.StatusOK, actionView{Title: result.Message, EventTitle: result.EventTitle, Confirm: result.Message})
+}
+
+// PerformAction snoozes or closes the reminder of an action link.
+func (h HandlerImpl) PerformAction(w http.ResponseWriter, r *http.Request) {
+	result, err := h.rs.PerformAction(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if !wantsHtml(r) {
+		utils.SendJson(w, result)
+		return
+	}
+	sendActionPage(w, http.StatusOK, actionView{Title: result.Message, EventTitle: result.EventTitle, Message: result.Message})
+}
+
+func (h HandlerImpl) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrInvalidAction):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrActionExpired):
+		status = http.StatusGone
+	case errors.Is(err, ErrActionUsed):
+		status = http.StatusConflict
+	}
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "Unable to handle the action, please try again"
+	}
+	if !wantsHtml(r) {
+		utils.SendJsonWithStatus(w, status, map[string]string{"error": message})
+		return
+	}
+	sendActionPage(w, status, actionView{Title: message, Message: message})
+}
+
+// wantsHtml tells whether the request comes from a browser following a link rather than from an API client.
+func wantsHtml(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func sendActionPage(w http.ResponseWriter, status int, view actionView) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := actionPage.Execute(w, view)
+	if err != nil {
+		utils.SendHttpError(w, err)
+	}
+}
@@ -0,0 +1,559 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/events"
+	"manny-reminder/internal/health"
+	"manny-reminder/internal/metrics"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotRetention is how long the state of an event is kept after it ended.
+const snapshotRetention = 24 * time.Hour
+
+type RemindersService interface {
+	Sync(ctx context.Context) error
+	SyncUser(ctx context.Context, user *models.User) error
+	Dispatch(ctx context.Context) error
+	Run(ctx context.Context)
+	DescribeAction(ctx context.Context, token string) (ActionResult, error)
+	PerformAction(ctx context.Context, token string) (ActionResult, error)
+}
+
+// ServiceImpl keeps the reminders of every user in line with their calendars and sends them when due. Sync diffs
+// the upcoming events against their last seen state to schedule, reschedule or cancel deliveries, Dispatch sends
+// the due ones. Both are safe to run on several replicas at once.
+type ServiceImpl struct {
+	l            *zap.Logger
+	r            events.EventsRepository
+	as           auth.AuthService
+	es           events.EventsService
+	ns           Notifiers
+	c            config.RemindersConfig
+	syncBeat     *health.Heartbeat
+	dispatchBeat *health.Heartbeat
+	actionCodec  actionCodec
+	publicURL    string
+	renderer     Renderer
+	now          func() time.Time
+
+	mu       sync.Mutex
+	lastSync map[string]time.Time
+}
+
+func NewService(l *zap.Logger, r events.EventsRepository, as auth.AuthService, es events.EventsService, ns Notifiers, c config.RemindersConfig) *ServiceImpl {
+	return &ServiceImpl{
+		l: l, r: r, as: as, es: es, ns: ns, c: c,
+		// a loop is down once it missed a few runs
+		syncBeat:     health.NewHeartbeat(3 * c.SyncInterval),
+		dispatchBeat: health.NewHeartbeat(3 * c.DispatchInterval),
+		actionCodec:  newActionCodec(randomActionKey()),
+		now:          time.Now,
+		lastSync:     map[string]time.Time{},
+	}
+}
+
+// SyncHeartbeat is beaten after every successful sync.
+func (s *ServiceImpl) SyncHeartbeat() *health.Heartbeat {
+	return s.syncBeat
+}
+
+// DispatchHeartbeat is beaten after every successful dispatch.
+func (s *ServiceImpl) DispatchHeartbeat() *health.Heartbeat {
+	return s.dispatchBeat
+}
+
+// Run syncs and dispatches on their intervals until the context is done.
+func (s *ServiceImpl) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.loop(ctx, "sync", s.c.SyncInterval, s.Sync, s.syncBeat)
+	}()
+	go func() {
+		defer wg.Done()
+		s.loop(ctx, "dispatch", s.c.DispatchInterval, s.Dispatch, s.dispatchBeat)
+	}()
+	wg.Wait()
+}
+
+func (s *ServiceImpl) loop(ctx context.Context, name string, interval time.Duration, run func(context.Context) error, beat *health.Heartbeat) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := run(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.l.Error("Reminders "+name+" failed", zap.Error(err))
+		} else if err == nil {
+			beat.Beat()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync syncs the reminders of every user. A user failing to sync doesn't stop the others.
+func (s *ServiceImpl) Sync(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Reminders.Sync")
+	defer tracing.End(span, &err)
+
+	users, err := s.as.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	present := map[string]bool{}
+	for i := range users {
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+		present[users[i].Id.String()] = true
+		err = s.SyncUser(ctx, &users[i])
+		if err != nil {
+			s.l.Error("Unable to sync the reminders of the user", zap.Stringer("userId", users[i].Id), zap.Error(err))
+		}
+	}
+	s.observeSyncLag(present)
+
+	return s.r.PruneEventSnapshots(ctx, s.now().Add(-snapshotRetention))
+}
+
+// SyncUser schedules reminders for the upcoming events of the user within the horizon. Deliveries of events which
+// moved are replaced, those of events cancelled, declined or gone are cancelled, and the user is told about the
+// changes to events they had reminders for.
+func (s *ServiceImpl) SyncUser(ctx context.Context, user *models.User) error {
+	userId := user.Id.String()
+	now := s.now()
+	from, to := now, now.Add(s.c.Horizon)
+
+	current, err := s.es.GetEventsToSync(ctx, user, from, to)
+	if err != nil {
+		return err
+	}
+	snapshots, err := s.r.GetEventSnapshots(ctx, userId, from, to)
+	if err != nil {
+		return err
+	}
+	known := map[string]models.Event{}
+	for _, snapshot := range snapshots {
+		known[snapshot.Id] = snapshot
+	}
+
+	seen := map[string]bool{}
+	for _, event := range current {
+		// a provider may only tell the id and status of a cancelled event, what it was is in the snapshot
+		if previous, ok := known[event.Id]; ok && event.Status == models.EventCancelled && event.Start == "" {
+			previous.Status = models.EventCancelled
+			event = previous
+		}
+		start, ok := timedStart(event)
+		// events without an id can't be followed, all-day ones have no time to remind of and started ones are past
+		if event.Id == "" || !ok || start.Before(now) || seen[event.Id] {
+			continue
+		}
+		seen[event.Id] = true
+
+		err = s.syncEvent(ctx, user, event, start, now, known)
+		if err != nil {
+			return fmt.Errorf("event %s: %w", event.Id, err)
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		if seen[snapshot.Id] {
+			continue
+		}
+		// the event was deleted, moved out of the horizon or is no longer visible to the user
+		_, err = s.r.DeleteEventSnapshot(ctx, userId, snapshot)
+		if err != nil {
+			return err
+		}
+		err = s.r.CancelDeliveries(ctx, userId, snapshot.Id, nil, models.CancelEventChanged)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.lastSync[userId] = now
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *ServiceImpl) syncEvent(ctx context.Context, user *models.User, event models.Event, start time.Time, now time.Time, known map[string]models.Event) error {
+	userId := user.Id.String()
+	previous, ok := known[event.Id]
+	if !ok {
+		_, err := s.r.SaveEventSnapshot(ctx, userId, event, nil)
+		if err != nil {
+			return err
+		}
+	} else if changed(previous, event) {
+		won, err := s.r.SaveEventSnapshot(ctx, userId, event, &previous)
+		if err != nil {
+			return err
+		}
+		// a concurrent sync saving the change first tells the user about it
+		if won && s.c.NotifyChanges {
+			s.notifyChange(ctx, user, previous, event)
+		}
+	}
+
+	if !active(event) {
+		return s.r.CancelDeliveries(ctx, userId, event.Id, nil, models.CancelEventChanged)
+	}
+	err := s.r.CancelDeliveries(ctx, userId, event.Id, &start, models.CancelEventChanged)
+	if err != nil {
+		return err
+	}
+	return s.schedule(ctx, user, event, start, now)
+}
+
+// schedule adds a delivery per offset and channel of the rules of the user, or a delivery per escalation step for
+// important events.
+func (s *ServiceImpl) schedule(ctx context.Context, user *models.User, event models.Event, start time.Time, now time.Time) error {
+	if s.important(event) {
+		steps := s.c.Escalation.Steps
+		offsets := make([]time.Duration, len(steps))
+		for i, step := range steps {
+			offsets[i] = step.Offset
+		}
+		for _, i := range upcoming(offsets, start, now) {
+			step := i
+			err := s.addDelivery(ctx, user, event, start, steps[i].Offset, steps[i].Channel, &step)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rules := s.rules(user)
+	offsets := rules.Offsets()
+	for _, i := range upcoming(offsets, start, now) {
+		for _, channel := range rules.Channels {
+			err := s.addDelivery(ctx, user, event, start, offsets[i], channel, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rules are the reminder rules of the user, inherited from their team and the config, without the channels which
+// aren't set up.
+func (s *ServiceImpl) rules(user *models.User) models.ReminderRules {
+	rules := user.ReminderRules(models.RulesOf(s.c.Offsets, s.c.Channels))
+	var channels []string
+	for _, channel := range rules.Channels {
+		if _, ok := s.ns[channel]; ok {
+			channels = append(channels, channel)
+		}
+	}
+	rules.Channels = channels
+	return rules
+}
+
+func (s *ServiceImpl) addDelivery(ctx context.Context, user *models.User, event models.Event, start time.Time, offset time.Duration, channel string, step *int) error {
+	id := uuid.New()
+	created, err := s.r.AddDelivery(ctx, models.Delivery{
+		Id: &id, UserId: user.Id, EventId: event.Id, EventStart: start, OffsetMinutes: int(offset / time.Minute),
+		Channel: channel, SendAt: start.Add(-offset), Status: models.DeliveryPending, EscalationStep: step,
+	})
+	if err != nil {
+		return err
+	}
+	if created {
+		metrics.ObserveReminder(channel, metrics.ReminderScheduled)
+	}
+	return nil
+}
+
+// upcoming returns the indexes of the offsets whose reminder isn't due yet. Reminders already due when the event is
+// found are skipped, but the last of them is kept when no other is left, so a late event still gets one.
+func upcoming(offsets []time.Duration, start time.Time, now time.Time) []int {
+	var result []int
+	late := -1
+	for i, offset := range offsets {
+		if start.Add(-offset).Before(now) {
+			if late < 0 || offset < offsets[late] {
+				late = i
+			}
+			continue
+		}
+		result = append(result, i)
+	}
+	if len(result) == 0 && late >= 0 {
+		result = append(result, late)
+	}
+	return result
+}
+
+// important tells whether the reminders of the event escalate. Events are flagged when their reminders are
+// scheduled, flagging one which already has reminders adds the chain to them.
+func (s *ServiceImpl) important(event models.Event) bool {
+	c := s.c.Escalation
+	if !c.Enabled() {
+		return false
+	}
+	title := strings.ToLower(event.Title)
+	for _, keyword := range c.Keywords {
+		if strings.Contains(title, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	for _, organizer := range c.Organizers {
+		if strings.EqualFold(organizer, event.Organizer) {
+			return true
+		}
+	}
+	for _, calendar := range c.Calendars {
+		if strings.EqualFold(calendar, event.Calendar) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyChange tells the user an event they had reminders for moved or was cancelled. Declining it is their own
+// doing, so it goes unnoticed.
+func (s *ServiceImpl) notifyChange(ctx context.Context, user *models.User, previous models.Event, event models.Event) {
+	if !active(previous) {
+		return
+	}
+	n := Notification{User: user, Event: event, Previous: &previous}
+	switch {
+	case event.Status == models.EventCancelled:
+		n.Kind = KindCancelled
+	case event.ResponseStatus == models.ResponseDeclined:
+		return
+	case moved(previous, event):
+		n.Kind = KindMoved
+	default:
+		return
+	}
+
+	for _, channel := range s.rules(user).Channels {
+		notifier := s.ns[channel]
+		err := notifier.Notify(ctx, s.render(ctx, channel, n))
+		if err != nil {
+			s.l.Error("Unable to notify the user of the change",
+				zap.Stringer("userId", user.Id), zap.String("eventId", event.Id), zap.String("channel", channel), zap.Error(err))
+		}
+	}
+}
+
+// Dispatch claims the due deliveries and sends them. A delivery failing is retried after the retry delay until it
+// runs out of attempts.
+func (s *ServiceImpl) Dispatch(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "Reminders.Dispatch")
+	defer tracing.End(span, &err)
+
+	deliveries, err := s.r.ClaimDeliveries(ctx, s.now(), s.c.ClaimLease, s.c.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		// the claims left expire and another run picks them up
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+		err = s.deliver(ctx, d)
+		if err != nil {
+			s.l.Error("Unable to deliver the reminder",
+				zap.Stringer("deliveryId", d.Id), zap.String("channel", d.Channel), zap.Int("attempts", d.Attempts), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (s *ServiceImpl) deliver(ctx context.Context, d models.Delivery) (err error) {
+	ctx, span := tracing.Start(ctx, "Reminders.Deliver",
+		attribute.String("delivery.id", d.Id.String()), attribute.String("delivery.channel", d.Channel))
+	defer tracing.End(span, &err)
+
+	userId := d.UserId.String()
+	// the event may have changed since the delivery was scheduled and the next sync hasn't caught up yet
+	event, err := s.r.GetEventSnapshot(ctx, userId, d.EventId)
+	if err != nil {
+		return err
+	}
+	start, ok := time.Time{}, false
+	if event != nil {
+		start, ok = timedStart(*event)
+	}
+	if !ok || !start.Equal(d.EventStart) || !active(*event) {
+		return s.cancelDelivery(ctx, d, models.CancelEventChanged)
+	}
+	// a snoozed reminder may come due after the event is over
+	end, err := event.EndTime()
+	if err == nil && !end.After(s.now()) {
+		return s.cancelDelivery(ctx, d, models.CancelExpired)
+	}
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return s.cancelDelivery(ctx, d, models.CancelExpired)
+	}
+	// the user marked a reminder of the occurrence as done, later ones and those a sync added since stay unsent
+	acknowledged, err := s.r.IsAcknowledged(ctx, userId, d.EventId, d.EventStart)
+	if err != nil {
+		return err
+	}
+	if acknowledged {
+		metrics.ObserveReminder(d.Channel, metrics.ReminderStopped)
+		return s.cancelDelivery(ctx, d, models.CancelAcknowledged)
+	}
+	held, err := s.holdBack(ctx, d, user, start)
+	if held || err != nil {
+		return err
+	}
+
+	notifier, ok := s.ns[d.Channel]
+	if !ok {
+		metrics.ObserveReminder(d.Channel, metrics.ReminderFailed)
+		held, err = s.r.FailDelivery(ctx, d.Id, d.ClaimedAt, "unknown channel", nil)
+		return s.settled(d, held, err)
+	}
+	actions, err := s.actions(d, *event)
+	if err != nil {
+		return err
+	}
+	n := Notification{Kind: KindReminder, User: user, Event: *event, Delivery: &d, Actions: actions}
+	notifyErr := notifier.Notify(ctx, s.render(ctx, d.Channel, n))
+	if notifyErr != nil {
+		metrics.ObserveReminder(d.Channel, metrics.ReminderFailed)
+		var retryAt *time.Time
+		if d.Attempts < s.c.MaxAttempts {
+			t := s.now().Add(s.c.RetryDelay)
+			retryAt = &t
+		}
+		held, err = s.r.FailDelivery(ctx, d.Id, d.ClaimedAt, notifyErr.Error(), retryAt)
+		err = s.settled(d, held, err)
+		if err != nil {
+			return err
+		}
+		return notifyErr
+	}
+	metrics.ObserveReminder(d.Channel, metrics.ReminderSent)
+	held, err = s.r.CompleteDelivery(ctx, d.Id, d.ClaimedAt, s.now())
+	return s.settled(d, held, err)
+}
+
+// cancelDelivery cancels a claimed delivery which must not be sent anymore for the reason.
+func (s *ServiceImpl) cancelDelivery(ctx context.Context, d models.Delivery, reason string) error {
+	held, err := s.r.CancelDelivery(ctx, d.Id, d.ClaimedAt, reason)
+	return s.settled(d, held, err)
+}
+
+// settled tells whether updating a claimed delivery failed. A claim held past the lease may have been taken over by
+// another scheduler, which then decides what becomes of the delivery, so the update was left out.
+func (s *ServiceImpl) settled(d models.Delivery, held bool, err error) error {
+	if err == nil && !held {
+		s.l.Warn("The claim of the delivery was taken over, leaving it to the other scheduler",
+			zap.Stringer("deliveryId", d.Id), zap.String("channel", d.Channel))
+	}
+	return err
+}
+
+// observeSyncLag reports how long ago every user was synced and forgets the users who are gone.
+func (s *ServiceImpl) observeSyncLag(present map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for userId, last := range s.lastSync {
+		if !present[userId] {
+			delete(s.lastSync, userId)
+			metrics.DeleteSyncLag(userId)
+			continue
+		}
+		metrics.SetSyncLag(userId, now.Sub(last))
+	}
+}
+
+// holdBack keeps a reminder due during the quiet hours of the user from being sent, following their policy: it is
+// dropped, or deferred to the end of the quiet hours unless the event started by then. It returns false when the
+// reminder is to be sent.
+func (s *ServiceImpl) holdBack(ctx context.Context, d models.Delivery, user *models.User, start time.Time) (bool, error) {
+	q := user.QuietHours
+	if q == nil {
+		return false, nil
+	}
+	loc := user.Location()
+	now := s.now().In(loc)
+	if !q.Quiet(now) {
+		return false, nil
+	}
+	if q.Policy == models.QuietDeliver && q.Quiet(start.In(loc)) {
+		return false, nil
+	}
+
+	until := q.Until(now)
+	if q.Policy == models.QuietDrop || q.Quiet(until) || !until.Before(start) {
+		s.l.Debug("Dropped the reminder due in quiet hours", zap.Stringer("deliveryId", d.Id), zap.String("policy", q.Policy))
+		metrics.ObserveReminder(d.Channel, metrics.ReminderDropped)
+		return true, s.cancelDelivery(ctx, d, models.CancelQuietHours)
+	}
+	s.l.Debug("Deferred the reminder due in quiet hours", zap.Stringer("deliveryId", d.Id), zap.Time("until", until))
+	metrics.ObserveReminder(d.Channel, metrics.ReminderDeferred)
+	held, err := s.r.DeferDelivery(ctx, d.Id, d.ClaimedAt, until)
+	return true, s.settled(d, held, err)
+}
+
+// timedStart returns the start of an event with a time, all-day events have a plain date.
+func timedStart(event models.Event) (time.Time, bool) {
+	start, err := time.Parse(time.RFC3339, event.Start)
+	return start, err == nil
+}
+
+// active tells whether the user is still expected at the event.
+func active(event models.Event) bool {
+	return event.Status != models.EventCancelled && event.ResponseStatus != models.ResponseDeclined
+}
+
+func moved(previous models.Event, event models.Event) bool {
+	previousStart, _ := timedStart(previous)
+	start, _ := timedStart(event)
+	return !previousStart.Equal(start)
+}
+
+// changed tells whether the event differs from its last seen state, comparing the start as an instant since the
+// snapshots are in UTC.
+func changed(previous models.Event, event models.Event) bool {
+	previousEnd, _ := previous.EndTime()
+	end, _ := event.EndTime()
+	return moved(previous, event) || !previousEnd.Equal(end) || previous.Status != event.Status ||
+		previous.ResponseStatus != event.ResponseStatus || previous.Title != event.Title || previous.JoinURL != event.JoinURL ||
+		previous.Organizer != event.Organizer || !sameAttendees(previous.Attendees, event.Attendees)
+}
+
+func sameAttendees(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
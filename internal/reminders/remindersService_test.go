@@ -0,0 +1,471 @@
+package reminders
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/templates"
+	"manny-reminder/mocks"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+
+func TestService_SyncUser_SchedulesNewEvent(t *testing.T) {
+	er, es, _, _, s := initService(t)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(2*time.Hour))
+	es.On("GetEventsToSync", mock.Anything, user, now, now.Add(24*time.Hour)).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, user.Id.String(), now, now.Add(24*time.Hour)).Return(models.Events{}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, user.Id.String(), event, (*models.Event)(nil)).Return(true, nil)
+	start := now.Add(2 * time.Hour)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", &start, models.CancelEventChanged).Return(nil)
+	er.On("AddDelivery", mock.Anything, mock.MatchedBy(func(d models.Delivery) bool {
+		return d.EventId == "e1" && d.EventStart.Equal(start) && d.SendAt.Equal(start.Add(-10*time.Minute)) &&
+			d.OffsetMinutes == 10 && d.Channel == ChannelLog && d.Status == models.DeliveryPending
+	})).Return(true, nil).Once()
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+}
+
+func TestService_SyncUser_SkipsAllDayAndStartedEvents(t *testing.T) {
+	er, es, _, _, s := initService(t)
+	user := generateUser()
+	allDay := models.Event{Id: "e1", Title: "Holiday", Start: "2022-06-02", End: "2022-06-03"}
+	started := generateEvent("e2", now.Add(-time.Minute))
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{allDay, started}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{}, nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+}
+
+func TestService_SyncUser_MovedEventReschedulesAndNotifies(t *testing.T) {
+	er, es, _, n, s := initService(t)
+	user := generateUser()
+	previous := generateEvent("e1", now.Add(2*time.Hour))
+	event := generateEvent("e1", now.Add(3*time.Hour))
+	start := now.Add(3 * time.Hour)
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{previous}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, user.Id.String(), event, &previous).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", &start, models.CancelEventChanged).Return(nil)
+	er.On("AddDelivery", mock.Anything, mock.MatchedBy(func(d models.Delivery) bool {
+		return d.EventStart.Equal(start)
+	})).Return(true, nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.Len(t, n.sent, 1)
+	assert.Equal(t, KindMoved, n.sent[0].Kind)
+	assert.Equal(t, `Your 3:00PM meeting "Meeting e1" moved to 4:00PM`, n.sent[0].Text())
+}
+
+func TestService_SyncUser_ChangeSavedByAnotherSyncIsNotNotified(t *testing.T) {
+	er, es, _, n, s := initService(t)
+	user := generateUser()
+	previous := generateEvent("e1", now.Add(2*time.Hour))
+	event := generateEvent("e1", now.Add(3*time.Hour))
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{previous}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, event, &previous).Return(false, nil)
+	er.On("CancelDeliveries", mock.Anything, mock.Anything, "e1", mock.Anything, models.CancelEventChanged).Return(nil)
+	er.On("AddDelivery", mock.Anything, mock.Anything).Return(false, nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.Empty(t, n.sent)
+}
+
+func TestService_SyncUser_DeclinedEventCancelsSilently(t *testing.T) {
+	er, es, _, n, s := initService(t)
+	user := generateUser()
+	previous := generateEvent("e1", now.Add(2*time.Hour))
+	event := previous
+	event.ResponseStatus = models.ResponseDeclined
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{previous}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, event, &previous).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", (*time.Time)(nil), models.CancelEventChanged).Return(nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.Empty(t, n.sent)
+}
+
+func TestService_SyncUser_CancelledEventCancelsAndNotifies(t *testing.T) {
+	er, es, _, n, s := initService(t)
+	user := generateUser()
+	previous := generateEvent("e1", now.Add(2*time.Hour))
+	event := previous
+	event.Status = models.EventCancelled
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{previous}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, event, &previous).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", (*time.Time)(nil), models.CancelEventChanged).Return(nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.Len(t, n.sent, 1)
+	assert.Equal(t, `Your 3:00PM meeting "Meeting e1" was cancelled`, n.sent[0].Text())
+}
+
+func TestService_SyncUser_CancelledEventOnlyIdentifiedNotifiesWithSnapshot(t *testing.T) {
+	er, es, _, n, s := initService(t)
+	user := generateUser()
+	previous := generateEvent("e1", now.Add(2*time.Hour))
+	cancelled := previous
+	cancelled.Status = models.EventCancelled
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(models.Events{{Id: "e1", Status: models.EventCancelled}}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{previous}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, cancelled, &previous).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", (*time.Time)(nil), models.CancelEventChanged).Return(nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+	assert.Len(t, n.sent, 1)
+	assert.Equal(t, `Your 3:00PM meeting "Meeting e1" was cancelled`, n.sent[0].Text())
+}
+
+func TestService_SyncUser_GoneEventCancels(t *testing.T) {
+	er, es, _, _, s := initService(t)
+	user := generateUser()
+	previous := generateEvent("e1", now.Add(2*time.Hour))
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{previous}, nil)
+	er.On("DeleteEventSnapshot", mock.Anything, user.Id.String(), previous).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, user.Id.String(), "e1", (*time.Time)(nil), models.CancelEventChanged).Return(nil)
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+}
+
+func TestService_SyncUser_LateEventKeepsLastReminder(t *testing.T) {
+	er, es, _, _, s := initService(t)
+	s.c.Offsets = []time.Duration{time.Hour, 10 * time.Minute}
+	user := generateUser()
+	event := generateEvent("e1", now.Add(5*time.Minute))
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, event, (*models.Event)(nil)).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, mock.Anything, "e1", mock.Anything, models.CancelEventChanged).Return(nil)
+	er.On("AddDelivery", mock.Anything, mock.MatchedBy(func(d models.Delivery) bool {
+		return d.OffsetMinutes == 10
+	})).Return(true, nil).Once()
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+}
+
+func TestService_SyncUser_MemberRulesOverrideTeam(t *testing.T) {
+	er, es, _, _, s := initService(t)
+	user := generateUser()
+	teamId := uuid.New()
+	user.Team = &models.Team{Id: &teamId, Name: "Sales", Rules: models.ReminderRules{OffsetMinutes: []int{30}, Channels: []string{ChannelLog, "unknown"}}}
+	user.Rules = models.ReminderRules{OffsetMinutes: []int{5, 60}}
+	event := generateEvent("e1", now.Add(2*time.Hour))
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, event, (*models.Event)(nil)).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, mock.Anything, "e1", mock.Anything, models.CancelEventChanged).Return(nil)
+	// the offsets of the user on the channels of the team which are set up
+	for _, offset := range []int{5, 60} {
+		offset := offset
+		er.On("AddDelivery", mock.Anything, mock.MatchedBy(func(d models.Delivery) bool {
+			return d.OffsetMinutes == offset && d.Channel == ChannelLog
+		})).Return(true, nil).Once()
+	}
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+}
+
+func TestService_SyncUser_ImportantEventEscalates(t *testing.T) {
+	er, es, _, _, s := initService(t)
+	s.c.Escalation = config.EscalationConfig{
+		Organizers: []string{"ceo@example.com"},
+		Steps: []config.EscalationStep{
+			{Channel: ChannelEmail, Offset: time.Hour}, {Channel: ChannelSlack, Offset: 10 * time.Minute}, {Channel: "sms", Offset: 2 * time.Minute},
+		},
+	}
+	user := generateUser()
+	event := generateEvent("e1", now.Add(30*time.Minute))
+	event.Organizer = "CEO@example.com"
+	es.On("GetEventsToSync", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{event}, nil)
+	er.On("GetEventSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Events{}, nil)
+	er.On("SaveEventSnapshot", mock.Anything, mock.Anything, event, (*models.Event)(nil)).Return(true, nil)
+	er.On("CancelDeliveries", mock.Anything, mock.Anything, "e1", mock.Anything, models.CancelEventChanged).Return(nil)
+	// the email step was due already
+	for step, channel := range map[int]string{1: ChannelSlack, 2: "sms"} {
+		step, channel := step, channel
+		er.On("AddDelivery", mock.Anything, mock.MatchedBy(func(d models.Delivery) bool {
+			return d.Channel == channel && d.EscalationStep != nil && *d.EscalationStep == step
+		})).Return(true, nil).Once()
+	}
+
+	err := s.SyncUser(context.Background(), user)
+
+	assert.Nil(t, err)
+}
+
+func TestService_Dispatch_AcknowledgedEscalationStops(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	step := 1
+	d.EscalationStep = &step
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(true, nil)
+	er.On("CancelDelivery", mock.Anything, d.Id, d.ClaimedAt, models.CancelAcknowledged).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, n.sent)
+}
+
+func TestService_Dispatch_Sends(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, now, 2*time.Minute, 50).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, user.Id.String(), "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	er.On("CompleteDelivery", mock.Anything, d.Id, d.ClaimedAt, now).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, n.sent, 1)
+	assert.Equal(t, KindReminder, n.sent[0].Kind)
+	assert.Equal(t, `"Meeting e1" starts at 1:10PM`, n.sent[0].Text())
+}
+
+func TestNotification_Text_TimeZoneOfUser(t *testing.T) {
+	user := generateUser()
+	timeZone := "America/New_York"
+	user.TimeZone = &timeZone
+	// the clocks go back an hour overnight
+	previous := generateEvent("e1", time.Date(2022, 11, 5, 13, 10, 0, 0, time.UTC))
+	event := generateEvent("e1", time.Date(2022, 11, 6, 13, 10, 0, 0, time.UTC))
+
+	n := Notification{Kind: KindMoved, User: user, Event: event, Previous: &previous}
+
+	assert.Equal(t, `Your 9:10AM meeting "Meeting e1" moved to Sun Nov 6 8:10AM`, n.Text())
+}
+
+func TestService_Dispatch_RendersTemplate(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	tr := mocks.NewTemplatesRepository(t)
+	engine, err := templates.NewEngine(zap.NewNop(), tr, "", "en")
+	assert.Nil(t, err)
+	s.SetRenderer(engine)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	er.On("CompleteDelivery", mock.Anything, d.Id, d.ClaimedAt, now).Return(true, nil)
+	tr.On("FindTemplates", mock.Anything, KindReminder, models.FormatText, []string{ChannelLog, templates.ChannelDefault}, []string{"en"}).
+		Return(models.MessageTemplates{{Channel: ChannelLog, Locale: "en", Kind: KindReminder, Format: models.FormatText,
+			Body: `{{.Event.Title}} in 10 minutes, at {{format "15:04" .Start}}`}}, nil)
+	tr.On("FindTemplates", mock.Anything, KindReminder, models.FormatHTML, mock.Anything, mock.Anything).Return(models.MessageTemplates{}, nil)
+
+	err = s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, n.sent, 1)
+	assert.Equal(t, "Meeting e1 in 10 minutes, at 13:10", n.sent[0].Text())
+	assert.Equal(t, "<p><strong>Meeting e1</strong> starts at 1:10PM</p>", n.sent[0].HTML)
+}
+
+func TestService_Dispatch_QuietHoursDefer(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	user := generateUser()
+	timeZone := "Europe/Paris"
+	user.TimeZone = &timeZone
+	// 13:00 UTC is 15:00 in Paris, quiet until 16:00
+	user.QuietHours = &models.QuietHours{Start: "12:00", End: "16:00", Policy: models.QuietDefer}
+	event := generateEvent("e1", now.Add(2*time.Hour))
+	d := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	er.On("DeferDelivery", mock.Anything, d.Id, d.ClaimedAt, mock.MatchedBy(func(sendAt time.Time) bool {
+		return sendAt.Equal(now.Add(time.Hour))
+	})).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, n.sent)
+}
+
+func TestService_Dispatch_QuietHoursDeferPastStartDrops(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	user := generateUser()
+	// a Wednesday, the user only works on Mondays
+	user.QuietHours = &models.QuietHours{WorkingDays: []time.Weekday{time.Monday}, Policy: models.QuietDefer}
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	er.On("CancelDelivery", mock.Anything, d.Id, d.ClaimedAt, models.CancelQuietHours).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, n.sent)
+}
+
+func TestService_Dispatch_QuietHoursDeliverEventWithinQuietHours(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	user := generateUser()
+	user.QuietHours = &models.QuietHours{Start: "22:00", End: "14:00", Policy: models.QuietDeliver}
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	er.On("CompleteDelivery", mock.Anything, d.Id, d.ClaimedAt, now).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, n.sent, 1)
+}
+
+func TestService_Dispatch_EventMovedCancels(t *testing.T) {
+	er, _, _, n, s := initService(t)
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	moved := generateEvent("e1", now.Add(time.Hour))
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&moved, nil)
+	er.On("CancelDelivery", mock.Anything, d.Id, d.ClaimedAt, models.CancelEventChanged).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, n.sent)
+}
+
+func TestService_Dispatch_FailureRetries(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	n.err = errors.New("unreachable")
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 1)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	retryAt := now.Add(time.Minute)
+	er.On("FailDelivery", mock.Anything, d.Id, d.ClaimedAt, "unreachable", &retryAt).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+}
+
+func TestService_Dispatch_LastAttemptFails(t *testing.T) {
+	er, _, as, n, s := initService(t)
+	n.err = errors.New("unreachable")
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := generateDelivery(user, event, 3)
+	er.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.Deliveries{d}, nil)
+	er.On("GetEventSnapshot", mock.Anything, mock.Anything, "e1").Return(&event, nil)
+	as.On("GetUser", mock.Anything, mock.Anything).Return(user, nil)
+	er.On("IsAcknowledged", mock.Anything, user.Id.String(), "e1", d.EventStart).Return(false, nil)
+	er.On("FailDelivery", mock.Anything, d.Id, d.ClaimedAt, "unreachable", (*time.Time)(nil)).Return(true, nil)
+
+	err := s.Dispatch(context.Background())
+
+	assert.Nil(t, err)
+}
+
+type fakeNotifier struct {
+	err  error
+	sent []Notification
+}
+
+func (n *fakeNotifier) Channel() string {
+	return ChannelLog
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, notification Notification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func initService(t *testing.T) (*mocks.EventsRepository, *mocks.EventsService, *mocks.AuthService, *fakeNotifier, *ServiceImpl) {
+	er := mocks.NewEventsRepository(t)
+	es := mocks.NewEventsService(t)
+	as := mocks.NewAuthService(t)
+	n := &fakeNotifier{}
+	c := config.RemindersConfig{
+		Offsets: []time.Duration{10 * time.Minute}, Channels: []string{ChannelLog}, Horizon: 24 * time.Hour,
+		SyncInterval: 5 * time.Minute, DispatchInterval: 15 * time.Second, BatchSize: 50, ClaimLease: 2 * time.Minute,
+		MaxAttempts: 3, RetryDelay: time.Minute, NotifyChanges: true,
+	}
+	s := NewService(zap.NewNop(), er, as, es, Notifiers{ChannelLog: n}, c)
+	s.now = func() time.Time { return now }
+	return er, es, as, n, s
+}
+
+func generateUser() *models.User {
+	id := uuid.New()
+	email := "user@example.com"
+	return &models.User{Id: &id, Email: &email}
+}
+
+func generateEvent(id string, start time.Time) models.Event {
+	return models.Event{
+		Id: id, Title: "Meeting " + id, Start: start.Format(time.RFC3339), End: start.Add(30 * time.Minute).Format(time.RFC3339),
+		Status: models.EventConfirmed, ResponseStatus: models.ResponseAccepted,
+	}
+}
+
+func generateDelivery(user *models.User, event models.Event, attempts int) models.Delivery {
+	id := uuid.New()
+	start, _ := event.StartTime()
+	claimedAt := now
+	return models.Delivery{
+		Id: &id, UserId: user.Id, EventId: event.Id, EventStart: start, OffsetMinutes: 10, Channel: ChannelLog,
+		SendAt: start.Add(-10 * time.Minute), Status: models.DeliverySending, Attempts: attempts, ClaimedAt: &claimedAt,
+	}
+}
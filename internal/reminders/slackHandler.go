@@ -0,0 +1,143 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"io"
+	"manny-reminder/internal/tracing"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// slackMaxSkew is how old a signed Slack request may be, older ones could be replayed.
+	slackMaxSkew = 5 * time.Minute
+	// slackActionTimeout bounds the actions of an interaction and their answers, run after it was acknowledged.
+	slackActionTimeout = 30 * time.Second
+)
+
+// SlackHandler receives the clicks on the buttons of Slack reminders, when interactivity is enabled for the app.
+type SlackHandler struct {
+	l      *zap.Logger
+	rs     RemindersService
+	secret []byte
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewSlackHandler(l *zap.Logger, rs RemindersService, signingSecret string) *SlackHandler {
+	return &SlackHandler{
+		l:      l,
+		rs:     rs,
+		secret: []byte(signingSecret),
+		client: &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+}
+
+type slackInteraction struct {
+	Type        string `json:"type"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionId string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// Interactions acknowledges the clicks on reminder buttons right away, Slack wants an answer within 3 seconds, then
+// performs their actions and tells the user how it went through the response URL, in a message only they see.
+func (h SlackHandler) Interactions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !h.verify(r.Header, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var interaction slackInteraction
+	err = json.Unmarshal([]byte(form.Get("payload")), &interaction)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	// the request ends with the acknowledgement, the actions run on their own
+	go h.perform(tracing.Detach(r.Context()), interaction)
+}
+
+func (h SlackHandler) perform(ctx context.Context, interaction slackInteraction) {
+	ctx, cancel := context.WithTimeout(ctx, slackActionTimeout)
+	defer cancel()
+	for _, action := range interaction.Actions {
+		// the join button only opens its link
+		if !strings.HasPrefix(action.ActionId, slackActionPrefix) {
+			continue
+		}
+		message := "Unable to handle the action, please try again"
+		result, err := h.rs.PerformAction(ctx, action.Value)
+		switch {
+		case err == nil:
+			message = result.Message
+		case errors.Is(err, ErrInvalidAction), errors.Is(err, ErrActionExpired), errors.Is(err, ErrActionUsed):
+			message = err.Error()
+		default:
+			h.l.Error("Unable to perform the Slack action", zap.Error(err))
+		}
+		h.respond(ctx, interaction.ResponseURL, message)
+	}
+}
+
+// verify checks the signature Slack computes over the timestamp and body with the signing secret.
+func (h SlackHandler) verify(header http.Header, body []byte) bool {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || math.Abs(h.now().Sub(time.Unix(seconds, 0)).Seconds()) > slackMaxSkew.Seconds() {
+		return false
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature")))
+}
+
+func (h SlackHandler) respond(ctx context.Context, responseURL string, message string) {
+	if responseURL == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"response_type":    "ephemeral",
+		"replace_original": false,
+		"text":             message,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		h.l.Error("Unable to answer the Slack interaction", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		h.l.Error("Unable to answer the Slack interaction", zap.Error(err))
+		return
+	}
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,218 @@
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/templates"
+	"manny-reminder/internal/tracing"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ChannelSlack = "slack"
+
+// slackActionPrefix starts the action id of the reminder buttons, their value is the action token.
+const slackActionPrefix = "reminder_action_"
+
+// slackError is a Web API call answered with ok false, or throttled.
+type slackError struct {
+	Method     string
+	Code       string
+	RetryAfter string
+}
+
+func (e *slackError) Error() string {
+	if e.RetryAfter != "" {
+		return fmt.Sprintf("slack %s: rate limited, retry after %ss", e.Method, e.RetryAfter)
+	}
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+// SlackNotifier posts reminders with the bot token, to the DM of the user or to the configured channel.
+type SlackNotifier struct {
+	l           *zap.Logger
+	c           config.SlackConfig
+	client      *http.Client
+	interactive bool
+
+	mu sync.Mutex
+	// dms caches the DM channel of every email, looking it up takes two calls
+	dms map[string]string
+}
+
+func NewSlackNotifier(l *zap.Logger, c config.SlackConfig) *SlackNotifier {
+	if !strings.HasSuffix(c.APIURL, "/") {
+		c.APIURL += "/"
+	}
+	return &SlackNotifier{
+		l:           l,
+		c:           c,
+		client:      &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second},
+		interactive: c.SigningSecret != "",
+		dms:         map[string]string{},
+	}
+}
+
+func (n *SlackNotifier) Channel() string {
+	return ChannelSlack
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, notification Notification) error {
+	channel, err := n.channel(ctx, notification)
+	if err != nil {
+		return err
+	}
+	blocks, err := json.Marshal(n.blocks(notification))
+	if err != nil {
+		return err
+	}
+	return n.call(ctx, "chat.postMessage", url.Values{
+		"channel": {channel},
+		// shown in notifications and by clients without blocks
+		"text":   {notification.Text()},
+		"blocks": {string(blocks)},
+	}, nil)
+}
+
+// channel returns the configured channel, or opens the DM with the Slack user having the email of the user.
+func (n *SlackNotifier) channel(ctx context.Context, notification Notification) (string, error) {
+	if n.c.Channel != "" {
+		return n.c.Channel, nil
+	}
+	if notification.User.Email == nil || *notification.User.Email == "" {
+		return "", errors.New("slack: the user has no email to find them by")
+	}
+	email := *notification.User.Email
+
+	n.mu.Lock()
+	dm, ok := n.dms[email]
+	n.mu.Unlock()
+	if ok {
+		return dm, nil
+	}
+
+	var lookup struct {
+		User struct {
+			Id string `json:"id"`
+		} `json:"user"`
+	}
+	err := n.call(ctx, "users.lookupByEmail", url.Values{"email": {email}}, &lookup)
+	if err != nil {
+		return "", err
+	}
+	var open struct {
+		Channel struct {
+			Id string `json:"id"`
+		} `json:"channel"`
+	}
+	err = n.call(ctx, "conversations.open", url.Values{"users": {lookup.User.Id}}, &open)
+	if err != nil {
+		return "", err
+	}
+
+	n.mu.Lock()
+	n.dms[email] = open.Channel.Id
+	n.mu.Unlock()
+	n.l.Debug("Opened the Slack DM of the user", zap.Stringer("userId", notification.User.Id), zap.String("slackUserId", lookup.User.Id))
+	return open.Channel.Id, nil
+}
+
+// blocks lays the notification out with Block Kit: the event and its time, then the join link and the actions.
+func (n *SlackNotifier) blocks(notification Notification) []interface{} {
+	start, _ := notification.Event.StartIn(notification.User.Location())
+	text := slackEscape(notification.Text())
+	if notification.Kind == KindReminder && notification.Message == "" {
+		text = fmt.Sprintf("*%s*\nStarts at %s", slackEscape(notification.Event.Title), templates.FormatTime(start, start))
+	}
+	blocks := []interface{}{
+		map[string]interface{}{"type": "section", "text": slackText("mrkdwn", text)},
+	}
+
+	var buttons []interface{}
+	if notification.Event.JoinURL != "" && notification.Kind != KindCancelled {
+		buttons = append(buttons, map[string]interface{}{
+			"type": "button", "action_id": "join", "text": slackText("plain_text", "Join"), "url": notification.Event.JoinURL,
+		})
+	}
+	for i, action := range notification.Actions {
+		button := map[string]interface{}{
+			"type":      "button",
+			"action_id": fmt.Sprintf("%s%d", slackActionPrefix, i),
+			"text":      slackText("plain_text", action.Label),
+			"value":     action.Token,
+		}
+		// without interactivity Slack can't tell us about the click, the button opens the action link instead
+		if !n.interactive && action.URL != "" {
+			button["url"] = action.URL
+		}
+		if action.Name == ActionDone {
+			button["style"] = "primary"
+		}
+		if n.interactive || action.URL != "" {
+			buttons = append(buttons, button)
+		}
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "actions", "elements": buttons})
+	}
+	return blocks
+}
+
+// call posts a form to a Web API method and decodes the response into result, when set.
+func (n *SlackNotifier) call(ctx context.Context, method string, form url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.c.APIURL+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+n.c.BotToken)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &slackError{Method: method, RetryAfter: resp.Header.Get("Retry-After")}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &slackError{Method: method, Code: resp.Status}
+	}
+
+	var body struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, &body)
+	if err != nil {
+		return fmt.Errorf("slack %s: %w", method, err)
+	}
+	if !body.Ok {
+		return &slackError{Method: method, Code: body.Error}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(b, result)
+}
+
+func slackText(textType string, text string) map[string]interface{} {
+	return map[string]interface{}{"type": textType, "text": text}
+}
+
+// slackEscape escapes the characters Slack reads as markup in mrkdwn text.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
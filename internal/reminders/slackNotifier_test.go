@@ -0,0 +1,218 @@
+package reminders
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSlack stands in for the Slack Web API, recording the calls and the posted messages.
+type fakeSlack struct {
+	mu       sync.Mutex
+	calls    []string
+	messages []url.Values
+	users    map[string]string
+}
+
+func (f *fakeSlack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+		_, _ = fmt.Fprint(w, `{"ok": false, "error": "invalid_auth"}`)
+		return
+	}
+	_ = r.ParseForm()
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	f.calls = append(f.calls, method)
+	switch method {
+	case "users.lookupByEmail":
+		id, ok := f.users[r.Form.Get("email")]
+		if !ok {
+			_, _ = fmt.Fprint(w, `{"ok": false, "error": "users_not_found"}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"ok": true, "user": {"id": %q}}`, id)
+	case "conversations.open":
+		_, _ = fmt.Fprintf(w, `{"ok": true, "channel": {"id": "D%s"}}`, r.Form.Get("users"))
+	case "chat.postMessage":
+		f.messages = append(f.messages, r.Form)
+		_, _ = fmt.Fprint(w, `{"ok": true, "ts": "1654074000.000100"}`)
+	default:
+		_, _ = fmt.Fprint(w, `{"ok": false, "error": "unknown_method"}`)
+	}
+}
+
+func TestSlackNotifier_PostsToDmOfUserByEmail(t *testing.T) {
+	slack, n := initSlack(t, config.SlackConfig{})
+	user := generateUser()
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	event.JoinURL = "https://meet.google.com/abc-defg-hij"
+	notification := Notification{Kind: KindReminder, User: user, Event: event, Actions: []Action{
+		{Name: ActionSnooze, Label: "Snooze 5 min", Token: "t1", URL: "https://manny.example.com/reminders/actions/t1"},
+		{Name: ActionDone, Label: "Done", Token: "t2", URL: "https://manny.example.com/reminders/actions/t2"},
+	}}
+
+	err := n.Notify(context.Background(), notification)
+	assert.Nil(t, err)
+	err = n.Notify(context.Background(), notification)
+	assert.Nil(t, err)
+
+	// the DM is looked up once
+	assert.Equal(t, []string{"users.lookupByEmail", "conversations.open", "chat.postMessage", "chat.postMessage"}, slack.calls)
+	message := slack.messages[0]
+	assert.Equal(t, "DU123", message.Get("channel"))
+	assert.Equal(t, `"Meeting e1" starts at 1:10PM`, message.Get("text"))
+	blocks := message.Get("blocks")
+	assert.Contains(t, blocks, `"text":"*Meeting e1*\nStarts at 1:10PM"`)
+	assert.Contains(t, blocks, `"url":"https://meet.google.com/abc-defg-hij"`)
+	assert.Contains(t, blocks, `"url":"https://manny.example.com/reminders/actions/t1"`)
+	assert.Contains(t, blocks, `"value":"t2"`)
+}
+
+func TestSlackNotifier_PostsToConfiguredChannel(t *testing.T) {
+	slack, n := initSlack(t, config.SlackConfig{Channel: "C042"})
+	previous := generateEvent("e1", now.Add(time.Hour))
+	event := generateEvent("e1", now.Add(2*time.Hour))
+
+	err := n.Notify(context.Background(), Notification{Kind: KindMoved, User: generateUser(), Event: event, Previous: &previous})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"chat.postMessage"}, slack.calls)
+	assert.Equal(t, "C042", slack.messages[0].Get("channel"))
+	assert.Contains(t, slack.messages[0].Get("blocks"), `moved to 3:00PM`)
+}
+
+func TestSlackNotifier_UnknownEmail(t *testing.T) {
+	_, n := initSlack(t, config.SlackConfig{})
+	user := generateUser()
+	email := "nobody@example.com"
+	user.Email = &email
+
+	err := n.Notify(context.Background(), Notification{Kind: KindReminder, User: user, Event: generateEvent("e1", now)})
+
+	assert.EqualError(t, err, "slack users.lookupByEmail: users_not_found")
+}
+
+func TestSlackNotifier_InteractiveButtonsHaveNoLinks(t *testing.T) {
+	_, n := initSlack(t, config.SlackConfig{SigningSecret: "secret"})
+
+	blocks, err := json.Marshal(n.blocks(Notification{Kind: KindReminder, Event: generateEvent("e1", now), Actions: []Action{
+		{Name: ActionDone, Label: "Done", Token: "t1", URL: "https://manny.example.com/reminders/actions/t1"},
+	}}))
+
+	assert.Nil(t, err)
+	assert.Contains(t, string(blocks), `"action_id":"reminder_action_0"`)
+	assert.NotContains(t, string(blocks), "https://manny.example.com")
+}
+
+func TestSlackHandler_PerformsClickedAction(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(generateUser(), event)
+	token := actionTokenFor(t, s, d, event, 0)
+	mockResolveAction(er, d, &event)
+	er.On("SnoozeDelivery", mock.Anything, d.Id, 0, now.Add(5*time.Minute)).Return(true, nil)
+	responses := make(chan string, 1)
+	responseSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		responses <- body.Text
+	}))
+	defer responseSrv.Close()
+	h := NewSlackHandler(zap.NewNop(), s, "secret")
+	h.now = func() time.Time { return now }
+	payload := fmt.Sprintf(`{"type": "block_actions", "response_url": %q, "actions": [{"action_id": "reminder_action_0", "value": %q}]}`,
+		responseSrv.URL, token)
+	body := url.Values{"payload": {payload}}.Encode()
+
+	rec := httptest.NewRecorder()
+	h.Interactions(rec, signedSlackRequest(body, now, "secret"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Snoozed for 5 minutes", <-responses)
+}
+
+func TestSlackHandler_AcknowledgesBeforePerformingTheAction(t *testing.T) {
+	er, _, _, _, s := initService(t)
+	event := generateEvent("e1", now.Add(10*time.Minute))
+	d := sentDelivery(generateUser(), event)
+	token := actionTokenFor(t, s, d, event, 0)
+	mockResolveAction(er, d, &event)
+	release := make(chan time.Time)
+	er.On("SnoozeDelivery", mock.Anything, d.Id, 0, now.Add(5*time.Minute)).WaitUntil(release).Return(true, nil)
+	responses := make(chan string, 1)
+	responseSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		responses <- body.Text
+	}))
+	defer responseSrv.Close()
+	h := NewSlackHandler(zap.NewNop(), s, "secret")
+	h.now = func() time.Time { return now }
+	payload := fmt.Sprintf(`{"type": "block_actions", "response_url": %q, "actions": [{"action_id": "reminder_action_0", "value": %q}]}`,
+		responseSrv.URL, token)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	rec := httptest.NewRecorder()
+	h.Interactions(rec, signedSlackRequest(url.Values{"payload": {payload}}.Encode(), now, "secret").WithContext(ctx))
+	// the request is over once acknowledged
+	cancel()
+	close(release)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Snoozed for 5 minutes", <-responses)
+}
+
+func TestSlackHandler_RejectsBadSignature(t *testing.T) {
+	_, _, _, _, s := initService(t)
+	h := NewSlackHandler(zap.NewNop(), s, "secret")
+	h.now = func() time.Time { return now }
+	body := url.Values{"payload": {`{"type": "block_actions"}`}}.Encode()
+
+	forged := httptest.NewRecorder()
+	h.Interactions(forged, signedSlackRequest(body, now, "another secret"))
+	replayed := httptest.NewRecorder()
+	h.Interactions(replayed, signedSlackRequest(body, now.Add(-time.Hour), "secret"))
+
+	assert.Equal(t, http.StatusUnauthorized, forged.Code)
+	assert.Equal(t, http.StatusUnauthorized, replayed.Code)
+}
+
+func initSlack(t *testing.T, c config.SlackConfig) (*fakeSlack, *SlackNotifier) {
+	slack := &fakeSlack{users: map[string]string{"user@example.com": "U123"}}
+	srv := httptest.NewServer(slack)
+	t.Cleanup(srv.Close)
+	c.BotToken = "xoxb-test"
+	c.APIURL = srv.URL + "/api"
+	return slack, NewSlackNotifier(zap.NewNop(), c)
+}
+
+func signedSlackRequest(body string, at time.Time, secret string) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
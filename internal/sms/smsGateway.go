@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/tracing"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Gateway sends text messages to phone numbers.
+type Gateway interface {
+	Send(ctx context.Context, to string, body string) error
+}
+
+// gatewayError is a message the gateway refused, Code is the error code of the API when it gave one.
+type gatewayError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *gatewayError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("sms gateway: %d %s (code %d)", e.Status, e.Message, e.Code)
+	}
+	return fmt.Sprintf("sms gateway: %d %s", e.Status, e.Message)
+}
+
+// TwilioGateway speaks the Messages API of Twilio, which other providers and local stand-ins copy.
+type TwilioGateway struct {
+	c      config.SMSConfig
+	client *http.Client
+}
+
+func NewTwilioGateway(c config.SMSConfig) *TwilioGateway {
+	return &TwilioGateway{c: c, client: &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second}}
+}
+
+func (g *TwilioGateway) Send(ctx context.Context, to string, body string) error {
+	form := url.Values{"To": {to}, "Body": {body}}
+	// a messaging service picks the sender itself
+	if strings.HasPrefix(g.c.From, "MG") {
+		form.Set("MessagingServiceSid", g.c.From)
+	} else {
+		form.Set("From", g.c.From)
+	}
+	endpoint := strings.TrimSuffix(g.c.APIURL, "/") + "/2010-04-01/Accounts/" + url.PathEscape(g.c.AccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(g.c.AccountSID, g.c.AuthToken)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	var apiErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&apiErr)
+	if err != nil || apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return &gatewayError{Status: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Message}
+}
@@ -0,0 +1,83 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+type HandlerImpl struct {
+	ss SmsService
+}
+
+func NewHandler(ss SmsService) *HandlerImpl {
+	return &HandlerImpl{ss: ss}
+}
+
+// StartVerification texts a code to the phone number posted as {"phone": "+14155552671"}.
+func (h HandlerImpl) StartVerification(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Phone string `json:"phone"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	verification, err := h.ss.StartVerification(r.Context(), mux.Vars(r)["userId"], request.Phone)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	if verification == nil {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	utils.SendJsonWithStatus(w, http.StatusAccepted, verification)
+}
+
+// ConfirmPhone sets the phone number of the user from the code posted as {"code": "123456"}.
+func (h HandlerImpl) ConfirmPhone(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Code string `json:"code"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	user, err := h.ss.ConfirmPhone(r.Context(), mux.Vars(r)["userId"], request.Code)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	utils.SendJson(w, user)
+}
+
+func (h HandlerImpl) RemovePhone(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.ss.RemovePhone(r.Context(), mux.Vars(r)["userId"])
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if !removed {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "phone number not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPhone), errors.Is(err, ErrInvalidCode):
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrDailyCap):
+		utils.SendJsonWithStatus(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+	default:
+		utils.SendHttpError(w, err)
+	}
+}
@@ -0,0 +1,38 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/reminders"
+)
+
+const ChannelSMS = "sms"
+
+var errNoPhone = errors.New("sms: the user has no verified phone number")
+
+// Notifier texts reminders to the verified phone number of the user, within the daily cap.
+type Notifier struct {
+	l *zap.Logger
+	s *sender
+}
+
+func NewNotifier(l *zap.Logger, r SmsRepository, g Gateway, c config.SMSConfig) *Notifier {
+	return &Notifier{l: l, s: newSender(r, g, c.DailyCap)}
+}
+
+func (n *Notifier) Channel() string {
+	return ChannelSMS
+}
+
+func (n *Notifier) Notify(ctx context.Context, notification reminders.Notification) error {
+	if notification.User.Phone == nil {
+		return errNoPhone
+	}
+	body := notification.Text()
+	if notification.Event.JoinURL != "" && notification.Kind != reminders.KindCancelled {
+		body += "\nJoin: " + notification.Event.JoinURL
+	}
+	return n.s.send(ctx, notification.User.Id.String(), *notification.User.Phone, body)
+}
@@ -0,0 +1,119 @@
+package sms
+
+import (
+	"context"
+	"database/sql"
+	"go.uber.org/zap"
+	"manny-reminder/internal/tracing"
+	"time"
+)
+
+type SmsRepository interface {
+	AddVerification(ctx context.Context, userId string, phone string, codeHash string, expiresAt time.Time) error
+	UseVerification(ctx context.Context, userId string, now time.Time, maxAttempts int) (string, string, error)
+	ConfirmPhone(ctx context.Context, userId string, phone string) error
+	RemovePhone(ctx context.Context, userId string) (bool, error)
+	ReserveSend(ctx context.Context, userId string, day string, cap int) (bool, error)
+	ReleaseSend(ctx context.Context, userId string, day string) error
+}
+
+type RepositoryImpl struct {
+	l  *zap.Logger
+	db *sql.DB
+}
+
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
+	return &RepositoryImpl{l, db}
+}
+
+// AddVerification replaces the verification the user had going, with a fresh count of attempts.
+func (r RepositoryImpl) AddVerification(ctx context.Context, userId string, phone string, codeHash string, expiresAt time.Time) (err error) {
+	query := `INSERT INTO sms_verifications (user_id, phone, code_hash, expires_at) VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO UPDATE SET phone = $2, code_hash = $3, attempts = 0, expires_at = $4`
+	ctx, span := tracing.StartQuery(ctx, "SmsRepository.AddVerification", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, userId, phone, codeHash, expiresAt)
+	return err
+}
+
+// UseVerification counts an attempt at the code of the user and returns the phone number and the code hash, or
+// empty strings when there is no verification going, it expired or it had maxAttempts already.
+func (r RepositoryImpl) UseVerification(ctx context.Context, userId string, now time.Time, maxAttempts int) (_ string, _ string, err error) {
+	query := `UPDATE sms_verifications SET attempts = attempts + 1
+WHERE user_id = $1 AND expires_at > $2 AND attempts < $3
+RETURNING phone, code_hash`
+	ctx, span := tracing.StartQuery(ctx, "SmsRepository.UseVerification", query)
+	defer tracing.End(span, &err)
+
+	var phone, codeHash string
+	err = r.db.QueryRowContext(ctx, query, userId, now, maxAttempts).Scan(&phone, &codeHash)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	return phone, codeHash, err
+}
+
+// ConfirmPhone sets the verified phone number of the user and ends the verification.
+func (r RepositoryImpl) ConfirmPhone(ctx context.Context, userId string, phone string) (err error) {
+	query := "UPDATE users SET phone = $2 WHERE id = $1"
+	deleteVerification := "DELETE FROM sms_verifications WHERE user_id = $1"
+	ctx, span := tracing.StartQuery(ctx, "SmsRepository.ConfirmPhone", query+"; "+deleteVerification)
+	defer tracing.End(span, &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, userId, phone)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, deleteVerification, userId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemovePhone returns false when the user has no phone number.
+func (r RepositoryImpl) RemovePhone(ctx context.Context, userId string) (_ bool, err error) {
+	query := "UPDATE users SET phone = NULL WHERE id = $1 AND phone IS NOT NULL"
+	ctx, span := tracing.StartQuery(ctx, "SmsRepository.RemovePhone", query)
+	defer tracing.End(span, &err)
+
+	res, err := r.db.ExecContext(ctx, query, userId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReserveSend counts a message to the user on the day, false when the user had cap messages that day already.
+func (r RepositoryImpl) ReserveSend(ctx context.Context, userId string, day string, cap int) (_ bool, err error) {
+	query := `INSERT INTO sms_usage (user_id, day, sent) VALUES ($1, $2, 1)
+ON CONFLICT (user_id, day) DO UPDATE SET sent = sms_usage.sent + 1 WHERE sms_usage.sent < $3
+RETURNING sent`
+	ctx, span := tracing.StartQuery(ctx, "SmsRepository.ReserveSend", query)
+	defer tracing.End(span, &err)
+
+	var sent int
+	err = r.db.QueryRowContext(ctx, query, userId, day, cap).Scan(&sent)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseSend gives back a message the gateway didn't take.
+func (r RepositoryImpl) ReleaseSend(ctx context.Context, userId string, day string) (err error) {
+	query := "UPDATE sms_usage SET sent = sent - 1 WHERE user_id = $1 AND day = $2 AND sent > 0"
+	ctx, span := tracing.StartQuery(ctx, "SmsRepository.ReleaseSend", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, userId, day)
+	return err
+}
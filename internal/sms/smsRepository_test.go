@@ -0,0 +1,31 @@
+package sms
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+)
+
+func TestRepository_ReserveSend_CapReached(t *testing.T) {
+	r, db := initRepository(t)
+	db.ExpectQuery(`INSERT INTO sms_usage .* DO UPDATE SET sent = sms_usage.sent \+ 1 WHERE sms_usage.sent < \$3`).
+		WithArgs("user", "2022-06-01", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"sent"}))
+
+	reserved, err := r.ReserveSend(context.Background(), "user", "2022-06-01", 3)
+
+	assert.Nil(t, err)
+	assert.False(t, reserved)
+}
+
+func initRepository(t *testing.T) (*RepositoryImpl, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, mock.ExpectationsWereMet())
+		_ = db.Close()
+	})
+	return NewRepository(zap.NewNop(), db), mock
+}
@@ -0,0 +1,135 @@
+package sms
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/models"
+	"math/big"
+	"regexp"
+	"time"
+)
+
+// maxCodeAttempts is how many codes a user may try before asking for a new one.
+const maxCodeAttempts = 5
+
+var (
+	ErrInvalidPhone = errors.New("the phone number must be in E.164 format, e.g. +14155552671")
+	ErrInvalidCode  = errors.New("the code is invalid or expired")
+	ErrDailyCap     = errors.New("the daily SMS cap of the user is reached")
+)
+
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+type SmsService interface {
+	StartVerification(ctx context.Context, userId string, phone string) (*models.PhoneVerification, error)
+	ConfirmPhone(ctx context.Context, userId string, code string) (*models.User, error)
+	RemovePhone(ctx context.Context, userId string) (bool, error)
+}
+
+type ServiceImpl struct {
+	l  *zap.Logger
+	r  SmsRepository
+	as auth.AuthService
+	s  *sender
+	c  config.SMSConfig
+}
+
+func NewService(l *zap.Logger, r SmsRepository, as auth.AuthService, g Gateway, c config.SMSConfig) *ServiceImpl {
+	return &ServiceImpl{l: l, r: r, as: as, s: newSender(r, g, c.DailyCap), c: c}
+}
+
+// StartVerification texts a one-time code to the phone number, nil when the user doesn't exist. The number is the
+// user's once the code is confirmed.
+func (s *ServiceImpl) StartVerification(ctx context.Context, userId string, phone string) (*models.PhoneVerification, error) {
+	if !e164.MatchString(phone) {
+		return nil, ErrInvalidPhone
+	}
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return nil, err
+	}
+	code := fmt.Sprintf("%06d", n)
+	expiresAt := s.s.now().Add(s.c.CodeTTL)
+	err = s.r.AddVerification(ctx, userId, phone, hashCode(code), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	err = s.s.send(ctx, userId, phone, fmt.Sprintf("Your manny-reminder code is %s", code))
+	if err != nil {
+		return nil, err
+	}
+	return &models.PhoneVerification{Phone: phone, ExpiresAt: expiresAt}, nil
+}
+
+// ConfirmPhone checks the code the user got and sets their phone number.
+func (s *ServiceImpl) ConfirmPhone(ctx context.Context, userId string, code string) (*models.User, error) {
+	phone, codeHash, err := s.r.UseVerification(ctx, userId, s.s.now(), maxCodeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if phone == "" || subtle.ConstantTimeCompare([]byte(codeHash), []byte(hashCode(code))) != 1 {
+		return nil, ErrInvalidCode
+	}
+	err = s.r.ConfirmPhone(ctx, userId, phone)
+	if err != nil {
+		return nil, err
+	}
+	return s.as.GetUser(ctx, userId)
+}
+
+// RemovePhone stops the SMS reminders of the user, false when the user has no phone number.
+func (s *ServiceImpl) RemovePhone(ctx context.Context, userId string) (bool, error) {
+	return s.r.RemovePhone(ctx, userId)
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// sender counts every message against the daily cap of the user, a message the gateway refuses isn't counted.
+type sender struct {
+	r   SmsRepository
+	g   Gateway
+	cap int
+	now func() time.Time
+}
+
+func newSender(r SmsRepository, g Gateway, cap int) *sender {
+	return &sender{r: r, g: g, cap: cap, now: time.Now}
+}
+
+func (s *sender) send(ctx context.Context, userId string, to string, body string) error {
+	day := s.now().UTC().Format("2006-01-02")
+	reserved, err := s.r.ReserveSend(ctx, userId, day, s.cap)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return ErrDailyCap
+	}
+	err = s.g.Send(ctx, to, body)
+	if err != nil {
+		releaseErr := s.r.ReleaseSend(ctx, userId, day)
+		if releaseErr != nil {
+			return fmt.Errorf("%w, and unable to release it from the cap: %s", err, releaseErr)
+		}
+	}
+	return err
+}
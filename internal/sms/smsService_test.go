@@ -0,0 +1,191 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/reminders"
+	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+
+// fakeTwilio stands in for the Messages API, recording the messages it takes.
+type fakeTwilio struct {
+	mu       sync.Mutex
+	messages []url.Values
+	fail     bool
+}
+
+func (f *fakeTwilio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sid, token, ok := r.BasicAuth()
+	if !ok || sid != "AC123" || token != "secret" || r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"code": 20003, "message": "Authenticate", "status": 401}`)
+		return
+	}
+	if f.fail {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"code": 21211, "message": "The 'To' number is not a valid phone number.", "status": 400}`)
+		return
+	}
+	_ = r.ParseForm()
+	f.messages = append(f.messages, r.PostForm)
+	w.WriteHeader(http.StatusCreated)
+	_, _ = fmt.Fprint(w, `{"sid": "SM123", "status": "queued"}`)
+}
+
+func TestService_VerifiesPhoneWithTextedCode(t *testing.T) {
+	twilio, r, as, s := initService(t)
+	user := generateUser()
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	var codeHash string
+	r.On("AddVerification", mock.Anything, user.Id.String(), "+14155552671", mock.Anything, now.Add(10*time.Minute)).
+		Run(func(args mock.Arguments) { codeHash = args.String(3) }).Return(nil)
+	r.On("ReserveSend", mock.Anything, user.Id.String(), "2022-06-01", 3).Return(true, nil)
+
+	verification, err := s.StartVerification(context.Background(), user.Id.String(), "+14155552671")
+
+	assert.Nil(t, err)
+	assert.Equal(t, &models.PhoneVerification{Phone: "+14155552671", ExpiresAt: now.Add(10 * time.Minute)}, verification)
+	assert.Len(t, twilio.messages, 1)
+	assert.Equal(t, "+14155552671", twilio.messages[0].Get("To"))
+	assert.Equal(t, "+15005550006", twilio.messages[0].Get("From"))
+	code := regexp.MustCompile(`[0-9]{6}`).FindString(twilio.messages[0].Get("Body"))
+	assert.Equal(t, hashCode(code), codeHash)
+
+	r.On("UseVerification", mock.Anything, user.Id.String(), now, maxCodeAttempts).Return("+14155552671", codeHash, nil)
+	r.On("ConfirmPhone", mock.Anything, user.Id.String(), "+14155552671").Return(nil)
+
+	_, err = s.ConfirmPhone(context.Background(), user.Id.String(), "000000x")
+	assert.Equal(t, ErrInvalidCode, err)
+	_, err = s.ConfirmPhone(context.Background(), user.Id.String(), code)
+	assert.Nil(t, err)
+}
+
+func TestService_StartVerification_InvalidPhone(t *testing.T) {
+	_, _, _, s := initService(t)
+
+	_, err := s.StartVerification(context.Background(), "user", "555-2671")
+
+	assert.Equal(t, ErrInvalidPhone, err)
+}
+
+func TestService_ConfirmPhone_ExpiredCode(t *testing.T) {
+	_, r, _, s := initService(t)
+	r.On("UseVerification", mock.Anything, "user", now, maxCodeAttempts).Return("", "", nil)
+
+	_, err := s.ConfirmPhone(context.Background(), "user", "123456")
+
+	assert.Equal(t, ErrInvalidCode, err)
+}
+
+func TestNotifier_TextsVerifiedPhone(t *testing.T) {
+	twilio, r, n := initNotifier(t)
+	user := generateUser()
+	phone := "+14155552671"
+	user.Phone = &phone
+	r.On("ReserveSend", mock.Anything, user.Id.String(), "2022-06-01", 3).Return(true, nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{
+		Kind: reminders.KindReminder, User: user,
+		Event: models.Event{Title: "Standup", Start: "2022-06-01T13:10:00Z", JoinURL: "https://meet.google.com/abc-defg-hij"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "\"Standup\" starts at 1:10PM\nJoin: https://meet.google.com/abc-defg-hij", twilio.messages[0].Get("Body"))
+}
+
+func TestNotifier_DailyCapReached(t *testing.T) {
+	twilio, r, n := initNotifier(t)
+	user := generateUser()
+	phone := "+14155552671"
+	user.Phone = &phone
+	r.On("ReserveSend", mock.Anything, user.Id.String(), "2022-06-01", 3).Return(false, nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: user})
+
+	assert.Equal(t, ErrDailyCap, err)
+	assert.Empty(t, twilio.messages)
+}
+
+func TestNotifier_RefusedMessageIsReleased(t *testing.T) {
+	twilio, r, n := initNotifier(t)
+	twilio.fail = true
+	user := generateUser()
+	phone := "+14155552671"
+	user.Phone = &phone
+	r.On("ReserveSend", mock.Anything, user.Id.String(), "2022-06-01", 3).Return(true, nil)
+	r.On("ReleaseSend", mock.Anything, user.Id.String(), "2022-06-01").Return(nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: user})
+
+	assert.EqualError(t, err, "sms gateway: 400 The 'To' number is not a valid phone number. (code 21211)")
+}
+
+func TestNotifier_NoPhone(t *testing.T) {
+	_, _, n := initNotifier(t)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: generateUser()})
+
+	assert.Equal(t, errNoPhone, err)
+}
+
+func TestHandler_StartVerification_DailyCap(t *testing.T) {
+	ss := mocks.NewSmsService(t)
+	ss.On("StartVerification", mock.Anything, "user", "+14155552671").Return(nil, ErrDailyCap)
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{userId}/phone", NewHandler(ss).StartVerification)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/user/phone", strings.NewReader(`{"phone": "+14155552671"}`)))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func initConfig(t *testing.T) (*fakeTwilio, config.SMSConfig) {
+	twilio := &fakeTwilio{}
+	srv := httptest.NewServer(twilio)
+	t.Cleanup(srv.Close)
+	return twilio, config.SMSConfig{
+		APIURL: srv.URL, AccountSID: "AC123", AuthToken: "secret", From: "+15005550006", DailyCap: 3, CodeTTL: 10 * time.Minute,
+	}
+}
+
+func initService(t *testing.T) (*fakeTwilio, *mocks.SmsRepository, *mocks.AuthService, *ServiceImpl) {
+	twilio, c := initConfig(t)
+	r := mocks.NewSmsRepository(t)
+	as := mocks.NewAuthService(t)
+	s := NewService(zap.NewNop(), r, as, NewTwilioGateway(c), c)
+	s.s.now = func() time.Time { return now }
+	return twilio, r, as, s
+}
+
+func initNotifier(t *testing.T) (*fakeTwilio, *mocks.SmsRepository, *Notifier) {
+	twilio, c := initConfig(t)
+	r := mocks.NewSmsRepository(t)
+	n := NewNotifier(zap.NewNop(), r, NewTwilioGateway(c), c)
+	n.s.now = func() time.Time { return now }
+	return twilio, r, n
+}
+
+func generateUser() *models.User {
+	id := uuid.New()
+	return &models.User{Id: &id}
+}
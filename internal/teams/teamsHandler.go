@@ -0,0 +1,140 @@
+package teams
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+type HandlerImpl struct {
+	ts TeamsService
+}
+
+func NewHandler(ts TeamsService) *HandlerImpl {
+	return &HandlerImpl{ts: ts}
+}
+
+func (h HandlerImpl) GetTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := h.ts.GetTeams(r.Context())
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, teams)
+}
+
+func (h HandlerImpl) GetTeam(w http.ResponseWriter, r *http.Request) {
+	team, err := h.ts.GetTeam(r.Context(), mux.Vars(r)["teamId"])
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if team == nil {
+		sendNotFound(w, "team not found")
+		return
+	}
+	utils.SendJson(w, team)
+}
+
+// CreateTeam creates the team posted as {"name": "Sales", "rules": {"offsetMinutes": [15], "channels": ["slack"]}},
+// rules left out follow the configured ones.
+func (h HandlerImpl) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name  string               `json:"name"`
+		Rules models.ReminderRules `json:"rules"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	team, err := h.ts.CreateTeam(r.Context(), models.Team{Name: request.Name, Rules: request.Rules})
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	utils.SendJsonWithStatus(w, http.StatusCreated, team)
+}
+
+// UpdateTeam replaces the name and rules of the team, with the body of CreateTeam.
+func (h HandlerImpl) UpdateTeam(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name  string               `json:"name"`
+		Rules models.ReminderRules `json:"rules"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	team, err := h.ts.UpdateTeam(r.Context(), mux.Vars(r)["teamId"], models.Team{Name: request.Name, Rules: request.Rules})
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	if team == nil {
+		sendNotFound(w, "team not found")
+		return
+	}
+	utils.SendJson(w, team)
+}
+
+func (h HandlerImpl) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.ts.DeleteTeam(r.Context(), mux.Vars(r)["teamId"])
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if !deleted {
+		sendNotFound(w, "team not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddMember moves the user to the team and returns the user.
+func (h HandlerImpl) AddMember(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	user, err := h.ts.AddMember(r.Context(), params["teamId"], params["userId"])
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	if user == nil {
+		sendNotFound(w, "user not found")
+		return
+	}
+	utils.SendJson(w, user)
+}
+
+func (h HandlerImpl) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	removed, err := h.ts.RemoveMember(r.Context(), params["teamId"], params["userId"])
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if !removed {
+		sendNotFound(w, "member not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sendNotFound(w http.ResponseWriter, message string) {
+	utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": message})
+}
+
+func sendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTeam):
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrTeamNotFound):
+		sendNotFound(w, err.Error())
+	default:
+		utils.SendHttpError(w, err)
+	}
+}
@@ -0,0 +1,153 @@
+package teams
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+)
+
+type TeamsRepository interface {
+	GetTeams(ctx context.Context) (models.Teams, error)
+	GetTeam(ctx context.Context, id string) (*models.Team, error)
+	AddTeam(ctx context.Context, team models.Team) error
+	UpdateTeam(ctx context.Context, team models.Team) (bool, error)
+	DeleteTeam(ctx context.Context, id string) (bool, error)
+	AddMember(ctx context.Context, teamId string, userId string) (bool, error)
+	RemoveMember(ctx context.Context, teamId string, userId string) (bool, error)
+}
+
+type RepositoryImpl struct {
+	l  *zap.Logger
+	db *sql.DB
+}
+
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
+	return &RepositoryImpl{l, db}
+}
+
+const teamColumns = "id, name, reminder_offsets, reminder_channels"
+
+func (r RepositoryImpl) GetTeams(ctx context.Context) (_ models.Teams, err error) {
+	query := "SELECT " + teamColumns + " FROM teams ORDER BY name, id"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.GetTeams", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := rows.Close()
+		if err != nil {
+			r.l.Error("Unable to close teams rows", zap.Error(err))
+		}
+	}()
+	var teams models.Teams
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+// GetTeam returns the team, nil when it doesn't exist.
+func (r RepositoryImpl) GetTeam(ctx context.Context, id string) (_ *models.Team, err error) {
+	query := "SELECT " + teamColumns + " FROM teams WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.GetTeam", query)
+	defer tracing.End(span, &err)
+
+	team, err := scanTeam(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (r RepositoryImpl) AddTeam(ctx context.Context, team models.Team) (err error) {
+	query := "INSERT INTO teams (" + teamColumns + ") VALUES ($1, $2, $3, $4)"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.AddTeam", query)
+	defer tracing.End(span, &err)
+
+	offsets, channels := auth.RulesArrays(team.Rules)
+	_, err = r.db.ExecContext(ctx, query, team.Id, team.Name, offsets, channels)
+	return err
+}
+
+// UpdateTeam renames the team and sets its rules, returning false when it doesn't exist.
+func (r RepositoryImpl) UpdateTeam(ctx context.Context, team models.Team) (_ bool, err error) {
+	query := "UPDATE teams SET name = $2, reminder_offsets = $3, reminder_channels = $4 WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.UpdateTeam", query)
+	defer tracing.End(span, &err)
+
+	offsets, channels := auth.RulesArrays(team.Rules)
+	return r.execOne(ctx, query, team.Id, team.Name, offsets, channels)
+}
+
+// DeleteTeam deletes the team, its members keep their own rules. It returns false when the team doesn't exist.
+func (r RepositoryImpl) DeleteTeam(ctx context.Context, id string) (_ bool, err error) {
+	query := "DELETE FROM teams WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.DeleteTeam", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, id)
+}
+
+// AddMember moves the user to the team, a user is in one team at most. It returns false when the user doesn't exist.
+func (r RepositoryImpl) AddMember(ctx context.Context, teamId string, userId string) (_ bool, err error) {
+	query := "UPDATE users SET team_id = $2 WHERE id = $1"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.AddMember", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, userId, teamId)
+}
+
+// RemoveMember takes the user out of the team, returning false when they weren't in it.
+func (r RepositoryImpl) RemoveMember(ctx context.Context, teamId string, userId string) (_ bool, err error) {
+	query := "UPDATE users SET team_id = NULL WHERE id = $1 AND team_id = $2"
+	ctx, span := tracing.StartQuery(ctx, "TeamsRepository.RemoveMember", query)
+	defer tracing.End(span, &err)
+
+	return r.execOne(ctx, query, userId, teamId)
+}
+
+// execOne runs a statement meant to change a single row, telling whether it did.
+func (r RepositoryImpl) execOne(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTeam(row scanner) (models.Team, error) {
+	var team models.Team
+	var id uuid.UUID
+	var offsets pq.Int64Array
+	var channels pq.StringArray
+	err := row.Scan(&id, &team.Name, &offsets, &channels)
+	if err != nil {
+		return models.Team{}, err
+	}
+	team.Id = &id
+	team.Rules = auth.RulesOf(offsets, channels)
+	return team, nil
+}
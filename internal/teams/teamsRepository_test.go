@@ -0,0 +1,46 @@
+package teams
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"testing"
+)
+
+func TestRepository_GetTeam_InheritedRulesAreNil(t *testing.T) {
+	r, db := initRepository(t)
+	id := uuid.New()
+	db.ExpectQuery(`SELECT id, name, reminder_offsets, reminder_channels FROM teams WHERE id = \$1`).
+		WithArgs(id.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "reminder_offsets", "reminder_channels"}).
+			AddRow(id.String(), "Sales", "{15,5}", nil))
+
+	team, err := r.GetTeam(context.Background(), id.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, &models.Team{Id: &id, Name: "Sales", Rules: models.ReminderRules{OffsetMinutes: []int{15, 5}}}, team)
+}
+
+func TestRepository_GetTeam_NotFound(t *testing.T) {
+	r, db := initRepository(t)
+	db.ExpectQuery(`FROM teams WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "reminder_offsets", "reminder_channels"}))
+
+	team, err := r.GetTeam(context.Background(), uuid.New().String())
+
+	assert.Nil(t, err)
+	assert.Nil(t, team)
+}
+
+func initRepository(t *testing.T) (*RepositoryImpl, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		assert.Nil(t, mock.ExpectationsWereMet())
+		_ = db.Close()
+	})
+	return NewRepository(zap.NewNop(), db), mock
+}
@@ -0,0 +1,123 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/models"
+	"strings"
+)
+
+var (
+	ErrInvalidTeam  = errors.New("invalid team")
+	ErrTeamNotFound = errors.New("team not found")
+)
+
+type TeamsService interface {
+	GetTeams(ctx context.Context) (models.Teams, error)
+	GetTeam(ctx context.Context, id string) (*models.Team, error)
+	CreateTeam(ctx context.Context, team models.Team) (*models.Team, error)
+	UpdateTeam(ctx context.Context, id string, team models.Team) (*models.Team, error)
+	DeleteTeam(ctx context.Context, id string) (bool, error)
+	AddMember(ctx context.Context, teamId string, userId string) (*models.User, error)
+	RemoveMember(ctx context.Context, teamId string, userId string) (bool, error)
+}
+
+// ServiceImpl manages teams and their members. Members inherit the reminder rules of their team, see
+// models.ReminderRules.
+type ServiceImpl struct {
+	l  *zap.Logger
+	r  TeamsRepository
+	as auth.AuthService
+}
+
+func NewService(l *zap.Logger, r TeamsRepository, as auth.AuthService) *ServiceImpl {
+	return &ServiceImpl{l: l, r: r, as: as}
+}
+
+func (s ServiceImpl) GetTeams(ctx context.Context) (models.Teams, error) {
+	return s.r.GetTeams(ctx)
+}
+
+// GetTeam returns the team, nil when it doesn't exist.
+func (s ServiceImpl) GetTeam(ctx context.Context, id string) (*models.Team, error) {
+	return s.r.GetTeam(ctx, id)
+}
+
+func (s ServiceImpl) CreateTeam(ctx context.Context, team models.Team) (*models.Team, error) {
+	err := validate(&team)
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.New()
+	team.Id = &id
+	err = s.r.AddTeam(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// UpdateTeam renames the team and replaces its rules, returning nil when it doesn't exist.
+func (s ServiceImpl) UpdateTeam(ctx context.Context, id string, team models.Team) (*models.Team, error) {
+	err := validate(&team)
+	if err != nil {
+		return nil, err
+	}
+	teamId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+	team.Id = &teamId
+	ok, err := s.r.UpdateTeam(ctx, team)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (s ServiceImpl) DeleteTeam(ctx context.Context, id string) (bool, error) {
+	return s.r.DeleteTeam(ctx, id)
+}
+
+// AddMember moves the user to the team, out of the team they were in, and returns the user, nil when they don't
+// exist.
+func (s ServiceImpl) AddMember(ctx context.Context, teamId string, userId string) (*models.User, error) {
+	team, err := s.r.GetTeam(ctx, teamId)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrTeamNotFound
+	}
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	ok, err := s.r.AddMember(ctx, teamId, userId)
+	if err != nil || !ok {
+		return nil, err
+	}
+	user.Team = team
+	return user, nil
+}
+
+// RemoveMember takes the user out of the team, returning false when they weren't in it.
+func (s ServiceImpl) RemoveMember(ctx context.Context, teamId string, userId string) (bool, error) {
+	return s.r.RemoveMember(ctx, teamId, userId)
+}
+
+func validate(team *models.Team) error {
+	team.Name = strings.TrimSpace(team.Name)
+	if team.Name == "" {
+		return fmt.Errorf("%w: the name is required", ErrInvalidTeam)
+	}
+	err := team.Rules.Validate()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTeam, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package teams
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"testing"
+)
+
+func TestService_CreateTeam(t *testing.T) {
+	r, _, s := initService(t)
+	r.On("AddTeam", mock.Anything, mock.MatchedBy(func(team models.Team) bool {
+		return team.Id != nil && team.Name == "Sales"
+	})).Return(nil)
+
+	team, err := s.CreateTeam(context.Background(), models.Team{Name: " Sales ", Rules: models.ReminderRules{Channels: []string{"slack"}}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Sales", team.Name)
+	assert.Nil(t, team.Rules.OffsetMinutes)
+}
+
+func TestService_CreateTeam_Invalid(t *testing.T) {
+	_, _, s := initService(t)
+
+	for _, team := range []models.Team{{Name: " "}, {Name: "Sales", Rules: models.ReminderRules{OffsetMinutes: []int{-5}}}} {
+		_, err := s.CreateTeam(context.Background(), team)
+
+		assert.ErrorIs(t, err, ErrInvalidTeam)
+	}
+}
+
+func TestService_AddMember(t *testing.T) {
+	r, as, s := initService(t)
+	teamId, userId := uuid.New(), uuid.New()
+	team := &models.Team{Id: &teamId, Name: "Sales"}
+	r.On("GetTeam", mock.Anything, teamId.String()).Return(team, nil)
+	as.On("GetUser", mock.Anything, userId.String()).Return(&models.User{Id: &userId}, nil)
+	r.On("AddMember", mock.Anything, teamId.String(), userId.String()).Return(true, nil)
+
+	user, err := s.AddMember(context.Background(), teamId.String(), userId.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, team, user.Team)
+}
+
+func TestService_AddMember_UnknownTeam(t *testing.T) {
+	r, _, s := initService(t)
+	r.On("GetTeam", mock.Anything, "team").Return(nil, nil)
+
+	user, err := s.AddMember(context.Background(), "team", "user")
+
+	assert.ErrorIs(t, err, ErrTeamNotFound)
+	assert.Nil(t, user)
+}
+
+func initService(t *testing.T) (*mocks.TeamsRepository, *mocks.AuthService, *ServiceImpl) {
+	r := mocks.NewTeamsRepository(t)
+	as := mocks.NewAuthService(t)
+	return r, as, NewService(zap.NewNop(), r, as)
+}
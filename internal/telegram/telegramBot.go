@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"manny-reminder/internal/tracing"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// botError is a Bot API call answered with ok false. RetryAfter is set when the bot is throttled.
+type botError struct {
+	Method      string
+	Code        int
+	Description string
+	RetryAfter  int
+}
+
+func (e *botError) Error() string {
+	return fmt.Sprintf("telegram %s: %d %s", e.Method, e.Code, e.Description)
+}
+
+// Bot API types, limited to the fields the bot uses.
+type update struct {
+	UpdateId      int64          `json:"update_id"`
+	Message       *message       `json:"message"`
+	CallbackQuery *callbackQuery `json:"callback_query"`
+}
+
+type message struct {
+	MessageId   int64                 `json:"message_id"`
+	Chat        chat                  `json:"chat"`
+	Text        string                `json:"text"`
+	ReplyMarkup *inlineKeyboardMarkup `json:"reply_markup"`
+}
+
+type chat struct {
+	Id int64 `json:"id"`
+}
+
+type callbackQuery struct {
+	Id      string   `json:"id"`
+	Message *message `json:"message"`
+	Data    string   `json:"data"`
+}
+
+type inlineKeyboardMarkup struct {
+	InlineKeyboard [][]inlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type inlineKeyboardButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// botClient calls the methods of the Bot API with the bot token.
+type botClient struct {
+	url    string
+	client *http.Client
+}
+
+// newBotClient gives calls pollTimeout on top of the usual time, getUpdates holds the request that long.
+func newBotClient(apiURL string, token string, pollTimeout time.Duration) botClient {
+	return botClient{
+		url:    strings.TrimSuffix(apiURL, "/") + "/bot" + token + "/",
+		client: &http.Client{Transport: tracing.Transport(nil), Timeout: pollTimeout + 10*time.Second},
+	}
+}
+
+func (c botClient) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// the URL holds the token, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram %s: %w", method, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Ok          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&response)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %s", method, resp.Status)
+	}
+	if !response.Ok {
+		return &botError{Method: method, Code: response.ErrorCode, Description: response.Description, RetryAfter: response.Parameters.RetryAfter}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func (c botClient) sendMessage(ctx context.Context, chatId int64, text string, markup *inlineKeyboardMarkup) error {
+	params := map[string]interface{}{"chat_id": chatId, "text": text}
+	if markup != nil {
+		params["reply_markup"] = markup
+	}
+	return c.call(ctx, "sendMessage", params, nil)
+}
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"github.com/gorilla/mux"
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+type HandlerImpl struct {
+	ts TelegramService
+}
+
+func NewHandler(ts TelegramService) *HandlerImpl {
+	return &HandlerImpl{ts: ts}
+}
+
+// CreateLinkCode returns the link a user opens to get their reminders from the bot.
+func (h HandlerImpl) CreateLinkCode(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	if userId == "" {
+		utils.SendHttpStringError(w, "User id not defined")
+		return
+	}
+
+	link, err := h.ts.CreateLinkCode(r.Context(), userId)
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if link == nil {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	utils.SendJsonWithStatus(w, http.StatusCreated, link)
+}
@@ -0,0 +1,67 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/reminders"
+	"net/http"
+)
+
+const ChannelTelegram = "telegram"
+
+var errNotLinked = errors.New("telegram: the user hasn't linked a chat")
+
+// Notifier sends reminders to the chat the user linked, with a button per action.
+type Notifier struct {
+	l   *zap.Logger
+	r   TelegramRepository
+	bot botClient
+}
+
+func NewNotifier(l *zap.Logger, r TelegramRepository, c config.TelegramConfig) *Notifier {
+	return &Notifier{l: l, r: r, bot: newBotClient(c.APIURL, c.BotToken, 0)}
+}
+
+func (n *Notifier) Channel() string {
+	return ChannelTelegram
+}
+
+func (n *Notifier) Notify(ctx context.Context, notification reminders.Notification) error {
+	chatId, err := n.r.GetChat(ctx, notification.User.Id.String())
+	if err != nil {
+		return err
+	}
+	if chatId == nil {
+		return errNotLinked
+	}
+
+	var markup *inlineKeyboardMarkup
+	var actions []inlineKeyboardButton
+	for _, action := range notification.Actions {
+		actions = append(actions, inlineKeyboardButton{Text: action.Label, CallbackData: action.Token})
+	}
+	if len(actions) > 0 {
+		markup = &inlineKeyboardMarkup{InlineKeyboard: [][]inlineKeyboardButton{actions}}
+	}
+	if notification.Event.JoinURL != "" && notification.Kind != reminders.KindCancelled {
+		if markup == nil {
+			markup = &inlineKeyboardMarkup{}
+		}
+		join := []inlineKeyboardButton{{Text: "Join", URL: notification.Event.JoinURL}}
+		markup.InlineKeyboard = append(markup.InlineKeyboard, join)
+	}
+
+	err = n.bot.sendMessage(ctx, *chatId, notification.Text(), markup)
+	var be *botError
+	if errors.As(err, &be) && be.Code == http.StatusForbidden {
+		// the user blocked the bot or left the chat, it won't take messages anymore
+		n.l.Info("Unlinking the Telegram chat of the user", zap.Stringer("userId", notification.User.Id), zap.String("reason", be.Description))
+		unlinkErr := n.r.UnlinkChat(ctx, *chatId)
+		if unlinkErr != nil {
+			n.l.Error("Unable to unlink the Telegram chat", zap.Error(unlinkErr))
+		}
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package telegram
+
+import (
+	"context"
+	"database/sql"
+	"go.uber.org/zap"
+	"manny-reminder/internal/tracing"
+	"time"
+)
+
+type TelegramRepository interface {
+	AddLinkCode(ctx context.Context, code string, userId string, expiresAt time.Time) error
+	UseLinkCode(ctx context.Context, code string, now time.Time) (string, error)
+	LinkChat(ctx context.Context, userId string, chatId int64) error
+	UnlinkChat(ctx context.Context, chatId int64) error
+	GetChat(ctx context.Context, userId string) (*int64, error)
+}
+
+type RepositoryImpl struct {
+	l  *zap.Logger
+	db *sql.DB
+}
+
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
+	return &RepositoryImpl{l, db}
+}
+
+func (r RepositoryImpl) AddLinkCode(ctx context.Context, code string, userId string, expiresAt time.Time) (err error) {
+	query := "INSERT INTO telegram_link_codes (code, user_id, expires_at) VALUES ($1, $2, $3)"
+	ctx, span := tracing.StartQuery(ctx, "TelegramRepository.AddLinkCode", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, code, userId, expiresAt)
+	return err
+}
+
+// UseLinkCode deletes the code and returns the user it links to, or an empty id when it's unknown or expired.
+func (r RepositoryImpl) UseLinkCode(ctx context.Context, code string, now time.Time) (_ string, err error) {
+	query := "DELETE FROM telegram_link_codes WHERE code = $1 AND expires_at > $2 RETURNING user_id"
+	ctx, span := tracing.StartQuery(ctx, "TelegramRepository.UseLinkCode", query)
+	defer tracing.End(span, &err)
+
+	var userId string
+	err = r.db.QueryRowContext(ctx, query, code, now).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return userId, err
+}
+
+// LinkChat sends the reminders of the user to the chat, replacing the chat linked before.
+func (r RepositoryImpl) LinkChat(ctx context.Context, userId string, chatId int64) (err error) {
+	query := `INSERT INTO telegram_chats (user_id, chat_id) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET chat_id = $2, linked_at = now()`
+	ctx, span := tracing.StartQuery(ctx, "TelegramRepository.LinkChat", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, userId, chatId)
+	return err
+}
+
+// UnlinkChat stops sending reminders to the chat, whichever users it was linked to.
+func (r RepositoryImpl) UnlinkChat(ctx context.Context, chatId int64) (err error) {
+	query := "DELETE FROM telegram_chats WHERE chat_id = $1"
+	ctx, span := tracing.StartQuery(ctx, "TelegramRepository.UnlinkChat", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, chatId)
+	return err
+}
+
+// GetChat returns the chat linked to the user, nil when there is none.
+func (r RepositoryImpl) GetChat(ctx context.Context, userId string) (_ *int64, err error) {
+	query := "SELECT chat_id FROM telegram_chats WHERE user_id = $1"
+	ctx, span := tracing.StartQuery(ctx, "TelegramRepository.GetChat", query)
+	defer tracing.End(span, &err)
+
+	var chatId int64
+	err = r.db.QueryRowContext(ctx, query, userId).Scan(&chatId)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &chatId, nil
+}
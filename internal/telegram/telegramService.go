@@ -0,0 +1,208 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/health"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/reminders"
+	"strings"
+	"time"
+)
+
+// pollBackoff is the wait after a failed poll, unless the Bot API asks for another.
+const pollBackoff = 5 * time.Second
+
+type TelegramService interface {
+	CreateLinkCode(ctx context.Context, userId string) (*models.TelegramLink, error)
+	Run(ctx context.Context)
+}
+
+// ServiceImpl runs the bot: it links chats to users with the codes they send, and handles the buttons of reminders.
+type ServiceImpl struct {
+	l    *zap.Logger
+	r    TelegramRepository
+	as   auth.AuthService
+	rs   reminders.RemindersService
+	c    config.TelegramConfig
+	bot  botClient
+	beat *health.Heartbeat
+	now  func() time.Time
+}
+
+func NewService(l *zap.Logger, r TelegramRepository, as auth.AuthService, rs reminders.RemindersService, c config.TelegramConfig) *ServiceImpl {
+	return &ServiceImpl{
+		l: l, r: r, as: as, rs: rs, c: c,
+		bot: newBotClient(c.APIURL, c.BotToken, c.PollTimeout),
+		// a poll returns at the latest after the poll timeout
+		beat: health.NewHeartbeat(3 * (c.PollTimeout + pollBackoff)),
+		now:  time.Now,
+	}
+}
+
+// Heartbeat is beaten after every successful poll.
+func (s *ServiceImpl) Heartbeat() *health.Heartbeat {
+	return s.beat
+}
+
+// CreateLinkCode returns a code for the user to send to the bot, nil when the user doesn't exist. The link opens
+// the chat with the bot and sends the code on start.
+func (s *ServiceImpl) CreateLinkCode(ctx context.Context, userId string) (*models.TelegramLink, error) {
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	b := make([]byte, 16)
+	_, err = rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := s.now().Add(s.c.LinkCodeTTL)
+	err = s.r.AddLinkCode(ctx, code, userId, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TelegramLink{Code: code, URL: "https://t.me/" + s.c.BotName + "?start=" + code, ExpiresAt: expiresAt}, nil
+}
+
+// Run long polls the updates of the bot until the context is done.
+func (s *ServiceImpl) Run(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		var updates []update
+		err := s.bot.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(s.c.PollTimeout / time.Second),
+			"allowed_updates": []string{"message", "callback_query"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.l.Error("Unable to get the Telegram updates", zap.Error(err))
+			s.wait(ctx, backoff(err))
+			continue
+		}
+		s.beat.Beat()
+
+		for _, u := range updates {
+			// the next poll confirms the update, it isn't handled twice even when it fails
+			offset = u.UpdateId + 1
+			err = s.handleUpdate(ctx, u)
+			if err != nil {
+				s.l.Error("Unable to handle the Telegram update", zap.Int64("updateId", u.UpdateId), zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *ServiceImpl) handleUpdate(ctx context.Context, u update) error {
+	switch {
+	case u.Message != nil:
+		return s.handleMessage(ctx, u.Message)
+	case u.CallbackQuery != nil:
+		return s.handleCallback(ctx, u.CallbackQuery)
+	}
+	return nil
+}
+
+// handleMessage answers the commands of the bot: /start with a link code links the chat, /stop unlinks it.
+func (s *ServiceImpl) handleMessage(ctx context.Context, m *message) error {
+	fields := strings.Fields(m.Text)
+	if len(fields) == 0 {
+		return nil
+	}
+	// in groups commands may be addressed to the bot by name
+	command := strings.SplitN(fields[0], "@", 2)[0]
+
+	switch command {
+	case "/start":
+		if len(fields) < 2 {
+			return s.bot.sendMessage(ctx, m.Chat.Id, "Hi! Open the Telegram link from manny-reminder to get your reminders here.", nil)
+		}
+		userId, err := s.r.UseLinkCode(ctx, fields[1], s.now())
+		if err != nil {
+			return err
+		}
+		if userId == "" {
+			return s.bot.sendMessage(ctx, m.Chat.Id, "This link is invalid or expired, please ask for a new one.", nil)
+		}
+		err = s.r.LinkChat(ctx, userId, m.Chat.Id)
+		if err != nil {
+			return err
+		}
+		return s.bot.sendMessage(ctx, m.Chat.Id, "You're all set, your reminders will arrive here. Send /stop to stop them.", nil)
+	case "/stop":
+		err := s.r.UnlinkChat(ctx, m.Chat.Id)
+		if err != nil {
+			return err
+		}
+		return s.bot.sendMessage(ctx, m.Chat.Id, "You won't get reminders here anymore.", nil)
+	}
+	return nil
+}
+
+// handleCallback performs the action of the pressed reminder button, then removes the action buttons, keeping links.
+func (s *ServiceImpl) handleCallback(ctx context.Context, q *callbackQuery) error {
+	result, err := s.rs.PerformAction(ctx, q.Data)
+	text := result.Message
+	if err != nil {
+		if !errors.Is(err, reminders.ErrInvalidAction) && !errors.Is(err, reminders.ErrActionExpired) &&
+			!errors.Is(err, reminders.ErrActionUsed) {
+			s.l.Error("Unable to perform the reminder action", zap.Error(err))
+			text = "Unable to handle the action, please try again"
+		} else {
+			text = err.Error()
+		}
+	}
+	answerErr := s.bot.call(ctx, "answerCallbackQuery", map[string]interface{}{"callback_query_id": q.Id, "text": text}, nil)
+	if answerErr != nil || err != nil || q.Message == nil {
+		return answerErr
+	}
+
+	markup := inlineKeyboardMarkup{InlineKeyboard: [][]inlineKeyboardButton{}}
+	if q.Message.ReplyMarkup != nil {
+		for _, row := range q.Message.ReplyMarkup.InlineKeyboard {
+			var links []inlineKeyboardButton
+			for _, button := range row {
+				if button.URL != "" {
+					links = append(links, button)
+				}
+			}
+			if len(links) > 0 {
+				markup.InlineKeyboard = append(markup.InlineKeyboard, links)
+			}
+		}
+	}
+	return s.bot.call(ctx, "editMessageReplyMarkup", map[string]interface{}{
+		"chat_id": q.Message.Chat.Id, "message_id": q.Message.MessageId, "reply_markup": markup,
+	}, nil)
+}
+
+func (s *ServiceImpl) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// backoff honors the wait asked by a throttled bot.
+func backoff(err error) time.Duration {
+	var be *botError
+	if errors.As(err, &be) && be.RetryAfter > 0 {
+		return time.Duration(be.RetryAfter) * time.Second
+	}
+	return pollBackoff
+}
@@ -0,0 +1,252 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/reminders"
+	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+
+// fakeBotAPI stands in for the Telegram Bot API: getUpdates returns the queued updates once, other calls are
+// recorded with their parameters.
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	updates []update
+	offsets []float64
+	calls   map[string][]map[string]interface{}
+	answer  string
+	called  chan string
+}
+
+func newFakeBotAPI(t *testing.T) (*fakeBotAPI, string) {
+	f := &fakeBotAPI{calls: map[string][]map[string]interface{}{}, called: make(chan string, 10)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/bot123:secret/") {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprint(w, `{"ok": false, "error_code": 404, "description": "Not Found"}`)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/bot123:secret/")
+	var params map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&params)
+
+	f.mu.Lock()
+	if method == "getUpdates" {
+		f.offsets = append(f.offsets, params["offset"].(float64))
+		updates := f.updates
+		f.updates = nil
+		f.mu.Unlock()
+		if len(updates) == 0 {
+			// a long poll without updates
+			time.Sleep(10 * time.Millisecond)
+		}
+		b, _ := json.Marshal(updates)
+		_, _ = fmt.Fprintf(w, `{"ok": true, "result": %s}`, b)
+		return
+	}
+	f.calls[method] = append(f.calls[method], params)
+	answer := f.answer
+	f.mu.Unlock()
+	if answer == "" {
+		answer = `{"ok": true, "result": true}`
+	}
+	_, _ = fmt.Fprint(w, answer)
+	f.called <- method
+}
+
+// fakeReminders performs actions with a fixed outcome.
+type fakeReminders struct {
+	reminders.RemindersService
+	result reminders.ActionResult
+	err    error
+	tokens []string
+}
+
+func (f *fakeReminders) PerformAction(_ context.Context, token string) (reminders.ActionResult, error) {
+	f.tokens = append(f.tokens, token)
+	return f.result, f.err
+}
+
+func TestService_Run_StartLinksChat(t *testing.T) {
+	api, url := newFakeBotAPI(t)
+	r, _, s := initService(t, url, &fakeReminders{})
+	api.updates = []update{{UpdateId: 41, Message: &message{MessageId: 1, Chat: chat{Id: 777}, Text: "/start abc123"}}}
+	r.On("UseLinkCode", mock.Anything, "abc123", now).Return("user-1", nil)
+	r.On("LinkChat", mock.Anything, "user-1", int64(777)).Return(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	assert.Equal(t, "sendMessage", <-api.called)
+	assert.Eventually(t, func() bool {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		return len(api.offsets) > 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	assert.Equal(t, float64(777), api.calls["sendMessage"][0]["chat_id"])
+	assert.Contains(t, api.calls["sendMessage"][0]["text"], "You're all set")
+	// the update is confirmed by the next poll
+	assert.Equal(t, float64(0), api.offsets[0])
+	assert.Equal(t, float64(42), api.offsets[1])
+	assert.Nil(t, s.Heartbeat().Check(context.Background()))
+}
+
+func TestService_HandleMessage_ExpiredCode(t *testing.T) {
+	api, url := newFakeBotAPI(t)
+	r, _, s := initService(t, url, &fakeReminders{})
+	r.On("UseLinkCode", mock.Anything, "old", now).Return("", nil)
+
+	err := s.handleMessage(context.Background(), &message{Chat: chat{Id: 777}, Text: "/start@manny_bot old"})
+
+	assert.Nil(t, err)
+	assert.Contains(t, api.calls["sendMessage"][0]["text"], "invalid or expired")
+}
+
+func TestService_HandleCallback_SnoozesAndKeepsLinks(t *testing.T) {
+	api, url := newFakeBotAPI(t)
+	rs := &fakeReminders{result: reminders.ActionResult{Message: "Snoozed for 5 minutes"}}
+	_, _, s := initService(t, url, rs)
+	markup := &inlineKeyboardMarkup{InlineKeyboard: [][]inlineKeyboardButton{
+		{{Text: "Snooze 5 min", CallbackData: "token"}, {Text: "Done", CallbackData: "token2"}},
+		{{Text: "Join", URL: "https://meet.google.com/abc-defg-hij"}},
+	}}
+
+	err := s.handleCallback(context.Background(), &callbackQuery{
+		Id: "q1", Data: "token", Message: &message{MessageId: 9, Chat: chat{Id: 777}, ReplyMarkup: markup},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"token"}, rs.tokens)
+	assert.Equal(t, "Snoozed for 5 minutes", api.calls["answerCallbackQuery"][0]["text"])
+	edited, _ := json.Marshal(api.calls["editMessageReplyMarkup"][0]["reply_markup"])
+	assert.JSONEq(t, `{"inline_keyboard": [[{"text": "Join", "url": "https://meet.google.com/abc-defg-hij"}]]}`, string(edited))
+}
+
+func TestService_HandleCallback_UsedAction(t *testing.T) {
+	api, url := newFakeBotAPI(t)
+	_, _, s := initService(t, url, &fakeReminders{err: reminders.ErrActionUsed})
+
+	err := s.handleCallback(context.Background(), &callbackQuery{Id: "q1", Data: "token", Message: &message{MessageId: 9}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "action link already used", api.calls["answerCallbackQuery"][0]["text"])
+	assert.Empty(t, api.calls["editMessageReplyMarkup"])
+}
+
+func TestService_CreateLinkCode(t *testing.T) {
+	_, url := newFakeBotAPI(t)
+	r, as, s := initService(t, url, &fakeReminders{})
+	id := uuid.New()
+	as.On("GetUser", mock.Anything, id.String()).Return(&models.User{Id: &id}, nil)
+	r.On("AddLinkCode", mock.Anything, mock.Anything, id.String(), now.Add(15*time.Minute)).Return(nil)
+
+	link, err := s.CreateLinkCode(context.Background(), id.String())
+
+	assert.Nil(t, err)
+	assert.Len(t, link.Code, 22)
+	assert.Equal(t, "https://t.me/manny_bot?start="+link.Code, link.URL)
+}
+
+func TestNotifier_SendsActionButtons(t *testing.T) {
+	api, url := newFakeBotAPI(t)
+	r := mocks.NewTelegramRepository(t)
+	n := NewNotifier(zap.NewNop(), r, config.TelegramConfig{APIURL: url, BotToken: "123:secret"})
+	user := generateUser()
+	chatId := int64(777)
+	r.On("GetChat", mock.Anything, user.Id.String()).Return(&chatId, nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{
+		Kind: reminders.KindReminder, User: user,
+		Event:   models.Event{Title: "Standup", Start: "2022-06-01T13:10:00Z", JoinURL: "https://meet.google.com/abc-defg-hij"},
+		Actions: []reminders.Action{{Name: reminders.ActionSnooze, Label: "Snooze 5 min", Token: "t1"}},
+	})
+
+	assert.Nil(t, err)
+	sent, _ := json.Marshal(api.calls["sendMessage"][0])
+	assert.JSONEq(t, `{"chat_id": 777, "text": "\"Standup\" starts at 1:10PM", "reply_markup": {"inline_keyboard": [
+		[{"text": "Snooze 5 min", "callback_data": "t1"}],
+		[{"text": "Join", "url": "https://meet.google.com/abc-defg-hij"}]
+	]}}`, string(sent))
+}
+
+func TestNotifier_BlockedBotUnlinksChat(t *testing.T) {
+	api, url := newFakeBotAPI(t)
+	api.answer = `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`
+	r := mocks.NewTelegramRepository(t)
+	n := NewNotifier(zap.NewNop(), r, config.TelegramConfig{APIURL: url, BotToken: "123:secret"})
+	user := generateUser()
+	chatId := int64(777)
+	r.On("GetChat", mock.Anything, user.Id.String()).Return(&chatId, nil)
+	r.On("UnlinkChat", mock.Anything, chatId).Return(nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: user})
+
+	assert.EqualError(t, err, "telegram sendMessage: 403 Forbidden: bot was blocked by the user")
+}
+
+func TestNotifier_NotLinked(t *testing.T) {
+	r := mocks.NewTelegramRepository(t)
+	n := NewNotifier(zap.NewNop(), r, config.TelegramConfig{})
+	user := generateUser()
+	r.On("GetChat", mock.Anything, user.Id.String()).Return(nil, nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: user})
+
+	assert.ErrorIs(t, err, errNotLinked)
+}
+
+func TestHandler_CreateLinkCode_UnknownUser(t *testing.T) {
+	ts := mocks.NewTelegramService(t)
+	ts.On("CreateLinkCode", mock.Anything, "nobody").Return(nil, nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{userId}/telegram/link", NewHandler(ts).CreateLinkCode)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/nobody/telegram/link", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func initService(t *testing.T, url string, rs reminders.RemindersService) (*mocks.TelegramRepository, *mocks.AuthService, *ServiceImpl) {
+	r := mocks.NewTelegramRepository(t)
+	as := mocks.NewAuthService(t)
+	s := NewService(zap.NewNop(), r, as, rs, config.TelegramConfig{
+		BotToken: "123:secret", BotName: "manny_bot", APIURL: url, PollTimeout: time.Second, LinkCodeTTL: 15 * time.Minute,
+	})
+	s.now = func() time.Time { return now }
+	return r, as, s
+}
+
+func generateUser() *models.User {
+	id := uuid.New()
+	return &models.User{Id: &id}
+}
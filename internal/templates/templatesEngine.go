@@ -0,0 +1,317 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"manny-reminder/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// ChannelDefault holds the templates of every channel without templates of its own.
+const ChannelDefault = "default"
+
+// Sources of a rendered message.
+const (
+	SourceDatabase = "database"
+	SourceFile     = "file"
+	SourceBuiltin  = "builtin"
+)
+
+// Key names a template.
+type Key struct {
+	Channel string
+	Locale  string
+	Kind    string
+	Format  string
+}
+
+// Data are the variables of a template. Times are in the time zone of the user, Location.
+type Data struct {
+	Kind          string
+	Channel       string
+	Locale        string
+	User          User
+	Event         models.Event
+	Previous      *models.Event
+	Start         time.Time
+	End           time.Time
+	PreviousStart time.Time
+	Location      *time.Location
+	Actions       []Action
+}
+
+// User is what templates see of the user, their accounts and tokens stay out of reach of template authors.
+type User struct {
+	Email    string
+	TimeZone string
+	Locale   string
+}
+
+func newUser(u *models.User) User {
+	var user User
+	if u == nil {
+		return user
+	}
+	if u.Email != nil {
+		user.Email = *u.Email
+	}
+	if u.TimeZone != nil {
+		user.TimeZone = *u.TimeZone
+	}
+	user.Locale = u.Language()
+	return user
+}
+
+// Action is a link the user can follow from the message.
+type Action struct {
+	Name  string
+	Label string
+	URL   string
+}
+
+// NewData derives the times of the event in the location, UTC when nil.
+func NewData(kind string, user *models.User, event models.Event, previous *models.Event, location *time.Location) Data {
+	if location == nil {
+		location = time.UTC
+	}
+	d := Data{Kind: kind, User: newUser(user), Event: event, Previous: previous, Location: location}
+	d.Start, _ = event.StartIn(location)
+	d.End, _ = event.EndIn(location)
+	d.PreviousStart = d.Start
+	if previous != nil {
+		d.PreviousStart, _ = previous.StartIn(location)
+	}
+	return d
+}
+
+// builtins keep the messages of the notifications in English when no template is found or every template fails.
+var builtins = map[Key]string{
+	{Kind: models.KindReminder, Format: models.FormatText}:  `{{printf "%q" .Event.Title}} starts at {{when .Start .Start}}`,
+	{Kind: models.KindMoved, Format: models.FormatText}:     `Your {{when .PreviousStart .PreviousStart}} meeting {{printf "%q" .Event.Title}} moved to {{when .Start .PreviousStart}}`,
+	{Kind: models.KindCancelled, Format: models.FormatText}: `Your {{when .Start .Start}} meeting {{printf "%q" .Event.Title}} was cancelled`,
+	{Kind: models.KindReminder, Format: models.FormatHTML}: `<p><strong>{{.Event.Title}}</strong> starts at {{when .Start .Start}}</p>
+{{- if .Event.JoinURL}}
+<p><a href="{{.Event.JoinURL}}">Join</a></p>{{end}}
+{{- if .Actions}}
+<p>{{range $i, $a := .Actions}}{{if $i}} · {{end}}<a href="{{$a.URL}}">{{$a.Label}}</a>{{end}}</p>{{end}}`,
+	{Kind: models.KindMoved, Format: models.FormatHTML}:     `<p>Your {{when .PreviousStart .PreviousStart}} meeting <strong>{{.Event.Title}}</strong> moved to {{when .Start .PreviousStart}}</p>`,
+	{Kind: models.KindCancelled, Format: models.FormatHTML}: `<p>Your {{when .Start .Start}} meeting <strong>{{.Event.Title}}</strong> was cancelled</p>`,
+}
+
+// Kinds and formats templates can be written for.
+var (
+	kinds   = map[string]bool{models.KindReminder: true, models.KindMoved: true, models.KindCancelled: true}
+	formats = map[string]bool{models.FormatText: true, models.FormatHTML: true}
+)
+
+var funcs = map[string]interface{}{
+	"when": FormatTime,
+	"join": strings.Join,
+	"format": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+// FormatTime shows the time of day, with the date when it isn't the day of reference.
+func FormatTime(t time.Time, reference time.Time) string {
+	if t.YearDay() != reference.YearDay() || t.Year() != reference.Year() {
+		return t.Format("Mon Jan 2 3:04PM")
+	}
+	return t.Format(time.Kitchen)
+}
+
+// executor is a parsed text or HTML template.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Engine renders the messages of notifications from the first template that works, looking in the database, then on
+// disk, for the channel then the default channel, in the locale, its language, then the default locale.
+type Engine struct {
+	l             *zap.Logger
+	r             TemplatesRepository
+	files         map[Key]string
+	defaultLocale string
+
+	mu sync.Mutex
+	// parsed caches the templates by format and body
+	parsed map[string]executor
+}
+
+func NewEngine(l *zap.Logger, r TemplatesRepository, dir string, defaultLocale string) (*Engine, error) {
+	files, err := loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{l: l, r: r, files: files, defaultLocale: defaultLocale, parsed: map[string]executor{}}, nil
+}
+
+// Render returns the message and where its template comes from. Failing templates are logged and skipped, the
+// built-in template is the last resort.
+func (e *Engine) Render(ctx context.Context, key Key, data Data) (string, string, error) {
+	data.Channel, data.Locale = key.Channel, key.Locale
+	candidates := e.candidates(key)
+
+	stored, err := e.r.FindTemplates(ctx, key.Kind, key.Format, channelsOf(candidates), localesOf(candidates))
+	if err != nil {
+		// the files and the built-in templates still work without the database
+		e.l.Error("Unable to load the message templates", zap.Error(err))
+	}
+	saved := map[Key]string{}
+	for _, t := range stored {
+		saved[Key{t.Channel, t.Locale, t.Kind, t.Format}] = t.Body
+	}
+
+	for _, candidate := range candidates {
+		for _, source := range []struct {
+			name      string
+			templates map[Key]string
+		}{{SourceDatabase, saved}, {SourceFile, e.files}} {
+			body, ok := source.templates[candidate]
+			if !ok {
+				continue
+			}
+			message, err := e.execute(key.Format, body, data)
+			if err != nil {
+				e.l.Error("Unable to render the message template", zap.String("source", source.name),
+					zap.String("channel", candidate.Channel), zap.String("locale", candidate.Locale),
+					zap.String("kind", candidate.Kind), zap.String("format", candidate.Format), zap.Error(err))
+				continue
+			}
+			return message, source.name, nil
+		}
+	}
+
+	body, ok := builtins[Key{Kind: key.Kind, Format: key.Format}]
+	if !ok {
+		return "", "", fmt.Errorf("no template for %s %s messages", key.Kind, key.Format)
+	}
+	message, err := e.execute(key.Format, body, data)
+	return message, SourceBuiltin, err
+}
+
+// Execute renders a template body, failing on any error.
+func (e *Engine) Execute(format string, body string, data Data) (string, error) {
+	return e.execute(format, body, data)
+}
+
+func (e *Engine) candidates(key Key) []Key {
+	locales := []string{key.Locale}
+	if i := strings.IndexAny(key.Locale, "-_"); i > 0 {
+		locales = append(locales, key.Locale[:i])
+	}
+	locales = append(locales, e.defaultLocale)
+
+	var candidates []Key
+	seen := map[Key]bool{}
+	for _, channel := range []string{key.Channel, ChannelDefault} {
+		for _, locale := range locales {
+			candidate := Key{Channel: channel, Locale: locale, Kind: key.Kind, Format: key.Format}
+			if locale == "" || seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+func (e *Engine) execute(format string, body string, data Data) (string, error) {
+	t, err := e.parse(format, body)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	err = t.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (e *Engine) parse(format string, body string) (executor, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cacheKey := format + "\x00" + body
+	if t, ok := e.parsed[cacheKey]; ok {
+		return t, nil
+	}
+
+	var t executor
+	var err error
+	switch format {
+	case models.FormatText:
+		t, err = texttemplate.New("message").Funcs(funcs).Parse(body)
+	case models.FormatHTML:
+		// the values are escaped for the context they appear in
+		t, err = htmltemplate.New("message").Funcs(funcs).Parse(body)
+	default:
+		err = fmt.Errorf("unknown template format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.parsed[cacheKey] = t
+	return t, nil
+}
+
+// loadDir reads the templates laid out as <channel>/<locale>/<kind>.<format>.tmpl, other files are ignored.
+func loadDir(dir string) (map[Key]string, error) {
+	files := map[Key]string{}
+	if dir == "" {
+		return files, nil
+	}
+	_, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("templates dir: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*", "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		name := strings.Split(strings.TrimSuffix(filepath.Base(path), ".tmpl"), ".")
+		if len(name) != 2 || !kinds[name[0]] || !formats[name[1]] {
+			continue
+		}
+		locale := filepath.Base(filepath.Dir(path))
+		channel := filepath.Base(filepath.Dir(filepath.Dir(path)))
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[Key{Channel: channel, Locale: locale, Kind: name[0], Format: name[1]}] = string(body)
+	}
+	return files, nil
+}
+
+func channelsOf(keys []Key) []string {
+	return distinct(keys, func(k Key) string { return k.Channel })
+}
+
+func localesOf(keys []Key) []string {
+	return distinct(keys, func(k Key) string { return k.Locale })
+}
+
+func distinct(keys []Key, field func(Key) string) []string {
+	var values []string
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if v := field(k); !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,105 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var event = models.Event{Id: "e1", Title: "Standup", Start: "2022-06-01T13:10:00Z", End: "2022-06-01T13:25:00Z"}
+
+func TestEngine_Render_DatabaseBeforeFile(t *testing.T) {
+	r, e := initEngine(t, map[string]string{"slack/fr/reminder.text.tmpl": "{{.Event.Title}} (fichier)"})
+	r.On("FindTemplates", mock.Anything, models.KindReminder, models.FormatText, []string{"slack", ChannelDefault}, []string{"fr", "en"}).
+		Return(models.MessageTemplates{{Channel: "slack", Locale: "fr", Kind: models.KindReminder, Format: models.FormatText, Body: "{{.Event.Title}} (base)"}}, nil)
+
+	message, source, err := e.Render(context.Background(), Key{"slack", "fr", models.KindReminder, models.FormatText}, data())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Standup (base)", message)
+	assert.Equal(t, SourceDatabase, source)
+}
+
+func TestEngine_Render_LanguageOfLocale(t *testing.T) {
+	r, e := initEngine(t, map[string]string{
+		"default/pt/reminder.text.tmpl": "{{.Event.Title}} começa às {{format \"15:04\" .Start}}",
+		"default/en/reminder.text.tmpl": "{{.Event.Title}} at {{.Start}}",
+	})
+	r.On("FindTemplates", mock.Anything, mock.Anything, mock.Anything, mock.Anything, []string{"pt-BR", "pt", "en"}).Return(models.MessageTemplates{}, nil)
+
+	message, source, err := e.Render(context.Background(), Key{"sms", "pt-BR", models.KindReminder, models.FormatText}, data())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Standup começa às 13:10", message)
+	assert.Equal(t, SourceFile, source)
+}
+
+func TestEngine_Render_BrokenTemplateFallsBack(t *testing.T) {
+	r, e := initEngine(t, map[string]string{"default/en/reminder.text.tmpl": "{{.Event.Nope}}"})
+	r.On("FindTemplates", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("db down"))
+
+	message, source, err := e.Render(context.Background(), Key{"sms", "en", models.KindReminder, models.FormatText}, data())
+
+	assert.Nil(t, err)
+	assert.Equal(t, `"Standup" starts at 1:10PM`, message)
+	assert.Equal(t, SourceBuiltin, source)
+}
+
+func TestEngine_Render_EscapesHTML(t *testing.T) {
+	r, e := initEngine(t, nil)
+	r.On("FindTemplates", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(models.MessageTemplates{}, nil)
+	d := data()
+	d.Event.Title = "<script>alert(1)</script>"
+
+	message, _, err := e.Render(context.Background(), Key{"email", "en", models.KindCancelled, models.FormatHTML}, d)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Your 1:10PM meeting <strong>&lt;script&gt;alert(1)&lt;/script&gt;</strong> was cancelled</p>", message)
+}
+
+func TestEngine_Execute_UserAccountsOutOfReach(t *testing.T) {
+	_, e := initEngine(t, nil)
+	email, token := "ada@example.com", "secret-token"
+	user := &models.User{Email: &email, Accounts: models.ConnectedAccounts{{Provider: models.ProviderGoogle, Token: &token}}}
+	d := NewData(models.KindReminder, user, event, nil, time.UTC)
+
+	greeting, err := e.Execute(models.FormatText, "Hi {{.User.Email}}", d)
+	assert.Nil(t, err)
+	assert.Equal(t, "Hi ada@example.com", greeting)
+
+	message, err := e.Execute(models.FormatText, "{{range .User.Accounts}}{{.Token}}{{end}}", d)
+	assert.Error(t, err)
+	assert.NotContains(t, message, token)
+}
+
+func TestNewEngine_MissingDir(t *testing.T) {
+	_, err := NewEngine(zap.NewNop(), nil, filepath.Join(t.TempDir(), "missing"), "en")
+
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func initEngine(t *testing.T, files map[string]string) (*mocks.TemplatesRepository, *Engine) {
+	dir := t.TempDir()
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(body), 0o644))
+	}
+	r := mocks.NewTemplatesRepository(t)
+	e, err := NewEngine(zap.NewNop(), r, dir, "en")
+	assert.Nil(t, err)
+	return r, e
+}
+
+func data() Data {
+	return NewData(models.KindReminder, &models.User{}, event, nil, time.UTC)
+}
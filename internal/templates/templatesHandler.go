@@ -0,0 +1,103 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"io"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+// maxTemplateSize bounds the body of a template.
+const maxTemplateSize = 64 << 10
+
+type HandlerImpl struct {
+	ts TemplatesService
+}
+
+func NewHandler(ts TemplatesService) *HandlerImpl {
+	return &HandlerImpl{ts: ts}
+}
+
+func (h HandlerImpl) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.ts.GetTemplates(r.Context())
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	utils.SendJson(w, templates)
+}
+
+// SaveTemplate saves the request body as the template of /admin/templates/{channel}/{locale}/{kind}/{format}.
+func (h HandlerImpl) SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxTemplateSize+1))
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if len(body) > maxTemplateSize {
+		utils.SendJsonWithStatus(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "template too large"})
+		return
+	}
+	key := keyOf(r)
+	t := models.MessageTemplate{Channel: key.Channel, Locale: key.Locale, Kind: key.Kind, Format: key.Format, Body: string(body)}
+
+	err = h.ts.SaveTemplate(r.Context(), t)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h HandlerImpl) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.ts.DeleteTemplate(r.Context(), keyOf(r))
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if !deleted {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "template not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preview renders a template against an event of a user, see PreviewRequest.
+func (h HandlerImpl) Preview(w http.ResponseWriter, r *http.Request) {
+	var request PreviewRequest
+	err := json.NewDecoder(io.LimitReader(r.Body, maxTemplateSize*2)).Decode(&request)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.ts.Preview(r.Context(), request)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	if preview == nil {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	utils.SendJson(w, preview)
+}
+
+func keyOf(r *http.Request) Key {
+	vars := mux.Vars(r)
+	return Key{Channel: vars["channel"], Locale: vars["locale"], Kind: vars["kind"], Format: vars["format"]}
+}
+
+func sendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTemplate):
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrEventNotFound):
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	default:
+		utils.SendHttpError(w, err)
+	}
+}
@@ -0,0 +1,101 @@
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+)
+
+type TemplatesRepository interface {
+	GetTemplates(ctx context.Context) (models.MessageTemplates, error)
+	FindTemplates(ctx context.Context, kind string, format string, channels []string, locales []string) (models.MessageTemplates, error)
+	SaveTemplate(ctx context.Context, t models.MessageTemplate) error
+	DeleteTemplate(ctx context.Context, channel string, locale string, kind string, format string) (bool, error)
+}
+
+type RepositoryImpl struct {
+	l  *zap.Logger
+	db *sql.DB
+}
+
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
+	return &RepositoryImpl{l, db}
+}
+
+const templateColumns = "channel, locale, kind, format, body, updated_at"
+
+func (r RepositoryImpl) GetTemplates(ctx context.Context) (_ models.MessageTemplates, err error) {
+	query := "SELECT " + templateColumns + " FROM message_templates ORDER BY channel, locale, kind, format"
+	ctx, span := tracing.StartQuery(ctx, "TemplatesRepository.GetTemplates", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	return scanTemplates(rows)
+}
+
+// FindTemplates returns the templates of the kind and format for any of the channels and locales.
+func (r RepositoryImpl) FindTemplates(ctx context.Context, kind string, format string, channels []string, locales []string) (_ models.MessageTemplates, err error) {
+	query := "SELECT " + templateColumns + ` FROM message_templates
+WHERE kind = $1 AND format = $2 AND channel = ANY($3) AND locale = ANY($4)`
+	ctx, span := tracing.StartQuery(ctx, "TemplatesRepository.FindTemplates", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, kind, format, pq.Array(channels), pq.Array(locales))
+	if err != nil {
+		return nil, err
+	}
+	defer r.closeRows(rows)
+	return scanTemplates(rows)
+}
+
+// SaveTemplate inserts the template or replaces the one with the same key.
+func (r RepositoryImpl) SaveTemplate(ctx context.Context, t models.MessageTemplate) (err error) {
+	query := `INSERT INTO message_templates (channel, locale, kind, format, body) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (channel, locale, kind, format) DO UPDATE SET body = $5, updated_at = now()`
+	ctx, span := tracing.StartQuery(ctx, "TemplatesRepository.SaveTemplate", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, t.Channel, t.Locale, t.Kind, t.Format, t.Body)
+	return err
+}
+
+// DeleteTemplate returns false when there is no such template.
+func (r RepositoryImpl) DeleteTemplate(ctx context.Context, channel string, locale string, kind string, format string) (_ bool, err error) {
+	query := "DELETE FROM message_templates WHERE channel = $1 AND locale = $2 AND kind = $3 AND format = $4"
+	ctx, span := tracing.StartQuery(ctx, "TemplatesRepository.DeleteTemplate", query)
+	defer tracing.End(span, &err)
+
+	res, err := r.db.ExecContext(ctx, query, channel, locale, kind, format)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r RepositoryImpl) closeRows(rows *sql.Rows) {
+	err := rows.Close()
+	if err != nil {
+		r.l.Error("Unable to close rows", zap.Error(err))
+	}
+}
+
+func scanTemplates(rows *sql.Rows) (models.MessageTemplates, error) {
+	templates := models.MessageTemplates{}
+	for rows.Next() {
+		var t models.MessageTemplate
+		err := rows.Scan(&t.Channel, &t.Locale, &t.Kind, &t.Format, &t.Body, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
@@ -0,0 +1,193 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/events"
+	"manny-reminder/internal/models"
+	"regexp"
+	"time"
+)
+
+// previewHorizon is how far ahead the preview looks for the next event of the user when none is given.
+const previewHorizon = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidTemplate = errors.New("invalid template")
+	ErrEventNotFound   = errors.New("event not found")
+)
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+// PreviewRequest renders the template of the key, or Body when set, against an event of the user. Without an event
+// id the next event of the user is used.
+type PreviewRequest struct {
+	Channel string `json:"channel"`
+	Locale  string `json:"locale"`
+	Kind    string `json:"kind"`
+	Format  string `json:"format"`
+	Body    string `json:"body"`
+	UserId  string `json:"userId"`
+	EventId string `json:"eventId"`
+}
+
+// Preview is a rendered message and where its template comes from, "request" for the body of the request.
+type Preview struct {
+	Message string `json:"message"`
+	Source  string `json:"source"`
+	EventId string `json:"eventId"`
+}
+
+const sourceRequest = "request"
+
+type TemplatesService interface {
+	GetTemplates(ctx context.Context) (models.MessageTemplates, error)
+	SaveTemplate(ctx context.Context, t models.MessageTemplate) error
+	DeleteTemplate(ctx context.Context, key Key) (bool, error)
+	Preview(ctx context.Context, request PreviewRequest) (*Preview, error)
+}
+
+type ServiceImpl struct {
+	l   *zap.Logger
+	r   TemplatesRepository
+	e   *Engine
+	as  auth.AuthService
+	er  events.EventsRepository
+	es  events.EventsService
+	now func() time.Time
+}
+
+func NewService(l *zap.Logger, r TemplatesRepository, e *Engine, as auth.AuthService, er events.EventsRepository, es events.EventsService) *ServiceImpl {
+	return &ServiceImpl{l: l, r: r, e: e, as: as, er: er, es: es, now: time.Now}
+}
+
+func (s *ServiceImpl) GetTemplates(ctx context.Context) (models.MessageTemplates, error) {
+	return s.r.GetTemplates(ctx)
+}
+
+// SaveTemplate saves the template once it renders a sample event, so a broken template never reaches the users.
+func (s *ServiceImpl) SaveTemplate(ctx context.Context, t models.MessageTemplate) error {
+	key := Key{Channel: t.Channel, Locale: t.Locale, Kind: t.Kind, Format: t.Format}
+	err := validateKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.e.Execute(t.Format, t.Body, sampleData(key))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	return s.r.SaveTemplate(ctx, t)
+}
+
+func (s *ServiceImpl) DeleteTemplate(ctx context.Context, key Key) (bool, error) {
+	return s.r.DeleteTemplate(ctx, key.Channel, key.Locale, key.Kind, key.Format)
+}
+
+// Preview renders a template against a real event of the user, nil when the user doesn't exist. A body given in the
+// request is rendered as is and its errors are returned, a saved template falls back like reminders do.
+func (s *ServiceImpl) Preview(ctx context.Context, request PreviewRequest) (*Preview, error) {
+	key := Key{Channel: request.Channel, Locale: request.Locale, Kind: request.Kind, Format: request.Format}
+	err := validateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.as.GetUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	event, err := s.previewEvent(ctx, request.UserId, request.EventId)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *models.Event
+	if key.Kind == models.KindMoved {
+		// the event as if it was an hour earlier
+		start, _ := event.StartTime()
+		earlier := *event
+		earlier.Start = start.Add(-time.Hour).UTC().Format(time.RFC3339)
+		previous = &earlier
+	}
+	data := NewData(key.Kind, user, *event, previous, user.Location())
+	data.Channel, data.Locale, data.Actions = key.Channel, key.Locale, sampleActions
+
+	if request.Body != "" {
+		message, err := s.e.Execute(key.Format, request.Body, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+		}
+		return &Preview{Message: message, Source: sourceRequest, EventId: event.Id}, nil
+	}
+	message, source, err := s.e.Render(ctx, key, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{Message: message, Source: source, EventId: event.Id}, nil
+}
+
+// previewEvent returns the synced event, or the next timed event of the user.
+func (s *ServiceImpl) previewEvent(ctx context.Context, userId string, eventId string) (*models.Event, error) {
+	if eventId != "" {
+		event, err := s.er.GetEventSnapshot(ctx, userId, eventId)
+		if err != nil {
+			return nil, err
+		}
+		if event == nil {
+			return nil, fmt.Errorf("%w: %s isn't among the synced events of the user", ErrEventNotFound, eventId)
+		}
+		return event, nil
+	}
+
+	now := s.now()
+	upcoming, err := s.es.GetUserEventsInRange(ctx, userId, now, now.Add(previewHorizon))
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range upcoming {
+		// all-day events get no reminders
+		if !event.AllDay() {
+			return &event, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: the user has no event in the next %s", ErrEventNotFound, previewHorizon)
+}
+
+func validateKey(key Key) error {
+	switch {
+	case key.Channel == "":
+		return fmt.Errorf("%w: the channel is required", ErrInvalidTemplate)
+	case !localePattern.MatchString(key.Locale):
+		return fmt.Errorf("%w: the locale %q isn't a language tag", ErrInvalidTemplate, key.Locale)
+	case !kinds[key.Kind]:
+		return fmt.Errorf("%w: the kind must be reminder, moved or cancelled", ErrInvalidTemplate)
+	case !formats[key.Format]:
+		return fmt.Errorf("%w: the format must be text or html", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+var sampleActions = []Action{
+	{Name: "snooze", Label: "Snooze 5 min", URL: "#"},
+	{Name: "done", Label: "Done", URL: "#"},
+}
+
+// sampleData is a made-up event templates must render before they are saved.
+func sampleData(key Key) Data {
+	email := "ada@example.com"
+	id := "sample"
+	previous := models.Event{Id: id, Title: "Weekly sync", Start: "2022-06-01T14:00:00Z", End: "2022-06-01T14:30:00Z"}
+	event := models.Event{
+		Id: id, Title: "Weekly sync", Start: "2022-06-01T15:00:00Z", End: "2022-06-01T15:30:00Z",
+		Organizer: "grace@example.com", Attendees: []string{"ada@example.com", "grace@example.com"},
+		Status: models.EventConfirmed, ResponseStatus: models.ResponseAccepted, JoinURL: "https://meet.google.com/abc-defg-hij",
+	}
+	data := NewData(key.Kind, &models.User{Email: &email}, event, &previous, nil)
+	data.Channel, data.Locale, data.Actions = key.Channel, key.Locale, sampleActions
+	return data
+}
@@ -0,0 +1,82 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"manny-reminder/mocks"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+
+func TestService_SaveTemplate_RejectsBrokenTemplate(t *testing.T) {
+	_, _, _, _, s := initService(t)
+
+	err := s.SaveTemplate(context.Background(), models.MessageTemplate{
+		Channel: "slack", Locale: "en", Kind: models.KindReminder, Format: models.FormatText, Body: "{{.Event.Nope}}",
+	})
+
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+}
+
+func TestService_SaveTemplate_RejectsUnknownKind(t *testing.T) {
+	_, _, _, _, s := initService(t)
+
+	err := s.SaveTemplate(context.Background(), models.MessageTemplate{
+		Channel: "slack", Locale: "en", Kind: "digest", Format: models.FormatText, Body: "{{.Event.Title}}",
+	})
+
+	assert.EqualError(t, err, "invalid template: the kind must be reminder, moved or cancelled")
+}
+
+func TestService_Preview_NextEventOfUser(t *testing.T) {
+	_, as, _, es, s := initService(t)
+	user := generateUser()
+	allDay := models.Event{Id: "e0", Title: "Holiday", Start: "2022-06-01", End: "2022-06-02"}
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	es.On("GetUserEventsInRange", mock.Anything, user.Id.String(), now, now.Add(previewHorizon)).Return(models.Events{allDay, event}, nil)
+
+	preview, err := s.Preview(context.Background(), PreviewRequest{
+		Channel: "sms", Locale: "en", Kind: models.KindMoved, Format: models.FormatText,
+		Body: `{{.Event.Title}}: {{format "15:04" .PreviousStart}} → {{format "15:04" .Start}}`, UserId: user.Id.String(),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, &Preview{Message: "Standup: 12:10 → 13:10", Source: sourceRequest, EventId: "e1"}, preview)
+}
+
+func TestService_Preview_UnknownEvent(t *testing.T) {
+	_, as, er, _, s := initService(t)
+	user := generateUser()
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	er.On("GetEventSnapshot", mock.Anything, user.Id.String(), "e9").Return(nil, nil)
+
+	_, err := s.Preview(context.Background(), PreviewRequest{
+		Channel: "sms", Locale: "en", Kind: models.KindReminder, Format: models.FormatText, UserId: user.Id.String(), EventId: "e9",
+	})
+
+	assert.True(t, errors.Is(err, ErrEventNotFound))
+}
+
+func initService(t *testing.T) (*mocks.TemplatesRepository, *mocks.AuthService, *mocks.EventsRepository, *mocks.EventsService, *ServiceImpl) {
+	r := mocks.NewTemplatesRepository(t)
+	as := mocks.NewAuthService(t)
+	er := mocks.NewEventsRepository(t)
+	es := mocks.NewEventsService(t)
+	e, err := NewEngine(zap.NewNop(), r, "", "en")
+	assert.Nil(t, err)
+	s := NewService(zap.NewNop(), r, e, as, er, es)
+	s.now = func() time.Time { return now }
+	return r, as, er, es, s
+}
+
+func generateUser() *models.User {
+	id := uuid.New()
+	return &models.User{Id: &id}
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"manny-reminder/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStartQuery_RecordsStatementAndError(t *testing.T) {
+	sr := useRecorder(t)
+
+	func() (err error) {
+		_, span := StartQuery(context.Background(), "AuthRepository.GetUser", "SELECT id FROM users WHERE id = $1")
+		defer End(span, &err)
+		return errors.New("connection refused")
+	}()
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "AuthRepository.GetUser", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), semconv.DBOperationKey.String("SELECT"))
+	assert.Contains(t, spans[0].Attributes(), semconv.DBStatementKey.String("SELECT id FROM users WHERE id = $1"))
+}
+
+func TestTransport_PropagatesTraceContext(t *testing.T) {
+	sr := useRecorder(t)
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer ts.Close()
+
+	ctx, parent := Start(context.Background(), "Reminder.Dispatch")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	res, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	assert.Nil(t, err)
+	_ = res.Body.Close()
+	parent.End()
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Contains(t, traceparent, parent.SpanContext().TraceID().String())
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: "jaeger"})
+
+	assert.Error(t, err)
+	assert.Nil(t, shutdown)
+}
+
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return sr
+}
+
+func TestDetach_KeepsTheSpanButNotTheCancellation(t *testing.T) {
+	useRecorder(t)
+	ctx, span := Start(context.Background(), "SlackHandler.Interactions")
+	defer span.End()
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	detached := Detach(ctx)
+
+	assert.Nil(t, detached.Err())
+	assert.Equal(t, span.SpanContext(), trace.SpanContextFromContext(detached))
+}
@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"time"
 )
 
 func SendHttpError(w io.Writer, err error) {
@@ -28,3 +31,35 @@ func SendJson(w io.Writer, body interface{}) {
 		SendHttpError(w, err)
 	}
 }
+
+func SendJsonWithStatus(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	SendJson(w, body)
+}
+
+// Timeout gives every request a deadline, so the calls made on its behalf stop once the response can't be sent anymore.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// StatusWriter records the status of the response for the middlewares observing requests.
+type StatusWriter struct {
+	http.ResponseWriter
+	Status int
+}
+
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (w *StatusWriter) WriteHeader(status int) {
+	w.Status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,179 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// recordSize is the record size of the aes128gcm encoding, a push message fits in one record.
+const recordSize = 4096
+
+// maxPayloadSize leaves room in the record for the header, the padding delimiter and the tag.
+const maxPayloadSize = recordSize - 16 - 4 - 1 - 65 - 1 - 16
+
+var errInvalidKey = errors.New("invalid VAPID private key")
+
+// generateKey returns a new VAPID private key, encoded like the private keys of the usual web push libraries.
+func generateKey() (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	d := make([]byte, 32)
+	key.D.FillBytes(d)
+	return base64.RawURLEncoding.EncodeToString(d), nil
+}
+
+// parseKey reads a raw base64url encoded P-256 private key.
+func parseKey(encoded string) (*ecdsa.PrivateKey, error) {
+	d, err := decodeBase64(encoded)
+	if err != nil || len(d) != 32 {
+		return nil, errInvalidKey
+	}
+	curve := elliptic.P256()
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	if key.D.Sign() == 0 || key.D.Cmp(curve.Params().N) >= 0 {
+		return nil, errInvalidKey
+	}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d)
+	return key, nil
+}
+
+// publicKey is the uncompressed point browsers take as applicationServerKey, base64url encoded.
+func publicKey(key *ecdsa.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(elliptic.Marshal(key.Curve, key.X, key.Y))
+}
+
+// vapidAuthorization signs the Authorization header of a push to the endpoint, RFC 8292.
+func vapidAuthorization(key *ecdsa.PrivateKey, endpoint string, subject string, expiresAt time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": expiresAt.Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	jwt := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return "vapid t=" + jwt + ", k=" + publicKey(key), nil
+}
+
+// subscriptionKeys are the decoded keys of a subscription.
+type subscriptionKeys struct {
+	public []byte
+	x, y   *big.Int
+	auth   []byte
+}
+
+func parseSubscriptionKeys(p256dh string, auth string) (*subscriptionKeys, error) {
+	public, err := decodeBase64(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), public)
+	if x == nil {
+		return nil, errors.New("invalid p256dh key: not an uncompressed P-256 point")
+	}
+	secret, err := decodeBase64(auth)
+	if err != nil || len(secret) != 16 {
+		return nil, errors.New("invalid auth secret: 16 bytes are expected")
+	}
+	return &subscriptionKeys{public: public, x: x, y: y, auth: secret}, nil
+}
+
+// encrypt encrypts the payload for the subscription with the aes128gcm content encoding, RFC 8291. The sender key
+// and the salt are fresh for every message, they are taken as arguments for the tests.
+func encrypt(payload []byte, keys *subscriptionKeys, sender *ecdsa.PrivateKey, salt []byte) ([]byte, error) {
+	if len(payload) > maxPayloadSize {
+		return nil, fmt.Errorf("push payload of %d bytes is over %d", len(payload), maxPayloadSize)
+	}
+	senderPublic := elliptic.Marshal(sender.Curve, sender.X, sender.Y)
+
+	sharedX, _ := sender.Curve.ScalarMult(keys.x, keys.y, sender.D.Bytes())
+	shared := make([]byte, 32)
+	sharedX.FillBytes(shared)
+
+	keyInfo := append([]byte("WebPush: info\x00"), keys.public...)
+	keyInfo = append(keyInfo, senderPublic...)
+	ikm := hkdf(keys.auth, shared, keyInfo, 32)
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 16+4+1, 16+4+1+len(senderPublic))
+	copy(header, salt)
+	binary.BigEndian.PutUint32(header[16:], recordSize)
+	header[20] = byte(len(senderPublic))
+	header = append(header, senderPublic...)
+	// a single record, ended by the last record delimiter without padding
+	record := append(payload[:len(payload):len(payload)], 2)
+	return gcm.Seal(header, nonce, record, nil), nil
+}
+
+// encryptFor encrypts the payload with a fresh sender key and salt.
+func encryptFor(payload []byte, keys *subscriptionKeys) ([]byte, error) {
+	sender, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	_, err = io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(payload, keys, sender, salt)
+}
+
+// hkdf derives a key of up to 32 bytes, RFC 5869.
+func hkdf(salt []byte, ikm []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+// decodeBase64 takes base64url with or without padding, as browsers and libraries differ.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
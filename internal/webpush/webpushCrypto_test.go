@@ -0,0 +1,133 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example of RFC 8291 appendix A.
+const (
+	rfcPlaintext     = "When I grow up, I want to be a watermelon"
+	rfcSenderPrivate = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPrivate     = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic      = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcAuth          = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcSalt          = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcMessage       = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func TestEncrypt_RFC8291Example(t *testing.T) {
+	sender, err := parseKey(rfcSenderPrivate)
+	assert.Nil(t, err)
+	keys, err := parseSubscriptionKeys(rfcUAPublic, rfcAuth)
+	assert.Nil(t, err)
+	salt, _ := decodeBase64(rfcSalt)
+
+	message, err := encrypt([]byte(rfcPlaintext), keys, sender, salt)
+
+	assert.Nil(t, err)
+	assert.Equal(t, rfcMessage, base64.RawURLEncoding.EncodeToString(message))
+}
+
+func TestEncryptFor_DecryptsWithTheBrowserKey(t *testing.T) {
+	keys, err := parseSubscriptionKeys(rfcUAPublic, rfcAuth)
+	assert.Nil(t, err)
+
+	message, err := encryptFor([]byte(rfcPlaintext), keys)
+
+	assert.Nil(t, err)
+	assert.Equal(t, rfcPlaintext, string(decrypt(t, message, rfcUAPrivate, rfcAuth)))
+}
+
+func TestEncrypt_TooLarge(t *testing.T) {
+	keys, _ := parseSubscriptionKeys(rfcUAPublic, rfcAuth)
+
+	_, err := encryptFor(make([]byte, maxPayloadSize+1), keys)
+
+	assert.EqualError(t, err, "push payload of 3994 bytes is over 3993")
+}
+
+func TestParseSubscriptionKeys_Invalid(t *testing.T) {
+	_, err := parseSubscriptionKeys("BCVxsr7N_eNgVRqvHtD0zTZs", rfcAuth)
+	assert.EqualError(t, err, "invalid p256dh key: not an uncompressed P-256 point")
+
+	_, err = parseSubscriptionKeys(rfcUAPublic, "BTBZMqHH6r4T")
+	assert.EqualError(t, err, "invalid auth secret: 16 bytes are expected")
+}
+
+func TestVapidAuthorization_SignsForTheOriginOfTheEndpoint(t *testing.T) {
+	encoded, err := generateKey()
+	assert.Nil(t, err)
+	key, err := parseKey(encoded)
+	assert.Nil(t, err)
+	expiresAt := time.Date(2022, 6, 2, 1, 0, 0, 0, time.UTC)
+
+	authorization, err := vapidAuthorization(key, "https://fcm.googleapis.com/fcm/send/abc", "mailto:ops@example.com", expiresAt)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(authorization, "vapid t="))
+	assert.True(t, strings.HasSuffix(authorization, ", k="+publicKey(key)))
+	jwt := strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+publicKey(key))
+	parts := strings.Split(jwt, ".")
+	assert.Len(t, parts, 3)
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.JSONEq(t, `{"aud": "https://fcm.googleapis.com", "exp": 1654131600, "sub": "mailto:ops@example.com"}`, string(claims))
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s))
+}
+
+func TestParseKey_Invalid(t *testing.T) {
+	_, err := parseKey("not a key")
+	assert.Equal(t, errInvalidKey, err)
+
+	_, err = parseKey(base64.RawURLEncoding.EncodeToString(make([]byte, 32)))
+	assert.Equal(t, errInvalidKey, err)
+}
+
+// decrypt does what the browser does with a push message.
+func decrypt(t *testing.T, message []byte, uaPrivate string, auth string) []byte {
+	ua, err := parseKey(uaPrivate)
+	assert.Nil(t, err)
+	secret, _ := decodeBase64(auth)
+	salt := message[:16]
+	assert.Equal(t, uint32(recordSize), binary.BigEndian.Uint32(message[16:20]))
+	idLen := int(message[20])
+	senderPublic := message[21 : 21+idLen]
+	x, y := elliptic.Unmarshal(elliptic.P256(), senderPublic)
+
+	sharedX, _ := ua.Curve.ScalarMult(x, y, ua.D.Bytes())
+	shared := make([]byte, 32)
+	sharedX.FillBytes(shared)
+	keyInfo := append([]byte("WebPush: info\x00"), elliptic.Marshal(ua.Curve, ua.X, ua.Y)...)
+	keyInfo = append(keyInfo, senderPublic...)
+	ikm := hkdf(secret, shared, keyInfo, 32)
+	block, _ := aes.NewCipher(hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	gcm, _ := cipher.NewGCM(block)
+
+	record, err := gcm.Open(nil, hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), message[21+idLen:], nil)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(2), record[len(record)-1])
+	return record[:len(record)-1]
+}
+
+// decryptPayload decrypts the payload pushed by the notifier.
+func decryptPayload(t *testing.T, message []byte) payload {
+	var p payload
+	err := json.Unmarshal(decrypt(t, message, rfcUAPrivate, rfcAuth), &p)
+	assert.Nil(t, err)
+	return p
+}
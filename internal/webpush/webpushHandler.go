@@ -0,0 +1,155 @@
+package webpush
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"html/template"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/utils"
+	"net/http"
+)
+
+// serviceWorker shows the pushed reminders. Clicking an action posts its link, clicking the notification opens
+// the meeting. Done is kept when the browser shows fewer actions than the reminder has.
+const serviceWorker = `self.addEventListener('push', event => {
+  const data = event.data ? event.data.json() : {};
+  const max = Notification.maxActions || 2;
+  const actions = (data.actions || []).map((a, i) => ({action: String(i), title: a.title, done: a.action === 'done'}));
+  const done = actions.filter(a => a.done);
+  const shown = actions.filter(a => !a.done).slice(0, Math.max(max - done.length, 0)).concat(done).slice(0, max);
+  event.waitUntil(self.registration.showNotification(data.title || 'Reminder', {
+    body: data.body, tag: data.tag, renotify: true, requireInteraction: true,
+    actions: shown.map(a => ({action: a.action, title: a.title})), data: data,
+  }));
+});
+
+self.addEventListener('notificationclick', event => {
+  const data = event.notification.data || {};
+  event.notification.close();
+  const action = event.action !== '' ? (data.actions || [])[Number(event.action)] : undefined;
+  if (action) {
+    event.waitUntil(fetch(action.url, {method: 'POST', headers: {Accept: 'application/json'}})
+      .then(resp => resp.json())
+      .then(result => self.registration.showNotification(data.title || 'Reminder', {body: result.message || result.error, tag: data.tag})));
+  } else if (data.url) {
+    event.waitUntil(clients.openWindow(data.url));
+  }
+});
+`
+
+// subscribePage asks the browser for permission and subscribes it to the reminders of the user.
+var subscribePage = template.Must(template.New("subscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width"><title>Browser reminders</title></head>
+<body>
+<p>Get your meeting reminders as browser notifications.</p>
+<button id="subscribe" type="button">Turn on notifications</button>
+<p id="status"></p>
+<script>
+const message = document.getElementById('status');
+document.getElementById('subscribe').addEventListener('click', async () => {
+  try {
+    const registration = await navigator.serviceWorker.register({{.ServiceWorkerURL}});
+    await navigator.serviceWorker.ready;
+    const key = atob({{.PublicKey}}.replace(/-/g, '+').replace(/_/g, '/'));
+    const subscription = await registration.pushManager.subscribe({
+      userVisibleOnly: true, applicationServerKey: Uint8Array.from(key, c => c.charCodeAt(0)),
+    });
+    const resp = await fetch({{.SubscriptionsURL}}, {
+      method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(subscription),
+    });
+    message.textContent = resp.ok ? 'Notifications are on for this browser.' : 'Unable to turn notifications on: ' + resp.status;
+  } catch (e) {
+    message.textContent = 'Unable to turn notifications on: ' + e.message;
+  }
+});
+</script>
+</body>
+</html>
+`))
+
+type subscribeView struct {
+	PublicKey        string
+	ServiceWorkerURL string
+	SubscriptionsURL string
+}
+
+type HandlerImpl struct {
+	ws WebPushService
+}
+
+func NewHandler(ws WebPushService) *HandlerImpl {
+	return &HandlerImpl{ws: ws}
+}
+
+// GetPublicKey returns the VAPID public key browsers subscribe with.
+func (h HandlerImpl) GetPublicKey(w http.ResponseWriter, r *http.Request) {
+	utils.SendJson(w, map[string]string{"publicKey": h.ws.PublicKey()})
+}
+
+func (h HandlerImpl) ServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	_, err := w.Write([]byte(serviceWorker))
+	if err != nil {
+		utils.SendHttpError(w, err)
+	}
+}
+
+// SubscribePage lets the user turn on the notifications of a browser.
+func (h HandlerImpl) SubscribePage(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := subscribePage.Execute(w, subscribeView{
+		PublicKey:        h.ws.PublicKey(),
+		ServiceWorkerURL: "/push/sw.js",
+		SubscriptionsURL: "/users/" + userId + "/push/subscriptions",
+	})
+	if err != nil {
+		utils.SendHttpError(w, err)
+	}
+}
+
+// Subscribe saves the subscription a browser posts, as given by PushSubscription.toJSON().
+func (h HandlerImpl) Subscribe(w http.ResponseWriter, r *http.Request) {
+	userId := mux.Vars(r)["userId"]
+	if userId == "" {
+		utils.SendHttpStringError(w, "User id not defined")
+		return
+	}
+	var request models.PushSubscription
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.ws.Subscribe(r.Context(), userId, request)
+	if errors.Is(err, ErrInvalidSubscription) {
+		utils.SendJsonWithStatus(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if subscription == nil {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "user not found"})
+		return
+	}
+	utils.SendJsonWithStatus(w, http.StatusCreated, subscription)
+}
+
+func (h HandlerImpl) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	deleted, err := h.ws.Unsubscribe(r.Context(), vars["userId"], vars["subscriptionId"])
+	if err != nil {
+		utils.SendHttpError(w, err)
+		return
+	}
+	if !deleted {
+		utils.SendJsonWithStatus(w, http.StatusNotFound, map[string]string{"error": "subscription not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
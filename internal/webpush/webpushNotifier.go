@@ -0,0 +1,165 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/reminders"
+	"manny-reminder/internal/tracing"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ChannelWebPush = "webpush"
+
+var errNoSubscriptions = errors.New("webpush: the user has no push subscription")
+
+// pushError is a push the push service refused.
+type pushError struct {
+	Status  int
+	Message string
+}
+
+func (e *pushError) Error() string {
+	return fmt.Sprintf("webpush: %d %s", e.Status, e.Message)
+}
+
+// payload is what the service worker gets, it shows the notification and posts the action the user clicks.
+type payload struct {
+	Title   string          `json:"title"`
+	Body    string          `json:"body"`
+	Tag     string          `json:"tag"`
+	URL     string          `json:"url,omitempty"`
+	Actions []payloadAction `json:"actions,omitempty"`
+}
+
+type payloadAction struct {
+	Action string `json:"action"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+}
+
+// Notifier pushes reminders to every browser the user subscribed.
+type Notifier struct {
+	l      *zap.Logger
+	r      WebPushRepository
+	key    *ecdsa.PrivateKey
+	c      config.WebPushConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewNotifier(l *zap.Logger, r WebPushRepository, key *ecdsa.PrivateKey, c config.WebPushConfig) *Notifier {
+	return &Notifier{
+		l:      l,
+		r:      r,
+		key:    key,
+		c:      c,
+		client: &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+}
+
+func (n *Notifier) Channel() string {
+	return ChannelWebPush
+}
+
+// Notify succeeds when a browser of the user took the push. Subscriptions the push service no longer knows are
+// deleted.
+func (n *Notifier) Notify(ctx context.Context, notification reminders.Notification) error {
+	subscriptions, err := n.r.GetSubscriptions(ctx, notification.User.Id.String())
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(newPayload(notification))
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	err = errNoSubscriptions
+	for _, s := range subscriptions {
+		pushErr := n.push(ctx, s, body)
+		var pe *pushError
+		if errors.As(pushErr, &pe) && (pe.Status == http.StatusNotFound || pe.Status == http.StatusGone) {
+			n.l.Info("Deleting the expired push subscription", zap.Stringer("subscriptionId", s.Id), zap.Int("status", pe.Status))
+			deleteErr := n.r.DeleteSubscriptionByEndpoint(ctx, s.Endpoint)
+			if deleteErr != nil {
+				n.l.Error("Unable to delete the push subscription", zap.Error(deleteErr))
+			}
+			continue
+		}
+		if pushErr != nil {
+			n.l.Warn("Unable to push the notification", zap.Stringer("subscriptionId", s.Id), zap.Error(pushErr))
+			err = pushErr
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		return nil
+	}
+	return err
+}
+
+func (n *Notifier) push(ctx context.Context, s models.PushSubscription, body []byte) error {
+	keys, err := parseSubscriptionKeys(s.Keys.P256dh, s.Keys.Auth)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encryptFor(body, keys)
+	if err != nil {
+		return err
+	}
+	// push services take tokens valid for up to a day
+	authorization, err := vapidAuthorization(n.key, s.Endpoint, n.c.Subject, n.now().Add(12*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, bytes.NewReader(encrypted))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// a reminder is worthless once the meeting started
+	req.Header.Set("TTL", strconv.Itoa(int(n.c.TTL/time.Second)))
+	req.Header.Set("Urgency", "high")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return &pushError{Status: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+// newPayload keeps the actions that have a link, the service worker posts to it.
+func newPayload(notification reminders.Notification) payload {
+	p := payload{Title: notification.Event.Title, Body: notification.Text(), Tag: notification.Event.Id}
+	if notification.Kind != reminders.KindCancelled {
+		p.URL = notification.Event.JoinURL
+	}
+	for _, action := range notification.Actions {
+		if action.URL != "" {
+			p.Actions = append(p.Actions, payloadAction{Action: action.Name, Title: action.Label, URL: action.URL})
+		}
+	}
+	return p
+}
@@ -0,0 +1,116 @@
+package webpush
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/tracing"
+)
+
+type WebPushRepository interface {
+	AddSubscription(ctx context.Context, s *models.PushSubscription) error
+	GetSubscriptions(ctx context.Context, userId string) ([]models.PushSubscription, error)
+	DeleteSubscription(ctx context.Context, userId string, id string) (bool, error)
+	DeleteSubscriptionByEndpoint(ctx context.Context, endpoint string) error
+	AddVAPIDKey(ctx context.Context, privateKey string) error
+	GetVAPIDKey(ctx context.Context) (string, error)
+}
+
+type RepositoryImpl struct {
+	l  *zap.Logger
+	db *sql.DB
+}
+
+func NewRepository(l *zap.Logger, db *sql.DB) *RepositoryImpl {
+	return &RepositoryImpl{l, db}
+}
+
+// AddSubscription saves the subscription with a new id. A browser subscribing again keeps its id and moves to the
+// user, the id and the creation time are set from the saved row.
+func (r RepositoryImpl) AddSubscription(ctx context.Context, s *models.PushSubscription) (err error) {
+	query := `INSERT INTO webpush_subscriptions (id, user_id, endpoint, p256dh, auth) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (endpoint) DO UPDATE SET user_id = $2, p256dh = $4, auth = $5
+RETURNING id, created_at`
+	ctx, span := tracing.StartQuery(ctx, "WebPushRepository.AddSubscription", query)
+	defer tracing.End(span, &err)
+
+	id := uuid.New()
+	err = r.db.QueryRowContext(ctx, query, id, s.UserId, s.Endpoint, s.Keys.P256dh, s.Keys.Auth).Scan(&id, &s.CreatedAt)
+	if err != nil {
+		return err
+	}
+	s.Id = &id
+	return nil
+}
+
+func (r RepositoryImpl) GetSubscriptions(ctx context.Context, userId string) (_ []models.PushSubscription, err error) {
+	query := "SELECT id, user_id, endpoint, p256dh, auth, created_at FROM webpush_subscriptions WHERE user_id = $1 ORDER BY created_at"
+	ctx, span := tracing.StartQuery(ctx, "WebPushRepository.GetSubscriptions", query)
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []models.PushSubscription{}
+	for rows.Next() {
+		var s models.PushSubscription
+		err = rows.Scan(&s.Id, &s.UserId, &s.Endpoint, &s.Keys.P256dh, &s.Keys.Auth, &s.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions, rows.Err()
+}
+
+// DeleteSubscription returns false when the user has no such subscription.
+func (r RepositoryImpl) DeleteSubscription(ctx context.Context, userId string, id string) (_ bool, err error) {
+	query := "DELETE FROM webpush_subscriptions WHERE user_id = $1 AND id = $2"
+	ctx, span := tracing.StartQuery(ctx, "WebPushRepository.DeleteSubscription", query)
+	defer tracing.End(span, &err)
+
+	result, err := r.db.ExecContext(ctx, query, userId, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (r RepositoryImpl) DeleteSubscriptionByEndpoint(ctx context.Context, endpoint string) (err error) {
+	query := "DELETE FROM webpush_subscriptions WHERE endpoint = $1"
+	ctx, span := tracing.StartQuery(ctx, "WebPushRepository.DeleteSubscriptionByEndpoint", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, endpoint)
+	return err
+}
+
+// AddVAPIDKey saves the key of the server unless there is one already, the first replica to start wins.
+func (r RepositoryImpl) AddVAPIDKey(ctx context.Context, privateKey string) (err error) {
+	query := "INSERT INTO webpush_vapid_keys (id, private_key) VALUES (1, $1) ON CONFLICT (id) DO NOTHING"
+	ctx, span := tracing.StartQuery(ctx, "WebPushRepository.AddVAPIDKey", query)
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, query, privateKey)
+	return err
+}
+
+// GetVAPIDKey returns an empty key when none was saved yet.
+func (r RepositoryImpl) GetVAPIDKey(ctx context.Context) (_ string, err error) {
+	query := "SELECT private_key FROM webpush_vapid_keys WHERE id = 1"
+	ctx, span := tracing.StartQuery(ctx, "WebPushRepository.GetVAPIDKey", query)
+	defer tracing.End(span, &err)
+
+	var key string
+	err = r.db.QueryRowContext(ctx, query).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return key, err
+}
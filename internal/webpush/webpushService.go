@@ -0,0 +1,109 @@
+package webpush
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"manny-reminder/internal/auth"
+	"manny-reminder/internal/models"
+	"net/url"
+)
+
+// ErrInvalidSubscription is a subscription the browser couldn't have made, it's never sent to.
+var ErrInvalidSubscription = errors.New("invalid push subscription")
+
+type WebPushService interface {
+	PublicKey() string
+	Subscribe(ctx context.Context, userId string, s models.PushSubscription) (*models.PushSubscription, error)
+	Unsubscribe(ctx context.Context, userId string, id string) (bool, error)
+}
+
+type ServiceImpl struct {
+	l   *zap.Logger
+	r   WebPushRepository
+	as  auth.AuthService
+	key *ecdsa.PrivateKey
+}
+
+func NewService(l *zap.Logger, r WebPushRepository, as auth.AuthService, key *ecdsa.PrivateKey) *ServiceImpl {
+	return &ServiceImpl{l: l, r: r, as: as, key: key}
+}
+
+// LoadKey returns the configured VAPID key, or the one kept in the database, generating it on the first start.
+func LoadKey(ctx context.Context, l *zap.Logger, r WebPushRepository, configured string) (*ecdsa.PrivateKey, error) {
+	if configured != "" {
+		return parseKey(configured)
+	}
+	saved, err := r.GetVAPIDKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if saved == "" {
+		generated, err := generateKey()
+		if err != nil {
+			return nil, err
+		}
+		err = r.AddVAPIDKey(ctx, generated)
+		if err != nil {
+			return nil, err
+		}
+		// another replica may have saved its key first
+		saved, err = r.GetVAPIDKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		l.Info("Generated the VAPID key of web push")
+	}
+	return parseKey(saved)
+}
+
+// PublicKey is the applicationServerKey browsers subscribe with.
+func (s *ServiceImpl) PublicKey() string {
+	return publicKey(s.key)
+}
+
+// Subscribe saves the push subscription of a browser for the user, nil when the user doesn't exist.
+func (s *ServiceImpl) Subscribe(ctx context.Context, userId string, subscription models.PushSubscription) (*models.PushSubscription, error) {
+	err := validate(subscription)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.as.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	subscription.UserId = user.Id
+	err = s.r.AddSubscription(ctx, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Unsubscribe deletes the subscription, false when the user has no such subscription.
+func (s *ServiceImpl) Unsubscribe(ctx context.Context, userId string, id string) (bool, error) {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return false, nil
+	}
+	return s.r.DeleteSubscription(ctx, userId, id)
+}
+
+func validate(s models.PushSubscription) error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: the endpoint must be an https URL", ErrInvalidSubscription)
+	}
+	_, err = parseSubscriptionKeys(s.Keys.P256dh, s.Keys.Auth)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSubscription, err)
+	}
+	return nil
+}
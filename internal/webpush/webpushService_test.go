@@ -0,0 +1,174 @@
+package webpush
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"manny-reminder/internal/config"
+	"manny-reminder/internal/models"
+	"manny-reminder/internal/reminders"
+	"manny-reminder/mocks"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC)
+
+// pushed is a message the fake push service took.
+type pushed struct {
+	header http.Header
+	body   []byte
+}
+
+func TestNotifier_PushesToEverySubscription(t *testing.T) {
+	messages := make(chan pushed, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		messages <- pushed{r.Header, body}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	r, n := initNotifier(t)
+	user := generateUser()
+	r.On("GetSubscriptions", mock.Anything, user.Id.String()).
+		Return([]models.PushSubscription{subscription(srv.URL + "/a"), subscription(srv.URL + "/b")}, nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{
+		Kind: reminders.KindReminder, User: user,
+		Event: models.Event{Id: "e1", Title: "Standup", Start: "2022-06-01T13:10:00Z", JoinURL: "https://meet.google.com/abc-defg-hij"},
+		Actions: []reminders.Action{
+			{Name: reminders.ActionSnooze, Label: "Snooze 5 min", Token: "t1", URL: "https://manny.example.com/reminders/actions/t1"},
+			{Name: reminders.ActionDone, Label: "Done", Token: "t2"},
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, messages, 2)
+	message := <-messages
+	assert.Equal(t, "aes128gcm", message.header.Get("Content-Encoding"))
+	assert.Equal(t, "600", message.header.Get("TTL"))
+	assert.Equal(t, "high", message.header.Get("Urgency"))
+	assert.True(t, strings.HasPrefix(message.header.Get("Authorization"), "vapid t="))
+	// actions without a link can't be performed from the browser
+	assert.Equal(t, payload{
+		Title: "Standup", Body: `"Standup" starts at 1:10PM`, Tag: "e1", URL: "https://meet.google.com/abc-defg-hij",
+		Actions: []payloadAction{{Action: reminders.ActionSnooze, Title: "Snooze 5 min", URL: "https://manny.example.com/reminders/actions/t1"}},
+	}, decryptPayload(t, message.body))
+}
+
+func TestNotifier_DeletesExpiredSubscriptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte("slow down"))
+	}))
+	defer srv.Close()
+	r, n := initNotifier(t)
+	user := generateUser()
+	r.On("GetSubscriptions", mock.Anything, user.Id.String()).
+		Return([]models.PushSubscription{subscription(srv.URL + "/gone"), subscription(srv.URL + "/busy")}, nil)
+	r.On("DeleteSubscriptionByEndpoint", mock.Anything, srv.URL+"/gone").Return(nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: user})
+
+	// the delivery is retried for the busy one
+	assert.EqualError(t, err, "webpush: 429 slow down")
+}
+
+func TestNotifier_NoSubscription(t *testing.T) {
+	r, n := initNotifier(t)
+	user := generateUser()
+	r.On("GetSubscriptions", mock.Anything, user.Id.String()).Return([]models.PushSubscription{}, nil)
+
+	err := n.Notify(context.Background(), reminders.Notification{Kind: reminders.KindReminder, User: user})
+
+	assert.ErrorIs(t, err, errNoSubscriptions)
+}
+
+func TestService_Subscribe(t *testing.T) {
+	r := mocks.NewWebPushRepository(t)
+	as := mocks.NewAuthService(t)
+	s := NewService(zap.NewNop(), r, as, initKey(t))
+	user := generateUser()
+	as.On("GetUser", mock.Anything, user.Id.String()).Return(user, nil)
+	r.On("AddSubscription", mock.Anything, mock.MatchedBy(func(s *models.PushSubscription) bool {
+		return s.UserId == user.Id && s.Endpoint == "https://push.example.com/a"
+	})).Return(nil)
+
+	saved, err := s.Subscribe(context.Background(), user.Id.String(), subscription("https://push.example.com/a"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, saved.UserId)
+}
+
+func TestService_Subscribe_Invalid(t *testing.T) {
+	s := NewService(zap.NewNop(), mocks.NewWebPushRepository(t), mocks.NewAuthService(t), initKey(t))
+	insecure := subscription("http://push.example.com/a")
+	badKey := subscription("https://push.example.com/a")
+	badKey.Keys.Auth = "short"
+
+	_, err := s.Subscribe(context.Background(), "user", insecure)
+	assert.EqualError(t, err, "invalid push subscription: the endpoint must be an https URL")
+	_, err = s.Subscribe(context.Background(), "user", badKey)
+	assert.EqualError(t, err, "invalid push subscription: invalid auth secret: 16 bytes are expected")
+}
+
+func TestLoadKey_GeneratesOnce(t *testing.T) {
+	r := mocks.NewWebPushRepository(t)
+	r.On("GetVAPIDKey", mock.Anything).Return("", nil).Once()
+	r.On("AddVAPIDKey", mock.Anything, mock.Anything).Return(nil)
+	// another replica saved its key first
+	r.On("GetVAPIDKey", mock.Anything).Return(rfcSenderPrivate, nil).Once()
+
+	key, err := LoadKey(context.Background(), zap.NewNop(), r, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8", publicKey(key))
+}
+
+func TestHandler_Subscribe_BadRequest(t *testing.T) {
+	ws := mocks.NewWebPushService(t)
+	ws.On("Subscribe", mock.Anything, "user", mock.Anything).Return(nil, ErrInvalidSubscription)
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{userId}/push/subscriptions", NewHandler(ws).Subscribe)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/user/push/subscriptions", strings.NewReader(`{"endpoint": ""}`)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"error": "invalid push subscription"}`, rec.Body.String())
+}
+
+func initNotifier(t *testing.T) (*mocks.WebPushRepository, *Notifier) {
+	r := mocks.NewWebPushRepository(t)
+	n := NewNotifier(zap.NewNop(), r, initKey(t), config.WebPushConfig{Subject: "mailto:ops@example.com", TTL: 10 * time.Minute})
+	n.now = func() time.Time { return now }
+	return r, n
+}
+
+func initKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := parseKey(rfcSenderPrivate)
+	assert.Nil(t, err)
+	return key
+}
+
+func subscription(endpoint string) models.PushSubscription {
+	id := uuid.New()
+	return models.PushSubscription{Id: &id, Endpoint: endpoint, Keys: models.PushKeys{P256dh: rfcUAPublic, Auth: rfcAuth}}
+}
+
+func generateUser() *models.User {
+	id := uuid.New()
+	return &models.User{Id: &id}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id    UUID PRIMARY KEY,
+    email TEXT,
+    token TEXT NOT NULL
+);
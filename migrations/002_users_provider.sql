@@ -0,0 +1,2 @@
+-- the provider of the single account of every user, moved to accounts by 003
+ALTER TABLE users ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT 'google';
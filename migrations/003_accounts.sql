@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS accounts
+(
+    id       UUID PRIMARY KEY,
+    user_id  UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    email    TEXT,
+    token    TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS accounts_user_id_idx ON accounts (user_id);
+
+-- every existing user becomes a person with a single connected account, reusing the user id as account id. The token
+-- of the users is gone once copied, so a second run copies nothing.
+DO
+$$
+    BEGIN
+        IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'token') THEN
+            INSERT INTO accounts (id, user_id, provider, email, token)
+            SELECT id, id, provider, email, token
+            FROM users
+            ON CONFLICT (id) DO NOTHING;
+        END IF;
+    END
+$$;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS provider,
+    DROP COLUMN IF EXISTS token;
+
+-- an account belongs to one person: signing in with it again finds them and linking it again refreshes its token. The
+-- same account signed in twice as two users before keeps one of its rows.
+DELETE
+FROM accounts a
+    USING accounts b
+WHERE a.provider = b.provider
+  AND a.email = b.email
+  AND a.id < b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_provider_email_idx ON accounts (provider, email);
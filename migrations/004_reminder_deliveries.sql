@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS reminder_deliveries
+(
+    id             UUID PRIMARY KEY,
+    user_id        UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_id       TEXT        NOT NULL,
+    event_start    TIMESTAMPTZ NOT NULL,
+    offset_minutes INTEGER     NOT NULL,
+    channel        TEXT        NOT NULL,
+    send_at        TIMESTAMPTZ NOT NULL,
+    status         TEXT        NOT NULL DEFAULT 'pending',
+    attempts       INTEGER     NOT NULL DEFAULT 0,
+    last_error     TEXT        NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    claimed_at     TIMESTAMPTZ,
+    sent_at        TIMESTAMPTZ,
+    -- a reminder is sent at most once, however many schedulers run
+    UNIQUE (user_id, event_id, event_start, offset_minutes, channel)
+);
+
+-- schedulers claim due pending deliveries and expired claims
+CREATE INDEX IF NOT EXISTS reminder_deliveries_due_idx ON reminder_deliveries (send_at) WHERE status IN ('pending', 'sending');
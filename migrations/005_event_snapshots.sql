@@ -0,0 +1,19 @@
+-- the last seen state of the upcoming events of every user, diffed on sync to reschedule or cancel reminders
+CREATE TABLE IF NOT EXISTS event_snapshots
+(
+    user_id         UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_id        TEXT        NOT NULL,
+    ical_uid        TEXT        NOT NULL DEFAULT '',
+    title           TEXT        NOT NULL DEFAULT '',
+    start_at        TIMESTAMPTZ NOT NULL,
+    end_at          TIMESTAMPTZ NOT NULL,
+    organizer       TEXT        NOT NULL DEFAULT '',
+    status          TEXT        NOT NULL DEFAULT '',
+    response_status TEXT        NOT NULL DEFAULT '',
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS event_snapshots_start_idx ON event_snapshots (user_id, start_at);
+
+CREATE INDEX IF NOT EXISTS reminder_deliveries_event_idx ON reminder_deliveries (user_id, event_id);
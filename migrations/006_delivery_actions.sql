@@ -0,0 +1,2 @@
+-- a snoozed delivery is sent again, action links carry the count so each of them works once
+ALTER TABLE reminder_deliveries ADD COLUMN IF NOT EXISTS snoozes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- reminders link to the video conference of the event
+ALTER TABLE event_snapshots ADD COLUMN IF NOT EXISTS join_url TEXT NOT NULL DEFAULT '';
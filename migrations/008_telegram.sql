@@ -0,0 +1,16 @@
+-- one-time codes users send to the bot with /start to link their Telegram chat
+CREATE TABLE IF NOT EXISTS telegram_link_codes
+(
+    code       TEXT PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS telegram_chats
+(
+    user_id   UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    chat_id   BIGINT      NOT NULL,
+    linked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS telegram_chats_chat_idx ON telegram_chats (chat_id);
@@ -0,0 +1,20 @@
+-- browser push subscriptions, a browser subscribes once per endpoint
+CREATE TABLE IF NOT EXISTS webpush_subscriptions
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    endpoint   TEXT        NOT NULL UNIQUE,
+    p256dh     TEXT        NOT NULL,
+    auth       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS webpush_subscriptions_user_idx ON webpush_subscriptions (user_id);
+
+-- the VAPID key pair of the server, subscriptions are bound to its public key so it must not change
+CREATE TABLE IF NOT EXISTS webpush_vapid_keys
+(
+    id          SMALLINT PRIMARY KEY CHECK (id = 1),
+    private_key TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
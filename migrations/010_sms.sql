@@ -0,0 +1,21 @@
+-- the phone number of a user, only set once verified
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT;
+
+-- the one-time code sent to the number a user is verifying, a user verifies one number at a time
+CREATE TABLE IF NOT EXISTS sms_verifications
+(
+    user_id    UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    phone      TEXT        NOT NULL,
+    code_hash  TEXT        NOT NULL,
+    attempts   INTEGER     NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- the messages sent to a user per UTC day, for the daily cap
+CREATE TABLE IF NOT EXISTS sms_usage
+(
+    user_id UUID    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    day     DATE    NOT NULL,
+    sent    INTEGER NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
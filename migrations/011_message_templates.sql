@@ -0,0 +1,15 @@
+-- templates variables include the attendees of the event
+ALTER TABLE event_snapshots ADD COLUMN IF NOT EXISTS attendees TEXT[] NOT NULL DEFAULT '{}';
+
+-- message templates per channel, locale, kind of notification and format, 'default' channel templates apply to
+-- every channel
+CREATE TABLE IF NOT EXISTS message_templates
+(
+    channel    TEXT        NOT NULL,
+    locale     TEXT        NOT NULL,
+    kind       TEXT        NOT NULL,
+    format     TEXT        NOT NULL,
+    body       TEXT        NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (channel, locale, kind, format)
+);
@@ -0,0 +1,3 @@
+-- the time zone of a user is seeded from their calendar, NULL renders times in UTC
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT;
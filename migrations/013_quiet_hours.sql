@@ -0,0 +1,6 @@
+-- the quiet hours of a user, in their time zone, set when quiet_policy is
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quiet_start TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quiet_end TEXT;
+-- days of the week, 0 being Sunday, NULL for every day
+ALTER TABLE users ADD COLUMN IF NOT EXISTS working_days SMALLINT[];
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quiet_policy TEXT;
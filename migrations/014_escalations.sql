@@ -0,0 +1,4 @@
+-- the step of the escalation chain of an important event a delivery is, null for plain reminders
+ALTER TABLE reminder_deliveries ADD COLUMN IF NOT EXISTS escalation_step INTEGER;
+-- when the user marked the delivery as done, which stops the escalation of the event
+ALTER TABLE reminder_deliveries ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMPTZ;
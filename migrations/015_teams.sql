@@ -0,0 +1,16 @@
+-- reminder rules are inherited when null: offsets in minutes and channels of a member override those of their team,
+-- which override the configured ones
+CREATE TABLE IF NOT EXISTS teams
+(
+    id                UUID PRIMARY KEY,
+    name              TEXT        NOT NULL,
+    reminder_offsets  INTEGER[],
+    reminder_channels TEXT[],
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS team_id UUID REFERENCES teams (id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reminder_offsets INTEGER[];
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reminder_channels TEXT[];
+
+CREATE INDEX IF NOT EXISTS users_team_id_idx ON users (team_id);
@@ -0,0 +1,2 @@
+-- why a delivery was cancelled, only those cancelled because their event changed come back with the event
+ALTER TABLE reminder_deliveries ADD COLUMN IF NOT EXISTS cancel_reason TEXT NOT NULL DEFAULT '';
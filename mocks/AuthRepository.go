@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	models "manny-reminder/internal/models"
 
 	mock "github.com/stretchr/testify/mock"
@@ -15,13 +16,13 @@ type AuthRepository struct {
 	mock.Mock
 }
 
-// AddUser provides a mock function with given fields: authCode, token
-func (_m *AuthRepository) AddUser(authCode string, token string) error {
-	ret := _m.Called(authCode, token)
+// AddAccount provides a mock function with given fields: ctx, account
+func (_m *AuthRepository) AddAccount(ctx context.Context, account models.ConnectedAccount) error {
+	ret := _m.Called(ctx, account)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, string) error); ok {
-		r0 = rf(authCode, token)
+	if rf, ok := ret.Get(0).(func(context.Context, models.ConnectedAccount) error); ok {
+		r0 = rf(ctx, account)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -29,13 +30,94 @@ func (_m *AuthRepository) AddUser(authCode string, token string) error {
 	return r0
 }
 
-// GetUser provides a mock function with given fields: id
-func (_m *AuthRepository) GetUser(id string) (*models.User, error) {
-	ret := _m.Called(id)
+// AddUser provides a mock function with given fields: ctx, id, email, account
+func (_m *AuthRepository) AddUser(ctx context.Context, id string, email string, account models.ConnectedAccount) error {
+	ret := _m.Called(ctx, id, email, account)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, models.ConnectedAccount) error); ok {
+		r0 = rf(ctx, id, email, account)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteUser provides a mock function with given fields: ctx, id
+func (_m *AuthRepository) DeleteUser(ctx context.Context, id string) (bool, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAccount provides a mock function with given fields: ctx, provider, email
+func (_m *AuthRepository) GetAccount(ctx context.Context, provider string, email string) (*models.ConnectedAccount, error) {
+	ret := _m.Called(ctx, provider, email)
+
+	var r0 *models.ConnectedAccount
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *models.ConnectedAccount); ok {
+		r0 = rf(ctx, provider, email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.ConnectedAccount)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, provider, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTeamMembers provides a mock function with given fields: ctx, teamId
+func (_m *AuthRepository) GetTeamMembers(ctx context.Context, teamId string) ([]models.User, error) {
+	ret := _m.Called(ctx, teamId)
+
+	var r0 []models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.User); ok {
+		r0 = rf(ctx, teamId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, teamId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUser provides a mock function with given fields: ctx, id
+func (_m *AuthRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 *models.User
-	if rf, ok := ret.Get(0).(func(string) *models.User); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.User)
@@ -43,8 +125,8 @@ func (_m *AuthRepository) GetUser(id string) (*models.User, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -52,13 +134,13 @@ func (_m *AuthRepository) GetUser(id string) (*models.User, error) {
 	return r0, r1
 }
 
-// GetUsers provides a mock function with given fields:
-func (_m *AuthRepository) GetUsers() ([]models.User, error) {
-	ret := _m.Called()
+// GetUsers provides a mock function with given fields: ctx
+func (_m *AuthRepository) GetUsers(ctx context.Context) ([]models.User, error) {
+	ret := _m.Called(ctx)
 
 	var r0 []models.User
-	if rf, ok := ret.Get(0).(func() []models.User); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context) []models.User); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.User)
@@ -66,8 +148,8 @@ func (_m *AuthRepository) GetUsers() ([]models.User, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -75,13 +157,55 @@ func (_m *AuthRepository) GetUsers() ([]models.User, error) {
 	return r0, r1
 }
 
-// UpdateUserToken provides a mock function with given fields: id, token
-func (_m *AuthRepository) UpdateUserToken(id *uuid.UUID, token string) error {
-	ret := _m.Called(id, token)
+// SavePreferences provides a mock function with given fields: ctx, userId, timeZone, locale
+func (_m *AuthRepository) SavePreferences(ctx context.Context, userId string, timeZone *string, locale *string) error {
+	ret := _m.Called(ctx, userId, timeZone, locale)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *string, *string) error); ok {
+		r0 = rf(ctx, userId, timeZone, locale)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveQuietHours provides a mock function with given fields: ctx, userId, q
+func (_m *AuthRepository) SaveQuietHours(ctx context.Context, userId string, q *models.QuietHours) error {
+	ret := _m.Called(ctx, userId, q)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.QuietHours) error); ok {
+		r0 = rf(ctx, userId, q)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveReminderRules provides a mock function with given fields: ctx, userId, rules
+func (_m *AuthRepository) SaveReminderRules(ctx context.Context, userId string, rules models.ReminderRules) error {
+	ret := _m.Called(ctx, userId, rules)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.ReminderRules) error); ok {
+		r0 = rf(ctx, userId, rules)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateAccountToken provides a mock function with given fields: ctx, id, token
+func (_m *AuthRepository) UpdateAccountToken(ctx context.Context, id *uuid.UUID, token string) error {
+	ret := _m.Called(ctx, id, token)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*uuid.UUID, string) error); ok {
-		r0 = rf(id, token)
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, string) error); ok {
+		r0 = rf(ctx, id, token)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -3,11 +3,13 @@
 package mocks
 
 import (
-	models "manny-reminder/internal/models"
+	context "context"
 	http "net/http"
 
 	mock "github.com/stretchr/testify/mock"
 
+	models "manny-reminder/internal/models"
+
 	oauth2 "golang.org/x/oauth2"
 )
 
@@ -16,43 +18,131 @@ type AuthService struct {
 	mock.Mock
 }
 
-// GetClient provides a mock function with given fields: user
-func (_m *AuthService) GetClient(user string) *http.Client {
-	ret := _m.Called(user)
+// DeleteUser provides a mock function with given fields: ctx, id
+func (_m *AuthService) DeleteUser(ctx context.Context, id string) (bool, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishDeviceLink provides a mock function with given fields: ctx, code
+func (_m *AuthService) FinishDeviceLink(ctx context.Context, code models.DeviceCode) (*models.User, error) {
+	ret := _m.Called(ctx, code)
+
+	var r0 *models.User
+	if rf, ok := ret.Get(0).(func(context.Context, models.DeviceCode) *models.User); ok {
+		r0 = rf(ctx, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.DeviceCode) error); ok {
+		r1 = rf(ctx, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetClient provides a mock function with given fields: ctx, user
+func (_m *AuthService) GetClient(ctx context.Context, user string) (*http.Client, error) {
+	ret := _m.Called(ctx, user)
 
 	var r0 *http.Client
-	if rf, ok := ret.Get(0).(func(string) *http.Client); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *http.Client); ok {
+		r0 = rf(ctx, user)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*http.Client)
 		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetTokenFromWeb provides a mock function with given fields:
-func (_m *AuthService) GetTokenFromWeb() string {
-	ret := _m.Called()
+// GetTeamMembers provides a mock function with given fields: ctx, teamId
+func (_m *AuthService) GetTeamMembers(ctx context.Context, teamId string) ([]models.User, error) {
+	ret := _m.Called(ctx, teamId)
+
+	var r0 []models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) []models.User); ok {
+		r0 = rf(ctx, teamId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, teamId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTokenFromWeb provides a mock function with given fields: ctx, provider, userId
+func (_m *AuthService) GetTokenFromWeb(ctx context.Context, provider string, userId string) (string, string, error) {
+	ret := _m.Called(ctx, provider, userId)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func() string); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, provider, userId)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	return r0
+	var r1 string
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) string); ok {
+		r1 = rf(ctx, provider, userId)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, string) error); ok {
+		r2 = rf(ctx, provider, userId)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// GetUser provides a mock function with given fields: id
-func (_m *AuthService) GetUser(id string) (*models.User, error) {
-	ret := _m.Called(id)
+// GetUser provides a mock function with given fields: ctx, id
+func (_m *AuthService) GetUser(ctx context.Context, id string) (*models.User, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 *models.User
-	if rf, ok := ret.Get(0).(func(string) *models.User); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.User)
@@ -60,8 +150,8 @@ func (_m *AuthService) GetUser(id string) (*models.User, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -69,13 +159,13 @@ func (_m *AuthService) GetUser(id string) (*models.User, error) {
 	return r0, r1
 }
 
-// GetUsers provides a mock function with given fields:
-func (_m *AuthService) GetUsers() ([]models.User, error) {
-	ret := _m.Called()
+// GetUsers provides a mock function with given fields: ctx
+func (_m *AuthService) GetUsers(ctx context.Context) ([]models.User, error) {
+	ret := _m.Called(ctx)
 
 	var r0 []models.User
-	if rf, ok := ret.Get(0).(func() []models.User); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context) []models.User); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]models.User)
@@ -83,8 +173,8 @@ func (_m *AuthService) GetUsers() ([]models.User, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -92,13 +182,13 @@ func (_m *AuthService) GetUsers() ([]models.User, error) {
 	return r0, r1
 }
 
-// RefreshUser provides a mock function with given fields: user
-func (_m *AuthService) RefreshUser(user *models.User) (*oauth2.Token, error) {
-	ret := _m.Called(user)
+// RefreshAccount provides a mock function with given fields: ctx, account
+func (_m *AuthService) RefreshAccount(ctx context.Context, account *models.ConnectedAccount) (*oauth2.Token, error) {
+	ret := _m.Called(ctx, account)
 
 	var r0 *oauth2.Token
-	if rf, ok := ret.Get(0).(func(*models.User) *oauth2.Token); ok {
-		r0 = rf(user)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.ConnectedAccount) *oauth2.Token); ok {
+		r0 = rf(ctx, account)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*oauth2.Token)
@@ -106,8 +196,8 @@ func (_m *AuthService) RefreshUser(user *models.User) (*oauth2.Token, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(*models.User) error); ok {
-		r1 = rf(user)
+	if rf, ok := ret.Get(1).(func(context.Context, *models.ConnectedAccount) error); ok {
+		r1 = rf(ctx, account)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -115,13 +205,82 @@ func (_m *AuthService) RefreshUser(user *models.User) (*oauth2.Token, error) {
 	return r0, r1
 }
 
-// SaveUser provides a mock function with given fields: authCode
-func (_m *AuthService) SaveUser(authCode string) error {
-	ret := _m.Called(authCode)
+// SavePreferences provides a mock function with given fields: ctx, userId, p
+func (_m *AuthService) SavePreferences(ctx context.Context, userId string, p models.UserPreferences) (*models.User, error) {
+	ret := _m.Called(ctx, userId, p)
+
+	var r0 *models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.UserPreferences) *models.User); ok {
+		r0 = rf(ctx, userId, p)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.UserPreferences) error); ok {
+		r1 = rf(ctx, userId, p)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveQuietHours provides a mock function with given fields: ctx, userId, q
+func (_m *AuthService) SaveQuietHours(ctx context.Context, userId string, q *models.QuietHours) (*models.User, error) {
+	ret := _m.Called(ctx, userId, q)
+
+	var r0 *models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string, *models.QuietHours) *models.User); ok {
+		r0 = rf(ctx, userId, q)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, *models.QuietHours) error); ok {
+		r1 = rf(ctx, userId, q)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveReminderRules provides a mock function with given fields: ctx, userId, rules
+func (_m *AuthService) SaveReminderRules(ctx context.Context, userId string, rules models.ReminderRules) (*models.User, error) {
+	ret := _m.Called(ctx, userId, rules)
+
+	var r0 *models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.ReminderRules) *models.User); ok {
+		r0 = rf(ctx, userId, rules)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.ReminderRules) error); ok {
+		r1 = rf(ctx, userId, rules)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveUser provides a mock function with given fields: ctx, state, nonce, authCode
+func (_m *AuthService) SaveUser(ctx context.Context, state string, nonce string, authCode string) error {
+	ret := _m.Called(ctx, state, nonce, authCode)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(authCode)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, state, nonce, authCode)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -129,6 +288,52 @@ func (_m *AuthService) SaveUser(authCode string) error {
 	return r0
 }
 
+// SeedQuietHours provides a mock function with given fields: ctx, userId, policy
+func (_m *AuthService) SeedQuietHours(ctx context.Context, userId string, policy string) (*models.User, error) {
+	ret := _m.Called(ctx, userId, policy)
+
+	var r0 *models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *models.User); ok {
+		r0 = rf(ctx, userId, policy)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userId, policy)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StartDeviceLink provides a mock function with given fields: ctx, provider, userId
+func (_m *AuthService) StartDeviceLink(ctx context.Context, provider string, userId string) (*models.DeviceCode, error) {
+	ret := _m.Called(ctx, provider, userId)
+
+	var r0 *models.DeviceCode
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *models.DeviceCode); ok {
+		r0 = rf(ctx, provider, userId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.DeviceCode)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, provider, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type NewAuthServiceT interface {
 	mock.TestingT
 	Cleanup(func())
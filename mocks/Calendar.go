@@ -9,6 +9,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	oauth2 "golang.org/x/oauth2"
+
+	time "time"
 )
 
 // Calendar is an autogenerated mock type for the Calendar type
@@ -16,6 +18,27 @@ type Calendar struct {
 	mock.Mock
 }
 
+// GetEmail provides a mock function with given fields: ctx, tok
+func (_m *Calendar) GetEmail(ctx context.Context, tok oauth2.Token) (string, error) {
+	ret := _m.Called(ctx, tok)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, oauth2.Token) string); ok {
+		r0 = rf(ctx, tok)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oauth2.Token) error); ok {
+		r1 = rf(ctx, tok)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetEventsForUser provides a mock function with given fields: ctx, tok, nextPageToken, size
 func (_m *Calendar) GetEventsForUser(ctx context.Context, tok oauth2.Token, nextPageToken string, size int) (*models.Events, string, error) {
 	ret := _m.Called(ctx, tok, nextPageToken, size)
@@ -46,6 +69,73 @@ func (_m *Calendar) GetEventsForUser(ctx context.Context, tok oauth2.Token, next
 	return r0, r1, r2
 }
 
+// GetEventsInRange provides a mock function with given fields: ctx, tok, from, to
+func (_m *Calendar) GetEventsInRange(ctx context.Context, tok oauth2.Token, from time.Time, to time.Time) (*models.Events, error) {
+	ret := _m.Called(ctx, tok, from, to)
+
+	var r0 *models.Events
+	if rf, ok := ret.Get(0).(func(context.Context, oauth2.Token, time.Time, time.Time) *models.Events); ok {
+		r0 = rf(ctx, tok, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Events)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oauth2.Token, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, tok, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTimeZone provides a mock function with given fields: ctx, tok
+func (_m *Calendar) GetTimeZone(ctx context.Context, tok oauth2.Token) (string, error) {
+	ret := _m.Called(ctx, tok)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, oauth2.Token) string); ok {
+		r0 = rf(ctx, tok)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oauth2.Token) error); ok {
+		r1 = rf(ctx, tok)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWorkingHours provides a mock function with given fields: ctx, tok
+func (_m *Calendar) GetWorkingHours(ctx context.Context, tok oauth2.Token) (*models.WorkingHours, error) {
+	ret := _m.Called(ctx, tok)
+
+	var r0 *models.WorkingHours
+	if rf, ok := ret.Get(0).(func(context.Context, oauth2.Token) *models.WorkingHours); ok {
+		r0 = rf(ctx, tok)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.WorkingHours)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, oauth2.Token) error); ok {
+		r1 = rf(ctx, tok)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type NewCalendarT interface {
 	mock.TestingT
 	Cleanup(func())
@@ -2,13 +2,399 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	models "manny-reminder/internal/models"
+
+	time "time"
+
+	uuid "github.com/google/uuid"
+)
 
 // EventsRepository is an autogenerated mock type for the EventsRepository type
 type EventsRepository struct {
 	mock.Mock
 }
 
+// AcknowledgeDelivery provides a mock function with given fields: ctx, id, snoozes
+func (_m *EventsRepository) AcknowledgeDelivery(ctx context.Context, id *uuid.UUID, snoozes int) (bool, error) {
+	ret := _m.Called(ctx, id, snoozes)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, int) bool); ok {
+		r0 = rf(ctx, id, snoozes)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID, int) error); ok {
+		r1 = rf(ctx, id, snoozes)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddDelivery provides a mock function with given fields: ctx, delivery
+func (_m *EventsRepository) AddDelivery(ctx context.Context, delivery models.Delivery) (bool, error) {
+	ret := _m.Called(ctx, delivery)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, models.Delivery) bool); ok {
+		r0 = rf(ctx, delivery)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.Delivery) error); ok {
+		r1 = rf(ctx, delivery)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelDeliveries provides a mock function with given fields: ctx, userId, eventId, keepStart, reason
+func (_m *EventsRepository) CancelDeliveries(ctx context.Context, userId string, eventId string, keepStart *time.Time, reason string) error {
+	ret := _m.Called(ctx, userId, eventId, keepStart, reason)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *time.Time, string) error); ok {
+		r0 = rf(ctx, userId, eventId, keepStart, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CancelDelivery provides a mock function with given fields: ctx, id, claimedAt, reason
+func (_m *EventsRepository) CancelDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, reason string) (bool, error) {
+	ret := _m.Called(ctx, id, claimedAt, reason)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, *time.Time, string) bool); ok {
+		r0 = rf(ctx, id, claimedAt, reason)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID, *time.Time, string) error); ok {
+		r1 = rf(ctx, id, claimedAt, reason)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimDeliveries provides a mock function with given fields: ctx, now, lease, limit
+func (_m *EventsRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) (models.Deliveries, error) {
+	ret := _m.Called(ctx, now, lease, limit)
+
+	var r0 models.Deliveries
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Duration, int) models.Deliveries); ok {
+		r0 = rf(ctx, now, lease, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(models.Deliveries)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Duration, int) error); ok {
+		r1 = rf(ctx, now, lease, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CompleteDelivery provides a mock function with given fields: ctx, id, claimedAt, sentAt
+func (_m *EventsRepository) CompleteDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, sentAt time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, claimedAt, sentAt)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, *time.Time, time.Time) bool); ok {
+		r0 = rf(ctx, id, claimedAt, sentAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID, *time.Time, time.Time) error); ok {
+		r1 = rf(ctx, id, claimedAt, sentAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeferDelivery provides a mock function with given fields: ctx, id, claimedAt, sendAt
+func (_m *EventsRepository) DeferDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, sendAt time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, claimedAt, sendAt)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, *time.Time, time.Time) bool); ok {
+		r0 = rf(ctx, id, claimedAt, sendAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID, *time.Time, time.Time) error); ok {
+		r1 = rf(ctx, id, claimedAt, sendAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteEventSnapshot provides a mock function with given fields: ctx, userId, previous
+func (_m *EventsRepository) DeleteEventSnapshot(ctx context.Context, userId string, previous models.Event) (bool, error) {
+	ret := _m.Called(ctx, userId, previous)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Event) bool); ok {
+		r0 = rf(ctx, userId, previous)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.Event) error); ok {
+		r1 = rf(ctx, userId, previous)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FailDelivery provides a mock function with given fields: ctx, id, claimedAt, lastError, retryAt
+func (_m *EventsRepository) FailDelivery(ctx context.Context, id *uuid.UUID, claimedAt *time.Time, lastError string, retryAt *time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, claimedAt, lastError, retryAt)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, *time.Time, string, *time.Time) bool); ok {
+		r0 = rf(ctx, id, claimedAt, lastError, retryAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID, *time.Time, string, *time.Time) error); ok {
+		r1 = rf(ctx, id, claimedAt, lastError, retryAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveries provides a mock function with given fields: ctx, filter
+func (_m *EventsRepository) GetDeliveries(ctx context.Context, filter models.DeliveryFilter) (models.Deliveries, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 models.Deliveries
+	if rf, ok := ret.Get(0).(func(context.Context, models.DeliveryFilter) models.Deliveries); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(models.Deliveries)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.DeliveryFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDelivery provides a mock function with given fields: ctx, id
+func (_m *EventsRepository) GetDelivery(ctx context.Context, id string) (*models.Delivery, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *models.Delivery
+	if rf, ok := ret.Get(0).(func(context.Context, string) *models.Delivery); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Delivery)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEventSnapshot provides a mock function with given fields: ctx, userId, eventId
+func (_m *EventsRepository) GetEventSnapshot(ctx context.Context, userId string, eventId string) (*models.Event, error) {
+	ret := _m.Called(ctx, userId, eventId)
+
+	var r0 *models.Event
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *models.Event); ok {
+		r0 = rf(ctx, userId, eventId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, userId, eventId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetEventSnapshots provides a mock function with given fields: ctx, userId, from, to
+func (_m *EventsRepository) GetEventSnapshots(ctx context.Context, userId string, from time.Time, to time.Time) (models.Events, error) {
+	ret := _m.Called(ctx, userId, from, to)
+
+	var r0 models.Events
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, time.Time) models.Events); ok {
+		r0 = rf(ctx, userId, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(models.Events)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, userId, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsAcknowledged provides a mock function with given fields: ctx, userId, eventId, eventStart
+func (_m *EventsRepository) IsAcknowledged(ctx context.Context, userId string, eventId string, eventStart time.Time) (bool, error) {
+	ret := _m.Called(ctx, userId, eventId, eventStart)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) bool); ok {
+		r0 = rf(ctx, userId, eventId, eventStart)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, userId, eventId, eventStart)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PruneEventSnapshots provides a mock function with given fields: ctx, before
+func (_m *EventsRepository) PruneEventSnapshots(ctx context.Context, before time.Time) error {
+	ret := _m.Called(ctx, before)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) error); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResendDelivery provides a mock function with given fields: ctx, id
+func (_m *EventsRepository) ResendDelivery(ctx context.Context, id *uuid.UUID) (*models.Delivery, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *models.Delivery
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID) *models.Delivery); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Delivery)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveEventSnapshot provides a mock function with given fields: ctx, userId, event, previous
+func (_m *EventsRepository) SaveEventSnapshot(ctx context.Context, userId string, event models.Event, previous *models.Event) (bool, error) {
+	ret := _m.Called(ctx, userId, event, previous)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, models.Event, *models.Event) bool); ok {
+		r0 = rf(ctx, userId, event, previous)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, models.Event, *models.Event) error); ok {
+		r1 = rf(ctx, userId, event, previous)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SnoozeDelivery provides a mock function with given fields: ctx, id, snoozes, sendAt
+func (_m *EventsRepository) SnoozeDelivery(ctx context.Context, id *uuid.UUID, snoozes int, sendAt time.Time) (bool, error) {
+	ret := _m.Called(ctx, id, snoozes, sendAt)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *uuid.UUID, int, time.Time) bool); ok {
+		r0 = rf(ctx, id, snoozes, sendAt)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *uuid.UUID, int, time.Time) error); ok {
+		r1 = rf(ctx, id, snoozes, sendAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type NewEventsRepositoryT interface {
 	mock.TestingT
 	Cleanup(func())